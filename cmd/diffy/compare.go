@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sgr0691/diffy/internal/analyze"
+	"github.com/sgr0691/diffy/internal/compare"
+	"github.com/sgr0691/diffy/internal/parse"
+	"github.com/sgr0691/diffy/internal/render"
+)
+
+var (
+	flagCompareFormat string
+	flagFailOnNew     string
+)
+
+var compareCmd = &cobra.Command{
+	Use:   "compare <old-plan.json> <new-plan.json>",
+	Short: "Compare two Terraform plans",
+	Long: `Compare two plan JSON files, e.g. the plan a reviewer approved and the
+plan after the pull request was updated.
+
+Reports resources added to or dropped from the plan, resources whose action
+changed (for example update → replace), and findings that appeared or were
+resolved. Either file may be "-" to read from stdin.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runCompare,
+}
+
+func init() {
+	compareCmd.Flags().StringVar(&flagCompareFormat, "format", "md", "output format: md, text, or json")
+	compareCmd.Flags().StringVar(&flagFailOnNew, "fail-on-new", "", "exit 2 if newly introduced findings are at or above this severity: info, low, medium, high, critical")
+
+	rootCmd.AddCommand(compareCmd)
+}
+
+func runCompare(cmd *cobra.Command, args []string) error {
+	if args[0] == "-" && args[1] == "-" {
+		return fmt.Errorf("only one plan can be read from stdin")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if !cmd.Flags().Changed("format") && cfg.Format != "" {
+		flagCompareFormat = cfg.Format
+	}
+	registry, err := configuredRegistry(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Validate format
+	var renderer interface {
+		render.Renderer
+		render.ComparisonRenderer
+	}
+	switch flagCompareFormat {
+	case "md":
+		renderer = render.MarkdownRenderer{}
+	case "text":
+		renderer = render.TextRenderer{}
+	case "json":
+		renderer = render.JSONRenderer{}
+	default:
+		return fmt.Errorf("invalid format %q: must be md, text, or json", flagCompareFormat)
+	}
+
+	// Parse threshold
+	var threshold *analyze.Severity
+	if flagFailOnNew != "" {
+		sev, ok := analyze.ParseSeverity(flagFailOnNew)
+		if !ok {
+			return fmt.Errorf("invalid --fail-on-new value %q: must be info, low, medium, high, or critical", flagFailOnNew)
+		}
+		threshold = &sev
+	}
+
+	// Load and analyze both plans
+	var sides [2]compare.Plan
+	for i, path := range args {
+		var plan *parse.Plan
+		var err error
+		if path == "-" {
+			plan, err = parse.Load(os.Stdin)
+		} else {
+			plan, err = parse.LoadFile(path)
+		}
+		if err != nil {
+			printLoadError(renderer, flagCompareFormat, &parse.SourceError{Path: path, Err: err})
+			os.Exit(1)
+		}
+		findings := registry.Analyze(plan.ResourceChanges)
+		findings = append(findings, registry.AnalyzeDrift(plan.ResourceDrift, plan.ResourceChanges)...)
+		findings = append(findings, registry.AnalyzeOutputs(plan.OutputChanges)...)
+		sides[i] = compare.Plan{Changes: plan.ResourceChanges, Findings: findings}
+	}
+
+	diff := compare.Compare(sides[0], sides[1])
+
+	// Determine exit code: only newly introduced findings gate
+	exitCode := 0
+	if threshold != nil && diff.NewFindingsAtOrAbove(*threshold) {
+		exitCode = 2
+	}
+
+	fmt.Print(renderer.RenderComparison(render.ComparisonResult{
+		Before:    sides[0].Changes,
+		After:     sides[1].Changes,
+		Diff:      diff,
+		Threshold: threshold,
+		ExitCode:  exitCode,
+	}))
+	os.Exit(exitCode)
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sgr0691/diffy/internal/analyze"
+	"github.com/sgr0691/diffy/internal/config"
+)
+
+var flagConfig string
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Work with the .diffy.yaml configuration file",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [path]",
+	Short: "Check a configuration file for errors",
+	Long: `Check a configuration file, and the suppression file it names, for unknown
+fields, values of the wrong type and unknown rule IDs. Without a path, the
+file given by --config or found from the working directory upward is
+checked. Exits 1 if either is invalid.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runConfigValidate,
+	// The problems are the output; Execute prints them once.
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&flagConfig, "config", "", "path to a configuration file (default: "+config.FileName+" in the working directory or the nearest parent)")
+
+	configCmd.AddCommand(configValidateCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// loadConfig loads the configuration given by --config, or else the one
+// found from the working directory upward. It returns an empty
+// configuration when there is none.
+func loadConfig() (*config.Config, error) {
+	path := flagConfig
+	if path == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		if path, err = config.Find(wd); err != nil {
+			return nil, err
+		}
+		if path == "" {
+			return &config.Config{}, nil
+		}
+	}
+	return config.Load(path)
+}
+
+// loadSuppressions loads the suppression file given by path, or else the
+// one named by cfg, checking rule IDs against registry. It returns nil when
+// there is none.
+func loadSuppressions(cfg *config.Config, registry *analyze.Registry, path string) ([]analyze.Suppression, error) {
+	if path == "" {
+		path = cfg.Suppressions
+	}
+	if path == "" {
+		return nil, nil
+	}
+	return config.LoadSuppressions(path, registry)
+}
+
+// configuredRegistry returns the built-in rules as configured by cfg.
+func configuredRegistry(cfg *config.Config) (*analyze.Registry, error) {
+	registry := analyze.DefaultRegistry()
+	if err := cfg.Apply(registry); err != nil {
+		return nil, fmt.Errorf("config %s: %w", cfg.Path, err)
+	}
+	return registry, nil
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	if len(args) == 1 {
+		flagConfig = args[0]
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.Path == "" {
+		return fmt.Errorf("no %s found in the working directory or its parents", config.FileName)
+	}
+	// Check everything before reporting success, so an invalid
+	// suppressions file is never preceded by a "valid" line.
+	var suppressions []analyze.Suppression
+	if cfg.Suppressions != "" {
+		registry, err := configuredRegistry(cfg)
+		if err != nil {
+			return err
+		}
+		if suppressions, err = loadSuppressions(cfg, registry, ""); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("%s is valid\n", cfg.Path)
+	if cfg.Suppressions != "" {
+		expired := 0
+		for _, s := range suppressions {
+			if s.Expired(time.Now()) {
+				expired++
+			}
+		}
+		fmt.Printf("%s is valid (%d suppressions, %d expired)\n", cfg.Suppressions, len(suppressions), expired)
+	}
+	return nil
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/sgr0691/diffy/internal/analyze"
+	"github.com/sgr0691/diffy/internal/graph"
 	"github.com/sgr0691/diffy/internal/parse"
 	"github.com/sgr0691/diffy/internal/render"
 )
@@ -15,45 +20,115 @@ var (
 	flagFromPlan string
 	flagFormat   string
 	flagFailOn   string
+	flagGroupBy  string
+	flagBinary   string
+	flagTimeout  time.Duration
+
+	flagSuppressions string
+	flagBaseline     string
 )
 
 var explainCmd = &cobra.Command{
-	Use:   "explain [plan.json]",
+	Use:   "explain [plan.json... | dir | -]",
 	Short: "Explain a Terraform plan",
 	Long: `Explain a Terraform plan by parsing its JSON output, analyzing changes,
 and producing a human-readable summary with risk findings.
 
-Provide either a plan JSON file as an argument, or use --from-plan to point
-to a binary plan file (Diffy will run terraform show -json for you).`,
-	Args: cobra.MaximumNArgs(1),
+Provide either plan JSON files as arguments, or use --from-plan to point
+to a binary plan file (Diffy will run terraform show -json for you, or
+tofu show -json for OpenTofu; see --binary). Pass "-"
+to read plan JSON from stdin. Gzip and zstd compressed plans are detected and
+decompressed automatically.
+
+Several files, glob patterns or directories (searched recursively for
+*.json, *.json.gz and *.json.zst) are explained together, e.g. the plans of a
+Terragrunt run-all. Each plan becomes a stack named after its path; output
+includes per-stack counts and findings plus the combined totals, and
+--fail-on applies to the combined findings.
+
+With --baseline, findings whose fingerprint appears in a previous run's
+JSON output are marked "in baseline" and do not count toward --fail-on, so
+only new findings fail the build.`,
+	Args: cobra.ArbitraryArgs,
 	RunE: runExplain,
 }
 
 func init() {
 	explainCmd.Flags().StringVar(&flagFromPlan, "from-plan", "", "path to binary Terraform plan file (runs terraform show -json)")
-	explainCmd.Flags().StringVar(&flagFormat, "format", "md", "output format: md, text, or json")
-	explainCmd.Flags().StringVar(&flagFailOn, "fail-on", "", "exit 2 if findings at or above this severity: info, low, medium, high, critical")
+	explainCmd.Flags().StringVar(&flagFormat, "format", "md", "output format: md, text, or json; overrides the config file's format")
+	explainCmd.Flags().StringVar(&flagFailOn, "fail-on", "", "exit 2 if findings at or above this severity: info, low, medium, high, critical; overrides the config file's fail_on")
+	explainCmd.Flags().StringVar(&flagBinary, "binary", "", "terraform or tofu executable used with --from-plan (default: $"+parse.BinaryEnv+", then terraform, then tofu from PATH)")
+	explainCmd.Flags().DurationVar(&flagTimeout, "timeout", 0, "kill terraform show after this long with --from-plan, e.g. 2m (0 waits indefinitely)")
+	explainCmd.Flags().StringVar(&flagSuppressions, "suppressions", "", "path to a suppression file (default: the config file's suppressions)")
+	explainCmd.Flags().StringVar(&flagBaseline, "baseline", "", "JSON output of a previous diffy explain; --fail-on ignores the findings it contains")
+	explainCmd.Flags().StringVar(&flagGroupBy, "group-by", "", "add a rollup of counts and max severity: module")
 
 	rootCmd.AddCommand(explainCmd)
 }
 
 func runExplain(cmd *cobra.Command, args []string) error {
-	// Validate input: exactly one of plan.json arg or --from-plan
-	hasArg := len(args) == 1
+	// Validate input: plan.json args or --from-plan
+	hasArg := len(args) > 0
 	hasPlan := flagFromPlan != ""
 
 	if !hasArg && !hasPlan {
-		return fmt.Errorf("provide a plan JSON file as an argument, or use --from-plan <plan.out>\n\nUsage: diffy explain <plan.json>\n       diffy explain --from-plan <plan.out>")
+		return fmt.Errorf("provide a plan JSON file as an argument, or use --from-plan <plan.out>\n\nUsage: diffy explain <plan.json>\n       terraform show -json plan.out | diffy explain -\n       diffy explain --from-plan <plan.out>")
 	}
 	if hasArg && hasPlan {
 		return fmt.Errorf("provide either a plan JSON file argument or --from-plan, not both")
 	}
+	if len(args) > 1 {
+		for _, a := range args {
+			if a == "-" {
+				return fmt.Errorf("- (stdin) cannot be combined with other plan files")
+			}
+		}
+	}
+
+	if flagBinary != "" && !hasPlan {
+		return fmt.Errorf("--binary only applies with --from-plan")
+	}
+	if flagTimeout < 0 {
+		return fmt.Errorf("invalid --timeout %s: must not be negative", flagTimeout)
+	}
+
+	// Flags win over the configuration file
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if !cmd.Flags().Changed("format") && cfg.Format != "" {
+		flagFormat = cfg.Format
+	}
+	if !cmd.Flags().Changed("fail-on") && cfg.FailOn != "" {
+		flagFailOn = cfg.FailOn
+	}
+	registry, err := configuredRegistry(cfg)
+	if err != nil {
+		return err
+	}
+	suppressions, err := loadSuppressions(cfg, registry, flagSuppressions)
+	if err != nil {
+		return err
+	}
+
+	var baseline analyze.Baseline
+	if flagBaseline != "" {
+		if baseline, err = loadBaseline(flagBaseline); err != nil {
+			return err
+		}
+	}
 
 	// Validate format
 	if flagFormat != "md" && flagFormat != "text" && flagFormat != "json" {
 		return fmt.Errorf("invalid format %q: must be md, text, or json", flagFormat)
 	}
 
+	// Validate grouping
+	if flagGroupBy != "" && flagGroupBy != render.GroupByModule {
+		return fmt.Errorf("invalid --group-by value %q: must be module", flagGroupBy)
+	}
+
 	// Parse threshold
 	var threshold *analyze.Severity
 	if flagFailOn != "" {
@@ -64,26 +139,71 @@ func runExplain(cmd *cobra.Command, args []string) error {
 		threshold = &sev
 	}
 
-	// Load changes
-	var changes []parse.ResourceChange
-	var err error
+	var renderer render.Renderer
+	switch flagFormat {
+	case "text":
+		renderer = render.TextRenderer{}
+	case "json":
+		renderer = render.JSONRenderer{}
+	default:
+		renderer = render.MarkdownRenderer{}
+	}
 
-	if hasPlan {
-		changes, err = parse.FromPlanBinary(flagFromPlan)
-	} else {
-		changes, err = parse.FromFile(args[0])
+	// Load plans
+	var plans []*parse.Plan
+	var stacks []render.Stack
+
+	switch {
+	case hasPlan:
+		// SIGINT/SIGTERM cancel the context, which kills terraform show
+		// and its process group instead of leaving it running.
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if flagTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, flagTimeout)
+			defer cancel()
+		}
+		var plan *parse.Plan
+		plan, err = parse.LoadPlanBinaryContext(ctx, flagBinary, flagFromPlan)
+		plans = []*parse.Plan{plan}
+	case args[0] == "-":
+		var plan *parse.Plan
+		plan, err = parse.Load(os.Stdin)
+		plans = []*parse.Plan{plan}
+	default:
+		plans, stacks, err = loadPlanFiles(args)
 	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		printLoadError(renderer, flagFormat, err)
 		os.Exit(1)
 	}
 
+	// Analyze each plan on its own so drift is matched within its stack,
+	// then combine
+	var changes []parse.ResourceChange
+	var drift []parse.ResourceDrift
+	var outputs []parse.OutputChange
+	var findings []analyze.Finding
+	for _, plan := range plans {
+		changes = append(changes, plan.ResourceChanges...)
+		drift = append(drift, plan.ResourceDrift...)
+		outputs = append(outputs, plan.OutputChanges...)
+		planFindings := analyze.AddBlastRadius(registry.Analyze(plan.ResourceChanges), graph.Build(plan))
+		findings = append(findings, planFindings...)
+		findings = append(findings, registry.AnalyzeDrift(plan.ResourceDrift, plan.ResourceChanges)...)
+		findings = append(findings, registry.AnalyzeOutputs(plan.OutputChanges)...)
+	}
+
+	// Set acknowledged findings aside; expired suppressions add findings
+	findings, acknowledged := analyze.SplitSuppressed(registry.Suppress(findings, suppressions, time.Now()))
+
+	// Findings already in the baseline do not count toward the threshold
+	findings = baseline.Mark(findings)
+
 	// Compute counts
 	counts := parse.ComputeCounts(changes)
 
-	// Analyze
-	findings := analyze.Analyze(changes)
-
 	// Determine exit code
 	exitCode := 0
 	if threshold != nil && analyze.ExceedsThreshold(findings, *threshold) {
@@ -92,24 +212,78 @@ func runExplain(cmd *cobra.Command, args []string) error {
 
 	// Render
 	result := render.Result{
-		Counts:    counts,
-		Changes:   changes,
-		Findings:  findings,
-		Threshold: threshold,
-		ExitCode:  exitCode,
+		Counts:       counts,
+		OutputCounts: parse.ComputeOutputCounts(outputs),
+		Changes:      changes,
+		Drift:        drift,
+		Outputs:      outputs,
+		Findings:     findings,
+		Acknowledged: acknowledged,
+		Threshold:    threshold,
+		ExitCode:     exitCode,
+		GroupBy:      flagGroupBy,
+		Stacks:       stacks,
 	}
-
-	var renderer render.Renderer
-	switch flagFormat {
-	case "text":
-		renderer = render.TextRenderer{}
-	case "json":
-		renderer = render.JSONRenderer{}
-	default:
-		renderer = render.MarkdownRenderer{}
+	if len(plans) == 1 {
+		result.Metadata = plans[0].Metadata
 	}
 
 	fmt.Print(renderer.Render(result))
 	os.Exit(exitCode)
 	return nil
 }
+
+// loadPlanFiles expands args into plan files and loads them. A single file
+// is returned untagged; several are tagged with their stack names, which are
+// also returned in order.
+func loadPlanFiles(args []string) ([]*parse.Plan, []render.Stack, error) {
+	paths, err := parse.ExpandPlanPaths(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(paths) == 1 {
+		plan, err := parse.LoadFile(paths[0])
+		return []*parse.Plan{plan}, nil, err
+	}
+
+	names := parse.StackNames(paths)
+	plans := make([]*parse.Plan, 0, len(paths))
+	stacks := make([]render.Stack, 0, len(paths))
+	for i, path := range paths {
+		plan, err := parse.LoadFile(path)
+		if err != nil {
+			return nil, nil, &parse.SourceError{Path: path, Err: err}
+		}
+		plan.TagStack(names[i])
+		plans = append(plans, plan)
+		stacks = append(stacks, render.Stack{Name: names[i], Metadata: plan.Metadata})
+	}
+	return plans, stacks, nil
+}
+
+// loadBaseline reads the finding fingerprints from a previous run's JSON
+// output.
+func loadBaseline(path string) (analyze.Baseline, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	baseline, err := render.ReadBaseline(f)
+	if err != nil {
+		return nil, fmt.Errorf("baseline %s: %w", path, err)
+	}
+	return baseline, nil
+}
+
+// printLoadError renders an error loading a plan in the output format. JSON
+// goes to stdout so CI consumers of --format json still get a parseable
+// document; md and text go to stderr, so an error never ends up in a report
+// redirected to a file or posted as a comment.
+func printLoadError(renderer render.Renderer, format string, err error) {
+	out := os.Stderr
+	if format == "json" {
+		out = os.Stdout
+	}
+	fmt.Fprint(out, renderer.RenderError(err))
+}
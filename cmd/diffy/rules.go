@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var rulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "List the rules Diffy runs",
+	Long: `List every registered rule with its ID, severity, status and what it
+flags, after applying the configuration file. Rule IDs are stable and appear
+on each finding, so they can be used to enable, disable or suppress a rule.`,
+	Args: cobra.NoArgs,
+	RunE: runRules,
+}
+
+func init() {
+	rootCmd.AddCommand(rulesCmd)
+}
+
+func runRules(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	registry, err := configuredRegistry(cfg)
+	if err != nil {
+		return err
+	}
+	rules := registry.Rules()
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID() < rules[j].ID() })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSEVERITY\tSTATUS\tDESCRIPTION")
+	for _, rule := range rules {
+		status := "enabled"
+		if !registry.Enabled(rule.ID()) {
+			status = "disabled"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", rule.ID(), strings.ToUpper(registry.Severity(rule.ID()).String()), status, rule.Description())
+	}
+	return w.Flush()
+}
@@ -0,0 +1,16 @@
+package analyze
+
+import "github.com/sgr0691/diffy/internal/graph"
+
+// AddBlastRadius records on each replacement and deletion finding the
+// resources in g that depend on the destroyed object. Findings are updated
+// in place and returned.
+func AddBlastRadius(findings []Finding, g *graph.Graph) []Finding {
+	for i, f := range findings {
+		if f.RuleID != RuleDeletion && f.RuleID != RuleReplacement {
+			continue
+		}
+		findings[i].Evidence.BlastRadius = g.Dependents(f.Address)
+	}
+	return findings
+}
@@ -0,0 +1,135 @@
+package analyze
+
+import "github.com/sgr0691/diffy/internal/parse"
+
+// Built-in rule IDs. IDs are stable across releases so they can be used in
+// configuration and suppressions; a retired rule's ID is not reused.
+const (
+	RuleDeletion               = "DIFFY-CORE-001"
+	RuleReplacement            = "DIFFY-CORE-002"
+	RuleDeferred               = "DIFFY-CORE-003"
+	RuleMove                   = "DIFFY-CORE-004"
+	RuleImport                 = "DIFFY-CORE-005"
+	RuleForget                 = "DIFFY-CORE-006"
+	RuleDeferredRead           = "DIFFY-CORE-007"
+	RuleUnknownAction          = "DIFFY-CORE-008"
+	RulePreventDestroyDisabled = "DIFFY-CORE-009"
+	RuleIgnoredSecurityChanges = "DIFFY-CORE-010"
+	RuleTagOnly                = "DIFFY-CORE-011"
+	RuleIndexShift             = "DIFFY-CORE-012"
+	RuleSuppressionExpired     = "DIFFY-CORE-013"
+	RuleProtectionRemoved      = "DIFFY-CORE-014"
+
+	RulePublicIngress      = "DIFFY-AWS-001"
+	RuleInternetFacingLB   = "DIFFY-AWS-002"
+	RulePublicIP           = "DIFFY-AWS-003"
+	RuleIAMAttachment      = "DIFFY-AWS-004"
+	RuleIAMPolicyDocument  = "DIFFY-AWS-005"
+	RuleStatefulUpdate     = "DIFFY-AWS-006"
+	RuleNetworkRouting     = "DIFFY-AWS-007"
+	RuleSecurityGroupDrift = "DIFFY-DRIFT-001"
+	RuleStatefulDrift      = "DIFFY-DRIFT-002"
+	RuleDriftReverted      = "DIFFY-DRIFT-003"
+	RuleOutputRemoved      = "DIFFY-OUTPUT-001"
+	RuleOutputUnsensitive  = "DIFFY-OUTPUT-002"
+	RuleOutputSensitive    = "DIFFY-OUTPUT-003"
+	RuleOutputTypeChanged  = "DIFFY-OUTPUT-004"
+)
+
+// builtinRules returns Diffy's own rules in the order their findings are
+// reported for a single change.
+func builtinRules() []Rule {
+	return []Rule{
+		NewRule(RuleDeferred, SeverityMedium, "Change is deferred to a later plan and run", changeRule(matchDeferred)),
+		NewRule(RuleMove, SeverityInfo, "Resource moves to a new address with no other changes", changeRule(matchMove)),
+		NewRule(RuleImport, SeverityInfo, "Existing object is imported into state", changeRule(matchImport)),
+		NewRule(RuleForget, SeverityMedium, "Resource is removed from state but keeps running", changeRule(matchForget)),
+		NewRule(RuleDeferredRead, SeverityLow, "Data source is read during apply", changeRule(matchDeferredRead)),
+		NewRule(RuleUnknownAction, SeverityMedium, "Plan action Diffy does not recognize", changeRule(matchUnknownAction)),
+		NewRule(RuleReplacement, SeverityHigh, "Resource is destroyed and recreated", objectRuleWith(matchReplacement)),
+		NewRule(RuleDeletion, SeverityHigh, "Resource is deleted", objectRuleWith(matchDeletion)),
+		NewRule(RulePreventDestroyDisabled, SeverityCritical, "Resource with prevent_destroy explicitly set to false is deleted or replaced", objectRule(matchPreventDestroyDisabled)),
+		NewRule(RuleProtectionRemoved, SeverityCritical, "Stateful resource is deleted with its resource block, and any prevent_destroy on it", objectRuleWith(matchProtectionRemoved)),
+		NewRule(RuleIgnoredSecurityChanges, SeverityMedium, "lifecycle.ignore_changes covers access or encryption settings", objectRule(matchIgnoredSecurityChanges)),
+		NewRule(RulePublicIngress, SeverityHigh, "Security group allows ingress from the internet on common ports", objectRuleWith(matchPublicIngress)),
+		NewRule(RuleInternetFacingLB, SeverityHigh, "Load balancer is internet-facing", objectRule(matchInternetFacingLB)),
+		NewRule(RulePublicIP, SeverityHigh, "Public IP association is enabled", objectRule(matchPublicIP)),
+		NewRule(RuleIAMAttachment, SeverityHigh, "IAM policy attachment is created or changed", objectRule(matchIAMAttachment)),
+		NewRule(RuleIAMPolicyDocument, SeverityHigh, "IAM policy document is created or changed", objectRule(matchIAMPolicyDocument)),
+		NewRule(RuleTagOnly, SeverityLow, "Update only changes tags", objectRule(matchTagOnly)),
+		NewRule(RuleStatefulUpdate, SeverityMedium, "Stateful resource has storage, engine or encryption updates", objectRuleWith(matchStatefulUpdate)),
+		NewRule(RuleNetworkRouting, SeverityMedium, "Routes or gateways change", objectRule(matchNetworkRouting)),
+		indexShiftRule{},
+		// Reported by Registry.Suppress rather than matched against inputs.
+		NewRule(RuleSuppressionExpired, SeverityMedium, "Suppression has passed its expiry date", func(Input) []Finding { return nil }),
+		NewRule(RuleSecurityGroupDrift, SeverityHigh, "Security group was changed outside of Terraform", driftRule(matchSecurityGroupDrift)),
+		NewRule(RuleStatefulDrift, SeverityMedium, "Stateful resource was changed outside of Terraform", driftRule(matchStatefulDrift)),
+		NewRule(RuleDriftReverted, SeverityMedium, "Plan reverts a change made outside of Terraform", driftRule(matchDriftReverted)),
+		NewRule(RuleOutputRemoved, SeverityHigh, "Output is removed", outputRule(matchOutputRemoved)),
+		NewRule(RuleOutputUnsensitive, SeverityHigh, "Output is no longer marked sensitive", outputRule(matchOutputUnsensitive)),
+		NewRule(RuleOutputSensitive, SeverityMedium, "Output becomes sensitive", outputRule(matchOutputSensitive)),
+		NewRule(RuleOutputTypeChanged, SeverityMedium, "Output value type changes", outputRule(matchOutputTypeChanged)),
+	}
+}
+
+// changeRule adapts a check of resource changes to a match function.
+func changeRule(check func(ch parse.ResourceChange) []Finding) func(in Input) []Finding {
+	return func(in Input) []Finding {
+		if in.Kind != InputChange {
+			return nil
+		}
+		return check(in.Change)
+	}
+}
+
+// objectRule is changeRule for checks that only apply to changes that
+// create, modify or destroy infrastructure. Moves, imports, forgets, reads
+// and unrecognized actions leave the object itself untouched, so those
+// checks would only add noise.
+func objectRule(check func(ch parse.ResourceChange) []Finding) func(in Input) []Finding {
+	return changeRule(func(ch parse.ResourceChange) []Finding {
+		if isStateOnly(ch.Action) {
+			return nil
+		}
+		return check(ch)
+	})
+}
+
+// objectRuleWith is objectRule for checks that depend on the registry's
+// Settings.
+func objectRuleWith(check func(ch parse.ResourceChange, s Settings) []Finding) func(in Input) []Finding {
+	return func(in Input) []Finding {
+		if in.Kind != InputChange || isStateOnly(in.Change.Action) {
+			return nil
+		}
+		return check(in.Change, in.Settings)
+	}
+}
+
+// driftRule adapts a check of drifted objects to a match function.
+func driftRule(check func(d parse.ResourceDrift, planned parse.Action, s Settings) []Finding) func(in Input) []Finding {
+	return func(in Input) []Finding {
+		if in.Kind != InputDrift {
+			return nil
+		}
+		return check(parse.ResourceDrift{ResourceChange: in.Change}, in.Planned, in.Settings)
+	}
+}
+
+// outputRule adapts a check of output changes to a match function.
+func outputRule(check func(o parse.OutputChange) []Finding) func(in Input) []Finding {
+	return func(in Input) []Finding {
+		if in.Kind != InputOutput {
+			return nil
+		}
+		return check(in.Output)
+	}
+}
+
+func isStateOnly(action parse.Action) bool {
+	switch action {
+	case parse.ActionMove, parse.ActionImport, parse.ActionForget, parse.ActionRead, parse.ActionUnknown:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,559 @@
+package analyze
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/sgr0691/diffy/internal/parse"
+)
+
+// Operator is how a custom rule condition tests an attribute.
+type Operator string
+
+const (
+	// OpEquals holds when the value equals the condition's value.
+	OpEquals Operator = "equals"
+	// OpChanged holds when the value differs between before and after.
+	OpChanged Operator = "changed"
+	// OpIncreased holds when a number is larger after than before.
+	OpIncreased Operator = "increased"
+	// OpDecreased holds when a number is smaller after than before.
+	OpDecreased Operator = "decreased"
+	// OpContains holds when a string contains the condition's value as a
+	// substring, or a list contains it as an element.
+	OpContains Operator = "contains"
+	// OpCIDRContains holds when a CIDR block, or any block of a list,
+	// contains the condition's address or block, e.g. "0.0.0.0/0" contains
+	// "203.0.113.7".
+	OpCIDRContains Operator = "cidr_contains"
+)
+
+// Operators lists the condition operators.
+var Operators = []Operator{OpEquals, OpChanged, OpIncreased, OpDecreased, OpContains, OpCIDRContains}
+
+// takesValue reports whether the operator compares against the condition's
+// value rather than before against after.
+func (op Operator) takesValue() bool {
+	return op == OpEquals || op == OpContains || op == OpCIDRContains
+}
+
+// RuleSpec declares a rule without Go code, e.g. in the configuration file.
+// A change matches when its type, action and change paths match and every
+// condition holds.
+type RuleSpec struct {
+	ID       string
+	Severity Severity
+	// Title and Description are text/template templates executed with a
+	// RuleData, e.g. "{{.Address}} shortens its deletion window to
+	// {{.After "deletion_window_in_days"}} days".
+	Title       string
+	Description string
+	// Types are globs over resource types, e.g. "aws_kms_*". Empty matches
+	// every type.
+	Types []string
+	// Actions are the actions the rule applies to. Empty means create,
+	// update, replace and delete.
+	Actions []parse.Action
+	// Paths are globs over change paths, e.g. "ingress[*].cidr_blocks*".
+	// When set, at least one change path must match.
+	Paths      []string
+	Conditions []Condition
+}
+
+// Condition tests the values at an attribute path.
+type Condition struct {
+	// Path is an attribute path in change path notation, where "*" matches
+	// any key or index, e.g. "ingress[*].cidr_blocks". When it resolves to
+	// several values, the condition holds if it holds for any of them.
+	Path string
+	Op   Operator
+	// Value is the operand of equals, contains and cidr_contains.
+	Value any
+	// Before makes equals, contains and cidr_contains test the value
+	// before the change instead of after it.
+	Before bool
+}
+
+// RuleData is what custom rule templates are executed with.
+type RuleData struct {
+	Address string
+	Type    string
+	Name    string
+	Action  parse.Action
+	Stack   string
+	// ChangePaths are the change paths the rule matched on, or all of them
+	// when the rule has no path globs.
+	ChangePaths []string
+
+	before, after any
+}
+
+// Before returns the value at path before the change, formatted for
+// display, e.g. `{{.Before "engine_version"}}`.
+func (d RuleData) Before(path string) string {
+	return formatValues(d.before, path)
+}
+
+// After returns the value at path after the change.
+func (d RuleData) After(path string) string {
+	return formatValues(d.after, path)
+}
+
+// NewCustomRule compiles a rule declaration into a Rule.
+func NewCustomRule(spec RuleSpec) (Rule, error) {
+	if !ruleIDPattern.MatchString(spec.ID) {
+		return nil, fmt.Errorf("invalid rule ID %q: use upper-case words joined by hyphens, e.g. ACME-NET-001", spec.ID)
+	}
+	r := &customRule{spec: spec}
+	if len(r.spec.Actions) == 0 {
+		r.spec.Actions = []parse.Action{parse.ActionCreate, parse.ActionUpdate, parse.ActionReplace, parse.ActionDelete}
+	}
+
+	var err error
+	if r.title, err = parseRuleTemplate("title", spec.Title); err != nil {
+		return nil, err
+	}
+	if r.description, err = parseRuleTemplate("description", spec.Description); err != nil {
+		return nil, err
+	}
+
+	for i, c := range spec.Conditions {
+		cc := compiledCondition{Condition: c}
+		if cc.path, err = parseAttributePath(c.Path); err != nil {
+			return nil, fmt.Errorf("conditions[%d].path: %w", i, err)
+		}
+		if !slices.Contains(Operators, c.Op) {
+			return nil, fmt.Errorf("conditions[%d].op: unknown operator %q", i, c.Op)
+		}
+		switch {
+		case c.Op.takesValue() && c.Value == nil:
+			return nil, fmt.Errorf("conditions[%d]: %s needs a value", i, c.Op)
+		case !c.Op.takesValue() && (c.Value != nil || c.Before):
+			return nil, fmt.Errorf("conditions[%d]: %s compares before and after and takes no value", i, c.Op)
+		}
+		if cc.Value, err = normalizeValue(c.Value); err != nil {
+			return nil, fmt.Errorf("conditions[%d].value: %w", i, err)
+		}
+		if c.Op == OpCIDRContains {
+			s, ok := cc.Value.(string)
+			if !ok {
+				return nil, fmt.Errorf("conditions[%d].value: cidr_contains needs an address or CIDR block, got %v", i, c.Value)
+			}
+			if cc.prefix, ok = parsePrefix(s); !ok {
+				return nil, fmt.Errorf("conditions[%d].value: %q is not an address or CIDR block", i, s)
+			}
+		}
+		r.conditions = append(r.conditions, cc)
+	}
+	return r, nil
+}
+
+type customRule struct {
+	spec        RuleSpec
+	title       *template.Template
+	description *template.Template
+	conditions  []compiledCondition
+}
+
+type compiledCondition struct {
+	Condition
+	path   []pathSegment
+	prefix netip.Prefix
+}
+
+func (r *customRule) ID() string                { return r.spec.ID }
+func (r *customRule) DefaultSeverity() Severity { return r.spec.Severity }
+func (r *customRule) Description() string       { return r.summary() }
+
+// summary describes in one line what the rule matches, e.g. "aws_kms_key is
+// updated or replaced where deletion_window_in_days decreased". The title
+// and description templates only make sense for a matched change.
+func (r *customRule) summary() string {
+	subject := "Resource"
+	if len(r.spec.Types) > 0 {
+		subject = strings.Join(r.spec.Types, " or ")
+	}
+	verbs := make([]string, len(r.spec.Actions))
+	for i, action := range r.spec.Actions {
+		verbs[i] = actionParticiple(action)
+	}
+	s := subject + " is " + joinOr(verbs)
+	if len(r.spec.Paths) > 0 {
+		s += " with changes to " + joinOr(r.spec.Paths)
+	}
+	conds := make([]string, len(r.spec.Conditions))
+	for i, c := range r.spec.Conditions {
+		conds[i] = c.summary()
+	}
+	if len(conds) > 0 {
+		s += " where " + strings.Join(conds, " and ")
+	}
+	return s
+}
+
+// summary describes the condition, e.g. `cidr_blocks contains "0.0.0.0/0"`.
+func (c Condition) summary() string {
+	path := c.Path
+	if c.Before {
+		path += " before the change"
+	}
+	if !c.Op.takesValue() {
+		return path + " " + string(c.Op)
+	}
+	value, err := json.Marshal(c.Value)
+	if err != nil {
+		value = []byte(fmt.Sprint(c.Value))
+	}
+	op := string(c.Op)
+	if c.Op == OpCIDRContains {
+		op = "has a CIDR block containing"
+	}
+	return path + " " + op + " " + string(value)
+}
+
+func actionParticiple(action parse.Action) string {
+	switch action {
+	case parse.ActionCreate:
+		return "created"
+	case parse.ActionUpdate:
+		return "updated"
+	case parse.ActionReplace:
+		return "replaced"
+	case parse.ActionDelete:
+		return "deleted"
+	}
+	return string(action)
+}
+
+// joinOr joins words as "a, b or c".
+func joinOr(words []string) string {
+	if len(words) < 2 {
+		return strings.Join(words, "")
+	}
+	return strings.Join(words[:len(words)-1], ", ") + " or " + words[len(words)-1]
+}
+
+func (r *customRule) Match(in Input) []Finding {
+	ch := in.Change
+	if in.Kind != InputChange || !slices.Contains(r.spec.Actions, ch.Action) {
+		return nil
+	}
+	if len(r.spec.Types) > 0 && !slices.ContainsFunc(r.spec.Types, func(t string) bool { return matchGlob(t, ch.Type) }) {
+		return nil
+	}
+
+	changePaths := ch.ChangePaths
+	if len(r.spec.Paths) > 0 {
+		changePaths = nil
+		for _, p := range ch.ChangePaths {
+			if slices.ContainsFunc(r.spec.Paths, func(g string) bool { return matchGlob(g, p) }) {
+				changePaths = append(changePaths, p)
+			}
+		}
+		if len(changePaths) == 0 {
+			return nil
+		}
+	}
+
+	before, after := decodeAny(ch.Before), decodeAny(ch.After)
+	var matches []string
+	for _, c := range r.conditions {
+		evidence, ok := c.test(before, after)
+		if !ok {
+			return nil
+		}
+		matches = append(matches, evidence...)
+	}
+
+	data := RuleData{
+		Address:     ch.Address,
+		Type:        ch.Type,
+		Action:      ch.Action,
+		Stack:       ch.Stack,
+		ChangePaths: changePaths,
+		before:      before,
+		after:       after,
+	}
+	if addr, err := parse.ParseAddress(ch.Address); err == nil {
+		data.Name = addr.Name
+	}
+	return []Finding{newFinding(
+		r.spec.Severity,
+		executeRuleTemplate(r.title, data),
+		executeRuleTemplate(r.description, data),
+		ch,
+		changePaths,
+		matches,
+	)}
+}
+
+// test reports whether the condition holds, with evidence such as
+// "deletion_window_in_days: 30 → 7".
+func (c compiledCondition) test(before, after any) ([]string, bool) {
+	var evidence []string
+	if c.Op.takesValue() {
+		side := after
+		if c.Before {
+			side = before
+		}
+		for _, v := range resolvePath(side, c.path, "") {
+			if c.holds(v.value) {
+				evidence = append(evidence, v.path+"="+formatValue(v.value))
+			}
+		}
+		return evidence, len(evidence) > 0
+	}
+
+	befores := make(map[string]any)
+	for _, v := range resolvePath(before, c.path, "") {
+		befores[v.path] = v.value
+	}
+	afters := make(map[string]any)
+	for _, v := range resolvePath(after, c.path, "") {
+		afters[v.path] = v.value
+	}
+	paths := make([]string, 0, len(befores)+len(afters))
+	for p := range befores {
+		paths = append(paths, p)
+	}
+	for p := range afters {
+		if _, ok := befores[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		b, a := befores[p], afters[p]
+		var ok bool
+		switch c.Op {
+		case OpChanged:
+			ok = !reflect.DeepEqual(b, a)
+		case OpIncreased, OpDecreased:
+			bn, bok := b.(float64)
+			an, aok := a.(float64)
+			ok = bok && aok && (c.Op == OpIncreased && an > bn || c.Op == OpDecreased && an < bn)
+		}
+		if ok {
+			evidence = append(evidence, fmt.Sprintf("%s: %s → %s", p, formatValue(b), formatValue(a)))
+		}
+	}
+	return evidence, len(evidence) > 0
+}
+
+func (c compiledCondition) holds(v any) bool {
+	switch c.Op {
+	case OpEquals:
+		return reflect.DeepEqual(v, c.Value)
+	case OpContains:
+		switch v := v.(type) {
+		case string:
+			s, ok := c.Value.(string)
+			return ok && strings.Contains(v, s)
+		case []any:
+			return slices.ContainsFunc(v, func(e any) bool { return reflect.DeepEqual(e, c.Value) })
+		}
+	case OpCIDRContains:
+		blocks, ok := v.([]any)
+		if !ok {
+			blocks = []any{v}
+		}
+		for _, b := range blocks {
+			s, ok := b.(string)
+			if !ok {
+				continue
+			}
+			if block, ok := parsePrefix(s); ok && block.Bits() <= c.prefix.Bits() && block.Contains(c.prefix.Addr()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parsePrefix parses a CIDR block, or an address as a single-address block.
+func parsePrefix(s string) (netip.Prefix, bool) {
+	if p, err := netip.ParsePrefix(s); err == nil {
+		return p.Masked(), true
+	}
+	if a, err := netip.ParseAddr(s); err == nil {
+		return netip.PrefixFrom(a, a.BitLen()), true
+	}
+	return netip.Prefix{}, false
+}
+
+// pathSegment is a map key or list index of an attribute path; "*"
+// matches any.
+type pathSegment struct {
+	key   string
+	index bool
+}
+
+// parseAttributePath splits a path such as "ingress[*].cidr_blocks" into
+// segments.
+func parseAttributePath(path string) ([]pathSegment, error) {
+	if path == "" {
+		return nil, fmt.Errorf("must not be empty")
+	}
+	var segs []pathSegment
+	for _, part := range strings.Split(path, ".") {
+		key, rest, _ := strings.Cut(part, "[")
+		if key == "" {
+			return nil, fmt.Errorf("%q has an empty attribute name", path)
+		}
+		segs = append(segs, pathSegment{key: key})
+		for rest != "" {
+			index, after, ok := strings.Cut(rest, "]")
+			if _, err := strconv.Atoi(index); !ok || (index != "*" && err != nil) {
+				return nil, fmt.Errorf("%q has an invalid index; use [0] or [*]", path)
+			}
+			segs = append(segs, pathSegment{key: index, index: true})
+			if after != "" && !strings.HasPrefix(after, "[") {
+				return nil, fmt.Errorf("%q has an invalid index; use [0] or [*]", path)
+			}
+			rest = strings.TrimPrefix(after, "[")
+		}
+	}
+	return segs, nil
+}
+
+type pathValue struct {
+	path  string
+	value any
+}
+
+// resolvePath returns the values at path in v, with their concrete paths.
+func resolvePath(v any, segs []pathSegment, prefix string) []pathValue {
+	if len(segs) == 0 {
+		return []pathValue{{path: prefix, value: v}}
+	}
+	seg, rest := segs[0], segs[1:]
+	var out []pathValue
+	if seg.index {
+		list, ok := v.([]any)
+		if !ok {
+			return nil
+		}
+		for i, e := range list {
+			if seg.key == "*" || seg.key == strconv.Itoa(i) {
+				out = append(out, resolvePath(e, rest, fmt.Sprintf("%s[%d]", prefix, i))...)
+			}
+		}
+		return out
+	}
+
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil
+	}
+	keys := []string{seg.key}
+	if seg.key == "*" {
+		keys = make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+	}
+	for _, k := range keys {
+		e, ok := m[k]
+		if !ok {
+			continue
+		}
+		p := k
+		if prefix != "" {
+			p = prefix + "." + k
+		}
+		out = append(out, resolvePath(e, rest, p)...)
+	}
+	return out
+}
+
+// normalizeValue converts a condition value to what JSON decoding yields,
+// e.g. float64 for every number, so it compares equal to plan values.
+func normalizeValue(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compare against %v", v)
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// formatValues formats the values at path in v, joined by commas, or "null"
+// when there are none.
+func formatValues(v any, path string) string {
+	segs, err := parseAttributePath(path)
+	if err != nil {
+		return "null"
+	}
+	values := resolvePath(v, segs, "")
+	if len(values) == 0 {
+		return "null"
+	}
+	parts := make([]string, len(values))
+	for i, pv := range values {
+		parts[i] = formatValue(pv.value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+func parseRuleTemplate(name, text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("%s: must not be empty", name)
+	}
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, templateError(name, err))
+	}
+	// Catch unknown fields and methods now rather than on the first match.
+	if err := t.Execute(new(strings.Builder), RuleData{}); err != nil {
+		return nil, fmt.Errorf("%s: %s", name, templateError(name, err))
+	}
+	return t, nil
+}
+
+// templateError strips the template name and position from err, which the
+// caller reports with its own location.
+func templateError(name string, err error) string {
+	msg := strings.TrimPrefix(err.Error(), "template: "+name+":")
+	if _, rest, ok := strings.Cut(msg, ": "); ok {
+		return rest
+	}
+	return msg
+}
+
+func executeRuleTemplate(t *template.Template, data RuleData) string {
+	var sb strings.Builder
+	if err := t.Execute(&sb, data); err != nil {
+		return t.Root.String()
+	}
+	return sb.String()
+}
@@ -0,0 +1,66 @@
+package analyze
+
+import (
+	"fmt"
+
+	"github.com/sgr0691/diffy/internal/parse"
+)
+
+var securityGroupTypes = map[string]bool{
+	"aws_security_group":                  true,
+	"aws_security_group_rule":             true,
+	"aws_vpc_security_group_ingress_rule": true,
+	"aws_vpc_security_group_egress_rule":  true,
+}
+
+// AnalyzeDrift runs the built-in drift rules against objects that changed
+// outside of Terraform. Planned changes are used to tell whether the plan
+// will revert a manual change.
+func AnalyzeDrift(drift []parse.ResourceDrift, changes []parse.ResourceChange) []Finding {
+	return defaultRegistry.AnalyzeDrift(drift, changes)
+}
+
+func matchSecurityGroupDrift(d parse.ResourceDrift, _ parse.Action, _ Settings) []Finding {
+	if !securityGroupTypes[d.Type] {
+		return nil
+	}
+	return []Finding{newDriftFinding(
+		SeverityHigh,
+		"Security group modified out-of-band",
+		fmt.Sprintf("Security group %s was changed outside of Terraform. Manual rule changes may have opened or closed access that the plan does not show.", d.Address),
+		d,
+		filterPaths(d.ChangePaths, []string{"ingress", "egress", "cidr", "port", "protocol"}),
+	)}
+}
+
+func matchStatefulDrift(d parse.ResourceDrift, _ parse.Action, s Settings) []Finding {
+	if !s.isStateful(d.Type) {
+		return nil
+	}
+	sev := SeverityMedium
+	desc := fmt.Sprintf("Stateful resource %s was changed outside of Terraform.", d.Address)
+	if d.Action == parse.ActionDelete {
+		sev = SeverityHigh
+		desc = fmt.Sprintf("Stateful resource %s was deleted outside of Terraform. The plan may recreate it empty.", d.Address)
+	}
+	return []Finding{newDriftFinding(sev, "Stateful resource drifted", desc, d, d.ChangePaths)}
+}
+
+func matchDriftReverted(d parse.ResourceDrift, planned parse.Action, _ Settings) []Finding {
+	if d.Action != parse.ActionUpdate || (planned != parse.ActionUpdate && planned != parse.ActionReplace) {
+		return nil
+	}
+	return []Finding{newDriftFinding(
+		SeverityMedium,
+		"Plan reverts out-of-band change",
+		fmt.Sprintf("Resource %s drifted outside of Terraform and this plan will %s it, reverting the manual change.", d.Address, planned),
+		d,
+		d.ChangePaths,
+	)}
+}
+
+func newDriftFinding(severity Severity, title, description string, d parse.ResourceDrift, changePaths []string) Finding {
+	f := newFinding(severity, title, description, d.ResourceChange, changePaths, nil)
+	f.Evidence.Drift = true
+	return f
+}
@@ -1,20 +1,61 @@
 package analyze
 
-import "github.com/sgr0691/diffy/internal/parse"
+import (
+	"github.com/sgr0691/diffy/internal/graph"
+	"github.com/sgr0691/diffy/internal/parse"
+)
+
+// Confidence describes how certain Diffy is that a finding reflects a real risk.
+type Confidence string
+
+const (
+	// ConfidenceConfirmed findings are backed by values known at plan time.
+	ConfidenceConfirmed Confidence = "confirmed"
+	// ConfidenceUnverified findings concern values that are known only after
+	// apply, so the risk can be neither confirmed nor ruled out.
+	ConfidenceUnverified Confidence = "unverified"
+)
 
 // Finding represents a single risk finding from the analysis.
 type Finding struct {
-	Severity    Severity `json:"severity"`
-	Title       string   `json:"title"`
-	Description string   `json:"description"`
-	Address     string   `json:"resource_address"`
-	Evidence    Evidence `json:"evidence"`
+	// RuleID is the ID of the rule that reported the finding.
+	RuleID string `json:"rule_id"`
+	// Fingerprint identifies the finding across runs; see Fingerprint.
+	Fingerprint string     `json:"fingerprint"`
+	Severity    Severity   `json:"severity"`
+	Confidence  Confidence `json:"confidence,omitempty"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Address     string     `json:"resource_address"`
+	// Stack is the source plan's stack when several plans are explained together.
+	Stack    string   `json:"stack,omitempty"`
+	Evidence Evidence `json:"evidence"`
+	// Collapsed holds the per-resource findings this one summarizes, e.g.
+	// the replacements caused by a count index shift.
+	Collapsed []Finding `json:"collapsed,omitempty"`
+	// Suppression is the suppression that acknowledges the finding, or nil
+	// when the finding is in effect.
+	Suppression *Suppression `json:"suppression,omitempty"`
+	// InBaseline is set when the finding is in the baseline given with
+	// --baseline; such findings do not count toward the threshold.
+	InBaseline bool `json:"in_baseline,omitempty"`
 }
 
 // Evidence captures supporting data for a finding.
 type Evidence struct {
-	Action       parse.Action `json:"action"`
-	ResourceType string       `json:"resource_type"`
-	ChangePaths  []string     `json:"change_paths,omitempty"`
-	Matches      []string     `json:"matches,omitempty"`
+	Action          parse.Action `json:"action"`
+	ResourceType    string       `json:"resource_type"`
+	PreviousAddress string       `json:"previous_address,omitempty"`
+	ChangePaths     []string     `json:"change_paths,omitempty"`
+	Matches         []string     `json:"matches,omitempty"`
+	ForcedBy        []string     `json:"forced_by,omitempty"`
+	ActionReason    string       `json:"action_reason,omitempty"`
+	ReplaceOrder    string       `json:"replace_order,omitempty"`
+	Drift           bool         `json:"drift,omitempty"`
+	// BlastRadius lists the resources that depend on a replaced or deleted
+	// resource.
+	BlastRadius []graph.Dependent `json:"blast_radius,omitempty"`
+	// IndexShifts lists the count instances that took another index's
+	// values, e.g. "[1] ← [2]".
+	IndexShifts []string `json:"index_shifts,omitempty"`
 }
@@ -0,0 +1,62 @@
+package analyze
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+)
+
+// Fingerprint identifies a finding across runs. It is derived from the rule
+// ID, the stack and address, and the evidence of why the rule fired: the
+// action, resource type, previous address, replacement ordering, drift flag,
+// matches and forced-by paths, with lists sorted. Severity, wording, change
+// paths and blast radius are left out, as they change with configuration or
+// with edits elsewhere in the plan.
+func Fingerprint(f Finding) string {
+	h := sha256.New()
+	for _, part := range []string{
+		f.RuleID,
+		f.Stack,
+		f.Address,
+		string(f.Evidence.Action),
+		f.Evidence.ResourceType,
+		f.Evidence.PreviousAddress,
+		f.Evidence.ReplaceOrder,
+		boolString(f.Evidence.Drift),
+		normalizedList(f.Evidence.Matches),
+		normalizedList(f.Evidence.ForcedBy),
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// Baseline holds the fingerprints of known findings, e.g. those of a
+// previous run on a stack that already carries findings.
+type Baseline map[string]bool
+
+// Mark flags the findings whose fingerprint is in the baseline. Findings are
+// updated in place and returned.
+func (b Baseline) Mark(findings []Finding) []Finding {
+	for i, f := range findings {
+		if b[f.Fingerprint] {
+			findings[i].InBaseline = true
+		}
+	}
+	return findings
+}
+
+func normalizedList(items []string) string {
+	sorted := slices.Clone(items)
+	slices.Sort(sorted)
+	return strings.Join(slices.Compact(sorted), "\x1f")
+}
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
@@ -0,0 +1,266 @@
+package analyze
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sgr0691/diffy/internal/parse"
+)
+
+// titleIndexShift is the finding that collapses the changes caused by
+// elements of a count list moving to new indices.
+const titleIndexShift = "Count index shift detected"
+
+// countInstance is one changed instance of a count resource.
+type countInstance struct {
+	index  int
+	change parse.ResourceChange
+	before map[string]any
+	after  map[string]any
+}
+
+// indexShiftRule collapses the findings of count instances that changed only
+// because their index moved.
+type indexShiftRule struct{}
+
+func (indexShiftRule) ID() string                { return RuleIndexShift }
+func (indexShiftRule) DefaultSeverity() Severity { return SeverityMedium }
+func (indexShiftRule) Description() string {
+	return "Count list elements shift to new indices, changing unrelated instances"
+}
+func (indexShiftRule) Match(Input) []Finding { return nil }
+
+func (indexShiftRule) Collapse(changes []parse.ResourceChange, findings []Finding) []Finding {
+	return collapseIndexShifts(changes, findings)
+}
+
+// collapseIndexShifts looks for count resources whose instances were
+// changed because list elements moved to new indices, e.g. after removing
+// an element from the middle of the list. Each such resource gets a single
+// finding, and the findings for its instances are collapsed under it.
+func collapseIndexShifts(changes []parse.ResourceChange, findings []Finding) []Finding {
+	groups := make(map[string][]countInstance)
+	var order []string
+	for _, ch := range changes {
+		if ch.Deferred() {
+			continue
+		}
+		addr, err := parse.ParseAddress(ch.Address)
+		if err != nil {
+			continue
+		}
+		idx, err := strconv.Atoi(addr.Key)
+		if err != nil {
+			continue // not a count instance
+		}
+		key := ch.Stack + "\x00" + addr.Resource()
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		before, _ := decodeAny(ch.Before).(map[string]any)
+		after, _ := decodeAny(ch.After).(map[string]any)
+		groups[key] = append(groups[key], countInstance{index: idx, change: ch, before: before, after: after})
+	}
+
+	for _, key := range order {
+		instances := groups[key]
+		if len(instances) < 2 {
+			continue
+		}
+		offset, pairs := detectIndexShift(instances)
+		if len(pairs) == 0 {
+			continue
+		}
+		_, base, _ := strings.Cut(key, "\x00")
+		findings = collapseGroup(findings, base, instances, offset, pairs)
+	}
+	return findings
+}
+
+// detectIndexShift returns the most common offset k such that an
+// instance's new values are the old values of the instance k places
+// further along, and the [new, old] index pairs that agree with it.
+func detectIndexShift(instances []countInstance) (int, [][2]int) {
+	byIndex := make(map[int]countInstance, len(instances))
+	for _, in := range instances {
+		byIndex[in.index] = in
+	}
+
+	votes := make(map[int][][2]int)
+	for _, in := range instances {
+		if in.after == nil {
+			continue
+		}
+		for _, other := range instances {
+			if other.index == in.index || other.before == nil {
+				continue
+			}
+			if takesValuesOf(in, other.before) {
+				k := other.index - in.index
+				votes[k] = append(votes[k], [2]int{in.index, other.index})
+			}
+		}
+	}
+
+	// Prefer the offset with most pairs, then the smallest, then a
+	// removal over an addition, so ties are decided the same way each run.
+	offsets := make([]int, 0, len(votes))
+	for k := range votes {
+		offsets = append(offsets, k)
+	}
+	sort.Ints(offsets)
+	best := 0
+	for _, k := range offsets {
+		pairs := votes[k]
+		if best == 0 || len(pairs) > len(votes[best]) ||
+			(len(pairs) == len(votes[best]) && (abs(k) < abs(best) || abs(k) == abs(best) && k > 0)) {
+			best = k
+		}
+	}
+	pairs := votes[best]
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	return best, pairs
+}
+
+// takesValuesOf reports whether every attribute the plan changes on in,
+// and whose new value is known, takes the value before has.
+func takesValuesOf(in countInstance, before map[string]any) bool {
+	unknown := make(map[string]bool)
+	for _, p := range in.change.UnknownPaths {
+		unknown[topLevelPath(p)] = true
+	}
+	compared := 0
+	seen := make(map[string]bool)
+	for _, p := range in.change.ChangePaths {
+		attr := topLevelPath(p)
+		if seen[attr] || unknown[attr] {
+			continue
+		}
+		seen[attr] = true
+		value, ok := in.after[attr]
+		if !ok || value == nil {
+			continue
+		}
+		if !reflect.DeepEqual(value, before[attr]) {
+			return false
+		}
+		compared++
+	}
+	return compared > 0
+}
+
+// shiftedInstances returns the instances changed by the shift: those that
+// took another index's values, and the trailing instances destroyed when
+// an element is removed or created when one is added. Other instances of
+// the resource changed for their own reasons.
+func shiftedInstances(instances []countInstance, offset int, pairs [][2]int) []countInstance {
+	shifted := make(map[int]bool, len(pairs))
+	last := 0
+	for _, p := range pairs {
+		shifted[p[0]] = true
+		last = max(last, p[0])
+	}
+	trailing := parse.ActionDelete
+	if offset < 0 {
+		trailing = parse.ActionCreate
+	}
+	var out []countInstance
+	for _, in := range instances {
+		if shifted[in.index] || (in.index > last && in.change.Action == trailing) {
+			out = append(out, in)
+		}
+	}
+	return out
+}
+
+// collapseGroup replaces the findings of a shifted count resource's
+// instances with one index shift finding, placed where the first of them
+// was. Findings on instances the shift did not change stay as they are.
+func collapseGroup(findings []Finding, base string, instances []countInstance, offset int, pairs [][2]int) []Finding {
+	stack := instances[0].change.Stack
+	instances = shiftedInstances(instances, offset, pairs)
+	inGroup := make(map[string]bool, len(instances))
+	for _, in := range instances {
+		inGroup[in.change.Address] = true
+	}
+
+	var collapsed []Finding
+	out := make([]Finding, 0, len(findings))
+	at := -1
+	for _, f := range findings {
+		if f.Stack == stack && inGroup[f.Address] && !f.Evidence.Drift {
+			if at < 0 {
+				at = len(out)
+			}
+			collapsed = append(collapsed, f)
+			continue
+		}
+		out = append(out, f)
+	}
+	if at < 0 {
+		at = len(out)
+	}
+
+	actions := make(map[parse.Action]int)
+	for _, in := range instances {
+		actions[in.change.Action]++
+	}
+	action := parse.ActionUpdate
+	switch {
+	case actions[parse.ActionReplace] > 0:
+		action = parse.ActionReplace
+	case actions[parse.ActionDelete] > 0:
+		action = parse.ActionDelete
+	}
+
+	verb := "Removing"
+	if offset < 0 {
+		verb = "Adding"
+	}
+	var summary []string
+	for _, a := range []parse.Action{parse.ActionUpdate, parse.ActionReplace, parse.ActionCreate, parse.ActionDelete} {
+		if n := actions[a]; n > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", n, a))
+		}
+	}
+	shifts := make([]string, len(pairs))
+	for i, p := range pairs {
+		shifts[i] = fmt.Sprintf("[%d] ← [%d]", p[0], p[1])
+	}
+
+	severity := SeverityMedium
+	if len(collapsed) > 0 && MaxSeverity(collapsed) > severity {
+		severity = MaxSeverity(collapsed)
+	}
+	f := Finding{
+		RuleID:     RuleIndexShift,
+		Severity:   severity,
+		Confidence: ConfidenceConfirmed,
+		Title:      titleIndexShift,
+		Description: fmt.Sprintf("%s an element of %s's count list shifted %d instances to new indices (%s), so objects are changed or destroyed only because their index moved. Use for_each with stable keys, or moved blocks, so unchanged elements keep their addresses.",
+			verb, base, len(pairs), strings.Join(summary, ", ")),
+		Address:   base,
+		Stack:     stack,
+		Collapsed: collapsed,
+		Evidence: Evidence{
+			Action:       action,
+			ResourceType: instances[0].change.Type,
+			IndexShifts:  shifts,
+		},
+	}
+
+	out = append(out, Finding{})
+	copy(out[at+1:], out[at:])
+	out[at] = f
+	return out
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
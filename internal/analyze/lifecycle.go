@@ -0,0 +1,125 @@
+package analyze
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sgr0691/diffy/internal/parse"
+)
+
+// securityAttributes are attributes whose changes matter for access or
+// encryption. Ignoring them with lifecycle.ignore_changes hides those
+// changes from every future plan.
+var securityAttributes = map[string]bool{
+	"acl":                                  true,
+	"assume_role_policy":                   true,
+	"associate_public_ip_address":          true,
+	"cidr_blocks":                          true,
+	"deletion_protection":                  true,
+	"egress":                               true,
+	"encrypted":                            true,
+	"iam_instance_profile":                 true,
+	"ingress":                              true,
+	"inline_policy":                        true,
+	"internal":                             true,
+	"ipv6_cidr_blocks":                     true,
+	"kms_key_id":                           true,
+	"map_public_ip_on_launch":              true,
+	"policy":                               true,
+	"publicly_accessible":                  true,
+	"security_groups":                      true,
+	"server_side_encryption_configuration": true,
+	"storage_encrypted":                    true,
+	"vpc_security_group_ids":               true,
+}
+
+// matchPreventDestroyDisabled flags deletions and replacements of resources
+// whose prevent_destroy is explicitly false. Protection removed together
+// with the resource block is matchProtectionRemoved's concern.
+func matchPreventDestroyDisabled(ch parse.ResourceChange) []Finding {
+	if ch.Lifecycle == nil || !ch.Lifecycle.PreventDestroyDisabled() {
+		return nil
+	}
+	verb := "deleted"
+	switch ch.Action {
+	case parse.ActionDelete:
+	case parse.ActionReplace:
+		verb = "replaced"
+	default:
+		return nil
+	}
+	return []Finding{newFinding(
+		SeverityCritical,
+		"Deletion protection explicitly disabled",
+		fmt.Sprintf("Resource %s has lifecycle.prevent_destroy explicitly set to false and will be %s by this plan. Confirm that turning the protection off was intended.", ch.Address, verb),
+		ch,
+		ch.ChangePaths,
+		[]string{"prevent_destroy=false"},
+	)}
+}
+
+// matchProtectionRemoved flags deletions of stateful resources whose block,
+// or module call, was removed from the configuration. Any prevent_destroy
+// on the block went with it, which is the usual way protection disappears
+// in the same change as a delete. A plan records only the new
+// configuration, so whether the block was protected cannot be known and
+// the finding says so.
+func matchProtectionRemoved(ch parse.ResourceChange, s Settings) []Finding {
+	if ch.Action != parse.ActionDelete || !s.isStateful(ch.Type) {
+		return nil
+	}
+	var removed string
+	switch ch.ActionReason {
+	case parse.ReasonNoResourceConfig:
+		removed = "its resource block was removed from the configuration"
+	case parse.ReasonNoModule:
+		removed = "the module call containing it was removed from the configuration"
+	default:
+		return nil
+	}
+	return []Finding{newFinding(
+		SeverityCritical,
+		"Deletion protection may have been removed",
+		fmt.Sprintf("Stateful resource %s will be deleted because %s. Any lifecycle.prevent_destroy on the block was removed with it; the plan does not record the previous configuration, so Diffy cannot tell whether the resource was protected. Confirm that deleting it was intended.", ch.Address, removed),
+		ch,
+		ch.ChangePaths,
+		[]string{ch.ActionReason},
+	)}
+}
+
+func matchIgnoredSecurityChanges(ch parse.ResourceChange) []Finding {
+	if ch.Lifecycle == nil || ch.Action == parse.ActionDelete {
+		return nil
+	}
+	ignored := ignoredSecurityAttributes(ch.Lifecycle.IgnoreChanges)
+	if len(ignored) == 0 {
+		return nil
+	}
+	what := "changes to " + strings.Join(ignored, ", ")
+	if ignored[0] == parse.IgnoreAll {
+		what = "all changes"
+	}
+	return []Finding{newFinding(
+		SeverityMedium,
+		"Security-relevant changes ignored",
+		fmt.Sprintf("Resource %s ignores %s through lifecycle.ignore_changes. Changes to its access or encryption settings will not show up in plans or be corrected.", ch.Address, what),
+		ch,
+		nil,
+		ignored,
+	)}
+}
+
+// ignoredSecurityAttributes returns the ignore_changes entries that cover a
+// security attribute, or just IgnoreAll when everything is ignored.
+func ignoredSecurityAttributes(ignoreChanges []string) []string {
+	var out []string
+	for _, path := range ignoreChanges {
+		if path == parse.IgnoreAll {
+			return []string{parse.IgnoreAll}
+		}
+		if securityAttributes[topLevelPath(path)] {
+			out = append(out, path)
+		}
+	}
+	return out
+}
@@ -0,0 +1,85 @@
+package analyze
+
+import (
+	"fmt"
+
+	"github.com/sgr0691/diffy/internal/parse"
+)
+
+// outputResourceType is the evidence resource type of output findings.
+const outputResourceType = "output"
+
+// AnalyzeOutputs runs the built-in output rules against planned output
+// changes. Outputs are read by other stacks through remote state, so
+// removing one or changing its shape or sensitivity can break consumers
+// this plan cannot see.
+func AnalyzeOutputs(outputs []parse.OutputChange) []Finding {
+	return defaultRegistry.AnalyzeOutputs(outputs)
+}
+
+func matchOutputRemoved(o parse.OutputChange) []Finding {
+	if o.Action != parse.ActionDelete {
+		return nil
+	}
+	return []Finding{newOutputFinding(
+		SeverityHigh,
+		"Output removed",
+		fmt.Sprintf("Output %s will be removed. Stacks that read it through remote state will fail or receive null.", o.Name),
+		o,
+	)}
+}
+
+func matchOutputUnsensitive(o parse.OutputChange) []Finding {
+	if o.Action != parse.ActionUpdate || !o.BeforeSensitive || o.AfterSensitive {
+		return nil
+	}
+	return []Finding{newOutputFinding(
+		SeverityHigh,
+		"Output no longer sensitive",
+		fmt.Sprintf("Output %s will no longer be marked sensitive. Its value will be shown in plan output and to consumers without redaction.", o.Name),
+		o,
+	)}
+}
+
+func matchOutputSensitive(o parse.OutputChange) []Finding {
+	if o.Action != parse.ActionUpdate || o.BeforeSensitive || !o.AfterSensitive {
+		return nil
+	}
+	return []Finding{newOutputFinding(
+		SeverityMedium,
+		"Output became sensitive",
+		fmt.Sprintf("Output %s will be marked sensitive. Consumers that use it in non-sensitive contexts, such as their own outputs, will fail until they handle the sensitivity.", o.Name),
+		o,
+	)}
+}
+
+func matchOutputTypeChanged(o parse.OutputChange) []Finding {
+	before, after := o.BeforeType(), o.AfterType()
+	if o.Action != parse.ActionUpdate || before == "" || after == "" || before == after ||
+		before == parse.ValueTypeNull || after == parse.ValueTypeNull {
+		return nil
+	}
+	f := newOutputFinding(
+		SeverityMedium,
+		"Output type changed",
+		fmt.Sprintf("Output %s changes from %s to %s. Consumers that read it through remote state may break.", o.Name, before, after),
+		o,
+	)
+	f.Evidence.Matches = []string{"before=" + before, "after=" + after}
+	return []Finding{f}
+}
+
+func newOutputFinding(severity Severity, title, description string, o parse.OutputChange) Finding {
+	return Finding{
+		Severity:    severity,
+		Confidence:  ConfidenceConfirmed,
+		Title:       title,
+		Description: description,
+		Address:     o.Address(),
+		Stack:       o.Stack,
+		Evidence: Evidence{
+			Action:       o.Action,
+			ResourceType: outputResourceType,
+		},
+	}
+}
@@ -0,0 +1,297 @@
+package analyze
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sgr0691/diffy/internal/parse"
+)
+
+// InputKind tells a rule what it is looking at.
+type InputKind int
+
+const (
+	// InputChange is a planned resource change.
+	InputChange InputKind = iota
+	// InputDrift is a change made outside of Terraform, recorded in the
+	// plan's resource_drift.
+	InputDrift
+	// InputOutput is a planned output change.
+	InputOutput
+)
+
+// Input is one object a rule is matched against.
+type Input struct {
+	Kind InputKind
+	// Change is the resource change for InputChange, or the drifted object
+	// for InputDrift.
+	Change parse.ResourceChange
+	// Planned is, for InputDrift, the action this plan takes on the drifted
+	// object, or "" when it takes none.
+	Planned parse.Action
+	// Output is the output change for InputOutput.
+	Output parse.OutputChange
+	// Settings are the registry's settings.
+	Settings Settings
+}
+
+// Settings extend what the built-in rules know about, e.g. an
+// organization's own stateful resource types.
+type Settings struct {
+	// StatefulTypes are resource types treated as stateful in addition to
+	// the built-in list. A trailing "*" matches a prefix, e.g.
+	// "aws_opensearch_*".
+	StatefulTypes []string
+	// PublicPorts are ports public ingress is flagged on in addition to
+	// the commonly targeted ones.
+	PublicPorts []int
+}
+
+func (s Settings) isStateful(resourceType string) bool {
+	if isStateful(resourceType) {
+		return true
+	}
+	for _, t := range s.StatefulTypes {
+		if prefix, ok := strings.CutSuffix(t, "*"); ok && strings.HasPrefix(resourceType, prefix) {
+			return true
+		}
+		if t == resourceType {
+			return true
+		}
+	}
+	return false
+}
+
+// Rule is a single check. Match returns the rule's findings for one input,
+// or nil when the rule does not apply to it; the registry fills in the
+// findings' RuleID and Fingerprint.
+type Rule interface {
+	// ID is the rule's stable identifier, e.g. "DIFFY-AWS-001".
+	ID() string
+	// DefaultSeverity is the severity the rule usually reports. Individual
+	// findings may be more or less severe, e.g. deletions of stateful
+	// resources are critical.
+	DefaultSeverity() Severity
+	// Description says in one line what the rule flags.
+	Description() string
+	Match(in Input) []Finding
+}
+
+// NewRule returns a Rule backed by a match function.
+func NewRule(id string, severity Severity, description string, match func(in Input) []Finding) Rule {
+	return funcRule{id: id, severity: severity, description: description, match: match}
+}
+
+type funcRule struct {
+	id          string
+	severity    Severity
+	description string
+	match       func(in Input) []Finding
+}
+
+func (r funcRule) ID() string                { return r.id }
+func (r funcRule) DefaultSeverity() Severity { return r.severity }
+func (r funcRule) Description() string       { return r.description }
+func (r funcRule) Match(in Input) []Finding  { return r.match(in) }
+
+// findingCollapser is implemented by rules that work on the findings of a
+// whole plan rather than one input at a time. Collapse runs after every
+// input has been matched.
+type findingCollapser interface {
+	Collapse(changes []parse.ResourceChange, findings []Finding) []Finding
+}
+
+var ruleIDPattern = regexp.MustCompile(`^[A-Z][A-Z0-9]*(-[A-Z0-9]+)+$`)
+
+// Registry holds the rules Diffy runs. Rules run in registration order, so
+// findings for the same input keep a stable order.
+type Registry struct {
+	rules    []Rule
+	byID     map[string]Rule
+	disabled map[string]bool
+	// severity holds per-rule severity overrides.
+	severity map[string]Severity
+	settings Settings
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		byID:     make(map[string]Rule),
+		disabled: make(map[string]bool),
+		severity: make(map[string]Severity),
+	}
+}
+
+// DefaultRegistry returns a new registry holding the built-in rules, all
+// enabled. Each call returns an independent registry.
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+	for _, rule := range builtinRules() {
+		if err := r.Register(rule); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// defaultRegistry backs the package-level Analyze functions.
+var defaultRegistry = DefaultRegistry()
+
+// Register adds an enabled rule. IDs are upper-case words joined by
+// hyphens, e.g. "ACME-NET-001", and must be unique.
+func (r *Registry) Register(rule Rule) error {
+	id := rule.ID()
+	if !ruleIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid rule ID %q: use upper-case words joined by hyphens, e.g. ACME-NET-001", id)
+	}
+	if _, ok := r.byID[id]; ok {
+		return fmt.Errorf("rule %s is already registered", id)
+	}
+	r.rules = append(r.rules, rule)
+	r.byID[id] = rule
+	return nil
+}
+
+// Lookup returns the rule with the given ID.
+func (r *Registry) Lookup(id string) (Rule, bool) {
+	rule, ok := r.byID[id]
+	return rule, ok
+}
+
+// Enable turns a rule back on.
+func (r *Registry) Enable(id string) error {
+	if _, ok := r.byID[id]; !ok {
+		return fmt.Errorf("unknown rule %q", id)
+	}
+	delete(r.disabled, id)
+	return nil
+}
+
+// Disable stops a rule from running.
+func (r *Registry) Disable(id string) error {
+	if _, ok := r.byID[id]; !ok {
+		return fmt.Errorf("unknown rule %q", id)
+	}
+	r.disabled[id] = true
+	return nil
+}
+
+// Enabled reports whether the rule with the given ID is registered and
+// enabled.
+func (r *Registry) Enabled(id string) bool {
+	_, ok := r.byID[id]
+	return ok && !r.disabled[id]
+}
+
+// SetSeverity makes every finding of a rule report the given severity,
+// whatever the rule itself decides.
+func (r *Registry) SetSeverity(id string, severity Severity) error {
+	if _, ok := r.byID[id]; !ok {
+		return fmt.Errorf("unknown rule %q", id)
+	}
+	r.severity[id] = severity
+	return nil
+}
+
+// Severity returns the severity a rule reports with: its override, or its
+// default severity.
+func (r *Registry) Severity(id string) Severity {
+	if sev, ok := r.severity[id]; ok {
+		return sev
+	}
+	if rule, ok := r.byID[id]; ok {
+		return rule.DefaultSeverity()
+	}
+	return SeverityInfo
+}
+
+// SetSettings replaces the settings passed to rules.
+func (r *Registry) SetSettings(s Settings) {
+	r.settings = s
+}
+
+// Rules returns every registered rule, enabled or not, in registration
+// order.
+func (r *Registry) Rules() []Rule {
+	return append([]Rule(nil), r.rules...)
+}
+
+// Analyze runs the enabled rules against resource changes.
+func (r *Registry) Analyze(changes []parse.ResourceChange) []Finding {
+	var findings []Finding
+	for _, ch := range changes {
+		findings = append(findings, r.match(Input{Kind: InputChange, Change: ch})...)
+	}
+	for _, rule := range r.enabled() {
+		c, ok := rule.(findingCollapser)
+		if !ok {
+			continue
+		}
+		findings = c.Collapse(changes, findings)
+		sev, override := r.severity[rule.ID()]
+		for i := range findings {
+			if findings[i].RuleID != rule.ID() {
+				continue
+			}
+			if override {
+				findings[i].Severity = sev
+			}
+			findings[i].Fingerprint = Fingerprint(findings[i])
+		}
+	}
+	return findings
+}
+
+// AnalyzeDrift runs the enabled rules against objects that changed outside
+// of Terraform. Planned changes are used to tell whether the plan will
+// revert a manual change.
+func (r *Registry) AnalyzeDrift(drift []parse.ResourceDrift, changes []parse.ResourceChange) []Finding {
+	planned := make(map[string]parse.Action, len(changes))
+	for _, ch := range changes {
+		planned[ch.Address] = ch.Action
+	}
+
+	var findings []Finding
+	for _, d := range drift {
+		findings = append(findings, r.match(Input{Kind: InputDrift, Change: d.ResourceChange, Planned: planned[d.Address]})...)
+	}
+	return findings
+}
+
+// AnalyzeOutputs runs the enabled rules against planned output changes.
+func (r *Registry) AnalyzeOutputs(outputs []parse.OutputChange) []Finding {
+	var findings []Finding
+	for _, o := range outputs {
+		findings = append(findings, r.match(Input{Kind: InputOutput, Output: o})...)
+	}
+	return findings
+}
+
+func (r *Registry) match(in Input) []Finding {
+	in.Settings = r.settings
+	var findings []Finding
+	for _, rule := range r.enabled() {
+		sev, override := r.severity[rule.ID()]
+		for _, f := range rule.Match(in) {
+			f.RuleID = rule.ID()
+			f.Fingerprint = Fingerprint(f)
+			if override {
+				f.Severity = sev
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+func (r *Registry) enabled() []Rule {
+	out := make([]Rule, 0, len(r.rules))
+	for _, rule := range r.rules {
+		if !r.disabled[rule.ID()] {
+			out = append(out, rule)
+		}
+	}
+	return out
+}
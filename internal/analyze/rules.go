@@ -11,6 +11,12 @@ import (
 	"github.com/sgr0691/diffy/internal/parse"
 )
 
+// Titles of the findings that destroy an object.
+const (
+	titleDeletion    = "Resource deletion detected"
+	titleReplacement = "Resource replacement detected"
+)
+
 // statefulTypes is the starter list of AWS stateful resource types where
 // deletes are especially dangerous.
 var statefulTypes = map[string]bool{
@@ -43,6 +49,8 @@ var iamPolicyDocTypes = map[string]bool{
 
 var publicCommonPorts = []int{22, 80, 443, 3389, 3306, 5432, 6379}
 
+var publicIPFields = []string{"associate_public_ip_address", "map_public_ip_on_launch", "associate_carrier_ip_address"}
+
 var statefulImpactfulPathHints = []string{
 	"allocated_storage",
 	"storage_type",
@@ -79,45 +87,154 @@ func isStateful(resourceType string) bool {
 	return false
 }
 
-// Analyze runs all v0.1 rules against the given resource changes and returns findings.
+// Analyze runs the built-in rules against the given resource changes and
+// returns findings.
 func Analyze(changes []parse.ResourceChange) []Finding {
-	var findings []Finding
+	return defaultRegistry.Analyze(changes)
+}
 
-	for _, ch := range changes {
-		findings = append(findings, analyzeChange(ch)...)
+func matchDeferred(ch parse.ResourceChange) []Finding {
+	if !ch.Deferred() {
+		return nil
 	}
+	return []Finding{newFinding(
+		SeverityMedium,
+		"Change deferred to a later plan",
+		fmt.Sprintf("Planned %s of %s is deferred (%s) and will not be applied by this run. Another plan and apply are needed to converge.", ch.Action, ch.Address, ch.DeferredReason),
+		ch,
+		ch.ChangePaths,
+		[]string{"deferred=" + ch.DeferredReason},
+	)}
+}
 
-	return findings
+func matchMove(ch parse.ResourceChange) []Finding {
+	if ch.Action != parse.ActionMove {
+		return nil
+	}
+	return []Finding{newFinding(
+		SeverityInfo,
+		"Resource moved",
+		fmt.Sprintf("Resource %s moves to %s with no other changes.", ch.PreviousAddress, ch.Address),
+		ch,
+		nil,
+		nil,
+	)}
 }
 
-func analyzeChange(ch parse.ResourceChange) []Finding {
-	var findings []Finding
+func matchImport(ch parse.ResourceChange) []Finding {
+	if ch.Action != parse.ActionImport {
+		return nil
+	}
+	return []Finding{newFinding(
+		SeverityInfo,
+		"Resource will be imported",
+		fmt.Sprintf("Existing object %s will be imported into state as %s with no other changes.", ch.ImportID, ch.Address),
+		ch,
+		nil,
+		[]string{"import_id=" + ch.ImportID},
+	)}
+}
 
-	switch ch.Action {
-	case parse.ActionReplace:
-		sev := SeverityHigh
-		desc := fmt.Sprintf("Resource %s will be replaced (destroyed and recreated). This may cause downtime or data loss.", ch.Address)
-		if isStateful(ch.Type) {
-			sev = SeverityCritical
-			desc = fmt.Sprintf("Stateful resource %s will be replaced (destroyed and recreated). This will likely cause data loss.", ch.Address)
-		}
-		findings = append(findings, newFinding(sev, "Resource replacement detected", desc, ch, ch.ChangePaths, nil))
-
-	case parse.ActionDelete:
-		sev := SeverityHigh
-		desc := fmt.Sprintf("Resource %s will be deleted.", ch.Address)
-		if isStateful(ch.Type) {
-			sev = SeverityCritical
-			desc = fmt.Sprintf("Stateful resource %s will be deleted. This will likely cause data loss.", ch.Address)
-		}
-		findings = append(findings, newFinding(sev, "Resource deletion detected", desc, ch, ch.ChangePaths, nil))
+func matchForget(ch parse.ResourceChange) []Finding {
+	if ch.Action != parse.ActionForget {
+		return nil
+	}
+	return []Finding{newFinding(
+		SeverityMedium,
+		"Resource will be removed from state but not destroyed",
+		fmt.Sprintf("Resource %s will be forgotten: Terraform stops managing it, but the real object keeps running and must be cleaned up or adopted elsewhere.", ch.Address),
+		ch,
+		nil,
+		nil,
+	)}
+}
+
+func matchDeferredRead(ch parse.ResourceChange) []Finding {
+	if ch.Action != parse.ActionRead {
+		return nil
+	}
+	return []Finding{newFinding(
+		SeverityLow,
+		"Data source read deferred to apply",
+		fmt.Sprintf("Data source %s will be read during apply, so values that depend on it are unknown in this plan.", ch.Address),
+		ch,
+		nil,
+		nil,
+	)}
+}
+
+func matchUnknownAction(ch parse.ResourceChange) []Finding {
+	if ch.Action != parse.ActionUnknown {
+		return nil
+	}
+	return []Finding{newFinding(
+		SeverityMedium,
+		"Unrecognized plan action",
+		fmt.Sprintf("Resource %s has actions Diffy does not recognize. Review this change manually.", ch.Address),
+		ch,
+		ch.ChangePaths,
+		ch.Actions,
+	)}
+}
+
+func matchDeletion(ch parse.ResourceChange, s Settings) []Finding {
+	if ch.Action != parse.ActionDelete {
+		return nil
+	}
+	sev := SeverityHigh
+	desc := fmt.Sprintf("Resource %s will be deleted.", ch.Address)
+	if s.isStateful(ch.Type) {
+		sev = SeverityCritical
+		desc = fmt.Sprintf("Stateful resource %s will be deleted. This will likely cause data loss.", ch.Address)
 	}
+	return []Finding{newFinding(sev, titleDeletion, desc, ch, ch.ChangePaths, nil)}
+}
 
-	findings = append(findings, analyzePublicExposure(ch)...)
-	findings = append(findings, analyzeIAM(ch)...)
-	findings = append(findings, analyzeUpdateRisk(ch)...)
+func matchReplacement(ch parse.ResourceChange, s Settings) []Finding {
+	if ch.Action != parse.ActionReplace {
+		return nil
+	}
+	return []Finding{replacementFinding(ch, s)}
+}
+
+// replacementFinding explains a replacement, including why Terraform chose
+// to replace rather than update when the plan records it.
+func replacementFinding(ch parse.ResourceChange, s Settings) Finding {
+	// Destroy-before-create (Terraform's default) leaves a gap with no
+	// object; create-before-destroy only overlaps two of them, which is far
+	// less disruptive unless the resource holds data.
+	cbd := ch.ReplaceOrder == parse.ReplaceCreateBeforeDestroy
+	sev := SeverityHigh
+	subject := "Resource"
+	consequence := "This may cause downtime or data loss."
+	if cbd {
+		sev = SeverityMedium
+		consequence = "The replacement is created before the existing object is destroyed, which limits downtime."
+	}
+	if s.isStateful(ch.Type) {
+		sev = SeverityCritical
+		subject = "Stateful resource"
+		consequence = "This will likely cause data loss."
+	}
+
+	var desc string
+	switch ch.ActionReason {
+	case parse.ReasonTainted:
+		desc = fmt.Sprintf("%s %s is tainted and will be replaced (destroyed and recreated). %s", subject, ch.Address, consequence)
+	case parse.ReasonByRequest:
+		desc = fmt.Sprintf("%s %s will be replaced because replacement was explicitly requested (-replace). %s", subject, ch.Address, consequence)
+	default:
+		desc = fmt.Sprintf("%s %s will be replaced (destroyed and recreated). %s", subject, ch.Address, consequence)
+	}
+	if ch.Moved() {
+		desc += fmt.Sprintf(" It is also moved from %s; the moved block does not prevent the replacement.", ch.PreviousAddress)
+	}
 
-	return findings
+	f := newFinding(sev, titleReplacement, desc, ch, ch.ChangePaths, nil)
+	f.Evidence.ForcedBy = ch.ReplacePaths
+	f.Evidence.ActionReason = ch.ActionReason
+	f.Evidence.ReplaceOrder = ch.ReplaceOrder
+	return f
 }
 
 // MaxSeverity returns the highest severity among findings, or SeverityInfo if empty.
@@ -131,156 +248,208 @@ func MaxSeverity(findings []Finding) Severity {
 	return max
 }
 
-// ExceedsThreshold returns true if any finding meets or exceeds the given threshold.
+// ExceedsThreshold returns true if any finding meets or exceeds the given
+// threshold. Suppressed findings are not counted.
 func ExceedsThreshold(findings []Finding, threshold Severity) bool {
 	for _, f := range findings {
-		if f.Severity >= threshold {
+		if f.Suppression == nil && !f.InBaseline && f.Severity >= threshold {
 			return true
 		}
 	}
 	return false
 }
 
-func analyzePublicExposure(ch parse.ResourceChange) []Finding {
-	if ch.Action != parse.ActionCreate && ch.Action != parse.ActionUpdate && ch.Action != parse.ActionReplace {
+// isExposable reports whether the change leaves an object whose exposure
+// can be checked.
+func isExposable(action parse.Action) bool {
+	return action == parse.ActionCreate || action == parse.ActionUpdate || action == parse.ActionReplace
+}
+
+func matchPublicIngress(ch parse.ResourceChange, s Settings) []Finding {
+	if !isExposable(ch.Action) {
 		return nil
 	}
-
-	var findings []Finding
-
-	if matches := findPublicIngress(ch); len(matches) > 0 {
-		findings = append(findings, newFinding(
+	if ingressMatches := findPublicIngress(ch, s); len(ingressMatches) > 0 {
+		return []Finding{newFinding(
 			SeverityHigh,
 			"Public ingress exposure detected",
 			fmt.Sprintf("Resource %s allows ingress from public CIDR ranges on commonly targeted ports.", ch.Address),
 			ch,
 			filterPaths(ch.ChangePaths, []string{"ingress", "cidr", "port", "protocol", "security_group"}),
-			matches,
-		))
+			ingressMatches,
+		)}
 	}
+	if unknown := unknownIngressPaths(ch); len(unknown) > 0 {
+		return []Finding{newUnverifiedFinding(
+			"Public ingress cannot be verified",
+			fmt.Sprintf("Ingress rules of %s are known only after apply, so Diffy cannot verify whether they allow public access.", ch.Address),
+			ch,
+			unknown,
+		)}
+	}
+	return nil
+}
 
+func matchInternetFacingLB(ch parse.ResourceChange) []Finding {
+	if !isExposable(ch.Action) {
+		return nil
+	}
 	if isInternetFacingLB(ch) {
-		findings = append(findings, newFinding(
+		return []Finding{newFinding(
 			SeverityHigh,
 			"Internet-facing load balancer detected",
 			fmt.Sprintf("Load balancer %s is configured as internet-facing.", ch.Address),
 			ch,
 			filterPaths(ch.ChangePaths, []string{"scheme", "internal"}),
 			[]string{"scheme=internet-facing"},
-		))
+		)}
+	}
+	if !isLoadBalancer(ch.Type) {
+		return nil
 	}
+	if unknown := filterPaths(ch.UnknownPaths, []string{"scheme", "internal"}); len(unknown) > 0 {
+		return []Finding{newUnverifiedFinding(
+			"Load balancer exposure cannot be verified",
+			fmt.Sprintf("The scheme of load balancer %s is known only after apply, so Diffy cannot verify whether it is internet-facing.", ch.Address),
+			ch,
+			unknown,
+		)}
+	}
+	return nil
+}
 
+func matchPublicIP(ch parse.ResourceChange) []Finding {
+	if !isExposable(ch.Action) {
+		return nil
+	}
 	if hasPublicIPEnabled(ch) {
-		findings = append(findings, newFinding(
+		return []Finding{newFinding(
 			SeverityHigh,
 			"Public IP association enabled",
 			fmt.Sprintf("Resource %s enables public IP association.", ch.Address),
 			ch,
 			filterPaths(ch.ChangePaths, []string{"public_ip", "associate_public_ip_address", "map_public_ip_on_launch"}),
 			[]string{"public_ip=true"},
-		))
+		)}
 	}
+	if !strings.HasPrefix(ch.Type, "aws_") {
+		return nil
+	}
+	if unknown := filterPaths(ch.UnknownPaths, publicIPFields); len(unknown) > 0 {
+		return []Finding{newUnverifiedFinding(
+			"Public IP association cannot be verified",
+			fmt.Sprintf("Public IP settings of %s are known only after apply, so Diffy cannot verify whether a public IP will be associated.", ch.Address),
+			ch,
+			unknown,
+		)}
+	}
+	return nil
+}
 
-	return findings
+func matchIAMAttachment(ch parse.ResourceChange) []Finding {
+	if !iamAttachmentTypes[ch.Type] || ch.Action == parse.ActionDelete {
+		return nil
+	}
+	return []Finding{newFinding(
+		SeverityHigh,
+		"IAM policy attachment change detected",
+		fmt.Sprintf("IAM attachment resource %s is being created or modified.", ch.Address),
+		ch,
+		filterPaths(ch.ChangePaths, []string{"policy", "role", "group", "user"}),
+		[]string{ch.Type},
+	)}
 }
 
-func analyzeIAM(ch parse.ResourceChange) []Finding {
-	if !strings.HasPrefix(ch.Type, "aws_iam_") {
+func matchIAMPolicyDocument(ch parse.ResourceChange) []Finding {
+	if !strings.HasPrefix(ch.Type, "aws_iam_") || !isExposable(ch.Action) {
 		return nil
 	}
-	if ch.Action == parse.ActionDelete {
+	policyPaths := []string{"policy", "assume_role_policy", "inline_policy"}
+	if !iamPolicyDocTypes[ch.Type] && !hasPathHint(ch.ChangePaths, policyPaths) {
 		return nil
 	}
+	return []Finding{newFinding(
+		SeverityHigh,
+		"IAM policy document change detected",
+		fmt.Sprintf("Policy document fields changed for %s. Review policy scope carefully.", ch.Address),
+		ch,
+		filterPaths(ch.ChangePaths, policyPaths),
+		nil,
+	)}
+}
 
-	var findings []Finding
-
-	if iamAttachmentTypes[ch.Type] {
-		findings = append(findings, newFinding(
-			SeverityHigh,
-			"IAM policy attachment change detected",
-			fmt.Sprintf("IAM attachment resource %s is being created or modified.", ch.Address),
-			ch,
-			filterPaths(ch.ChangePaths, []string{"policy", "role", "group", "user"}),
-			[]string{ch.Type},
-		))
-	}
-
-	if iamPolicyDocTypes[ch.Type] || hasPathHint(ch.ChangePaths, []string{"policy", "assume_role_policy", "inline_policy"}) {
-		if ch.Action == parse.ActionCreate || ch.Action == parse.ActionUpdate || ch.Action == parse.ActionReplace {
-			findings = append(findings, newFinding(
-				SeverityHigh,
-				"IAM policy document change detected",
-				fmt.Sprintf("Policy document fields changed for %s. Review policy scope carefully.", ch.Address),
-				ch,
-				filterPaths(ch.ChangePaths, []string{"policy", "assume_role_policy", "inline_policy"}),
-				nil,
-			))
-		}
+func matchTagOnly(ch parse.ResourceChange) []Finding {
+	if ch.Action != parse.ActionUpdate || !isTagOnlyChange(ch.ChangePaths) {
+		return nil
 	}
-
-	return dedupeFindings(findings)
+	return []Finding{newFinding(
+		SeverityLow,
+		"Tag-only update detected",
+		fmt.Sprintf("Resource %s only changed tags.", ch.Address),
+		ch,
+		filterPaths(ch.ChangePaths, []string{"tags", "tags_all"}),
+		nil,
+	)}
 }
 
-func analyzeUpdateRisk(ch parse.ResourceChange) []Finding {
-	if ch.Action != parse.ActionUpdate && ch.Action != parse.ActionCreate && ch.Action != parse.ActionReplace {
+func matchStatefulUpdate(ch parse.ResourceChange, s Settings) []Finding {
+	if ch.Action != parse.ActionUpdate || !s.isStateful(ch.Type) {
 		return nil
 	}
-
-	var findings []Finding
-	if ch.Action == parse.ActionUpdate && isTagOnlyChange(ch.ChangePaths) {
-		findings = append(findings, newFinding(
-			SeverityLow,
-			"Tag-only update detected",
-			fmt.Sprintf("Resource %s only changed tags.", ch.Address),
-			ch,
-			filterPaths(ch.ChangePaths, []string{"tags", "tags_all"}),
-			nil,
-		))
-	}
-
-	if ch.Action == parse.ActionUpdate && isStateful(ch.Type) {
-		matched := filterPaths(ch.ChangePaths, statefulImpactfulPathHints)
-		if len(matched) > 0 {
-			findings = append(findings, newFinding(
-				SeverityMedium,
-				"Impactful stateful update detected",
-				fmt.Sprintf("Stateful resource %s has impactful configuration updates.", ch.Address),
-				ch,
-				matched,
-				nil,
-			))
-		}
+	matched := filterPaths(ch.ChangePaths, statefulImpactfulPathHints)
+	if len(matched) == 0 {
+		return nil
 	}
+	return []Finding{newFinding(
+		SeverityMedium,
+		"Impactful stateful update detected",
+		fmt.Sprintf("Stateful resource %s has impactful configuration updates.", ch.Address),
+		ch,
+		matched,
+		nil,
+	)}
+}
 
-	if isNetworkRoutingType(ch.Type) {
-		findings = append(findings, newFinding(
-			SeverityMedium,
-			"Network routing change detected",
-			fmt.Sprintf("Resource %s changes network routing/gateway behavior.", ch.Address),
-			ch,
-			ch.ChangePaths,
-			nil,
-		))
+func matchNetworkRouting(ch parse.ResourceChange) []Finding {
+	if !isExposable(ch.Action) || !isNetworkRoutingType(ch.Type) {
+		return nil
 	}
-
-	return dedupeFindings(findings)
+	return []Finding{newFinding(
+		SeverityMedium,
+		"Network routing change detected",
+		fmt.Sprintf("Resource %s changes network routing/gateway behavior.", ch.Address),
+		ch,
+		ch.ChangePaths,
+		nil,
+	)}
 }
 
 func newFinding(severity Severity, title, description string, ch parse.ResourceChange, changePaths, matches []string) Finding {
 	return Finding{
 		Severity:    severity,
+		Confidence:  ConfidenceConfirmed,
 		Title:       title,
 		Description: description,
 		Address:     ch.Address,
+		Stack:       ch.Stack,
 		Evidence: Evidence{
-			Action:       ch.Action,
-			ResourceType: ch.Type,
-			ChangePaths:  changePaths,
-			Matches:      matches,
+			Action:          ch.Action,
+			ResourceType:    ch.Type,
+			PreviousAddress: ch.PreviousAddress,
+			ChangePaths:     changePaths,
+			Matches:         matches,
 		},
 	}
 }
 
+// newUnverifiedFinding reports a risky attribute whose value is unknown until
+// apply. The unknown paths are both the evidence and the matches.
+func newUnverifiedFinding(title, description string, ch parse.ResourceChange, unknownPaths []string) Finding {
+	f := newFinding(SeverityMedium, title, description, ch, unknownPaths, nil)
+	f.Confidence = ConfidenceUnverified
+	return f
+}
+
 func decodeAny(raw json.RawMessage) any {
 	if len(raw) == 0 {
 		return nil
@@ -292,8 +461,12 @@ func decodeAny(raw json.RawMessage) any {
 	return v
 }
 
-func findPublicIngress(ch parse.ResourceChange) []string {
-	if ch.Type != "aws_security_group" && ch.Type != "aws_security_group_rule" && ch.Type != "aws_vpc_security_group_ingress_rule" {
+func isIngressType(resourceType string) bool {
+	return resourceType == "aws_security_group" || resourceType == "aws_security_group_rule" || resourceType == "aws_vpc_security_group_ingress_rule"
+}
+
+func findPublicIngress(ch parse.ResourceChange, s Settings) []string {
+	if !isIngressType(ch.Type) {
 		return nil
 	}
 
@@ -308,7 +481,7 @@ func findPublicIngress(ch parse.ResourceChange) []string {
 		if !rule.publicCIDR {
 			continue
 		}
-		if rule.protocolAll || portOverlapsCommon(rule.fromPort, rule.toPort) {
+		if rule.protocolAll || portOverlapsCommon(rule.fromPort, rule.toPort, s.PublicPorts) {
 			matches = append(matches, fmt.Sprintf("%s ports=%d-%d", strings.Join(rule.cidrs, ","), rule.fromPort, rule.toPort))
 		}
 	}
@@ -316,6 +489,33 @@ func findPublicIngress(ch parse.ResourceChange) []string {
 	return matches
 }
 
+// unknownIngressPaths returns the unknown-until-apply paths that decide
+// whether an ingress rule is public.
+func unknownIngressPaths(ch parse.ResourceChange) []string {
+	if !isIngressType(ch.Type) || len(ch.UnknownPaths) == 0 {
+		return nil
+	}
+	if ch.Type == "aws_security_group_rule" {
+		// Egress rules never expose anything; a rule whose type is itself
+		// unknown still might.
+		after, _ := decodeAny(ch.After).(map[string]any)
+		if t, _ := asString(after["type"]); t != "" && t != "ingress" {
+			return nil
+		}
+	}
+	paths := ch.UnknownPaths
+	if ch.Type == "aws_security_group" {
+		var ingress []string
+		for _, p := range paths {
+			if topLevelPath(p) == "ingress" {
+				ingress = append(ingress, p)
+			}
+		}
+		paths = ingress
+	}
+	return filterPaths(paths, []string{"ingress", "cidr", "port", "protocol"})
+}
+
 type ingressRule struct {
 	fromPort    int
 	toPort      int
@@ -415,20 +615,26 @@ func hasPublicCIDR(cidrs []string) bool {
 	return false
 }
 
-func portOverlapsCommon(from, to int) bool {
+func portOverlapsCommon(from, to int, extra []int) bool {
 	if from > to {
 		from, to = to, from
 	}
-	for _, p := range publicCommonPorts {
-		if p >= from && p <= to {
-			return true
+	for _, ports := range [][]int{publicCommonPorts, extra} {
+		for _, p := range ports {
+			if p >= from && p <= to {
+				return true
+			}
 		}
 	}
 	return false
 }
 
+func isLoadBalancer(resourceType string) bool {
+	return resourceType == "aws_lb" || resourceType == "aws_alb" || resourceType == "aws_elb"
+}
+
 func isInternetFacingLB(ch parse.ResourceChange) bool {
-	if ch.Type != "aws_lb" && ch.Type != "aws_alb" && ch.Type != "aws_elb" {
+	if !isLoadBalancer(ch.Type) {
 		return false
 	}
 	after, ok := decodeAny(ch.After).(map[string]any)
@@ -453,8 +659,7 @@ func hasPublicIPEnabled(ch parse.ResourceChange) bool {
 	if !strings.HasPrefix(ch.Type, "aws_") {
 		return false
 	}
-	keys := []string{"associate_public_ip_address", "map_public_ip_on_launch", "associate_carrier_ip_address"}
-	for _, key := range keys {
+	for _, key := range publicIPFields {
 		if hasTrueField(after, key) {
 			return true
 		}
@@ -544,23 +749,6 @@ func filterPaths(paths []string, hints []string) []string {
 	return out
 }
 
-func dedupeFindings(findings []Finding) []Finding {
-	if len(findings) < 2 {
-		return findings
-	}
-	seen := make(map[string]struct{}, len(findings))
-	out := make([]Finding, 0, len(findings))
-	for _, f := range findings {
-		key := f.Title + "|" + f.Address + "|" + f.Severity.String()
-		if _, ok := seen[key]; ok {
-			continue
-		}
-		seen[key] = struct{}{}
-		out = append(out, f)
-	}
-	return out
-}
-
 func asString(v any) (string, bool) {
 	s, ok := v.(string)
 	return s, ok
@@ -2,8 +2,13 @@ package analyze
 
 import (
 	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/sgr0691/diffy/internal/graph"
 	"github.com/sgr0691/diffy/internal/parse"
 )
 
@@ -81,6 +86,77 @@ func TestReplaceStatefulIsCritical(t *testing.T) {
 	}
 }
 
+func TestReplacementOrderingSeverity(t *testing.T) {
+	tests := []struct {
+		name         string
+		resourceType string
+		order        string
+		want         Severity
+	}{
+		{"stateless create-before-destroy", "aws_instance", parse.ReplaceCreateBeforeDestroy, SeverityMedium},
+		{"stateless destroy-before-create", "aws_instance", parse.ReplaceDestroyBeforeCreate, SeverityHigh},
+		{"stateful create-before-destroy", "aws_db_instance", parse.ReplaceCreateBeforeDestroy, SeverityCritical},
+		{"stateful destroy-before-create", "aws_db_instance", parse.ReplaceDestroyBeforeCreate, SeverityCritical},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := Analyze([]parse.ResourceChange{{
+				Address:      "test." + tt.resourceType,
+				Type:         tt.resourceType,
+				Action:       parse.ActionReplace,
+				ReplaceOrder: tt.order,
+			}})
+			if len(findings) != 1 {
+				t.Fatalf("expected 1 finding, got %d", len(findings))
+			}
+			if findings[0].Severity != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, findings[0].Severity)
+			}
+			if findings[0].Evidence.ReplaceOrder != tt.order {
+				t.Errorf("expected ordering %s in evidence, got %s", tt.order, findings[0].Evidence.ReplaceOrder)
+			}
+		})
+	}
+}
+
+func TestReplacementReasonWording(t *testing.T) {
+	tests := []struct {
+		name     string
+		reason   string
+		paths    []string
+		wantDesc string
+	}{
+		{"provider forced", parse.ReasonCannotUpdate, []string{"engine", "availability_zone"}, "will be replaced (destroyed and recreated)"},
+		{"tainted", parse.ReasonTainted, nil, "is tainted"},
+		{"requested", parse.ReasonByRequest, nil, "explicitly requested (-replace)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := Analyze([]parse.ResourceChange{{
+				Address:      "aws_db_instance.main",
+				Type:         "aws_db_instance",
+				Action:       parse.ActionReplace,
+				ReplacePaths: tt.paths,
+				ActionReason: tt.reason,
+			}})
+			if len(findings) != 1 {
+				t.Fatalf("expected 1 finding, got %d", len(findings))
+			}
+			f := findings[0]
+			if !strings.Contains(f.Description, tt.wantDesc) {
+				t.Errorf("expected description to contain %q, got %q", tt.wantDesc, f.Description)
+			}
+			if f.Evidence.ActionReason != tt.reason {
+				t.Errorf("expected action reason %s in evidence, got %s", tt.reason, f.Evidence.ActionReason)
+			}
+			if len(f.Evidence.ForcedBy) != len(tt.paths) {
+				t.Errorf("expected forced-by %v in evidence, got %v", tt.paths, f.Evidence.ForcedBy)
+			}
+		})
+	}
+}
+
 func TestCreateAndUpdateProduceNoFindings(t *testing.T) {
 	changes := []parse.ResourceChange{
 		{Address: "aws_instance.a", Type: "aws_instance", Action: parse.ActionCreate},
@@ -181,6 +257,58 @@ func TestPublicIngressDetection(t *testing.T) {
 	}
 }
 
+func TestUnknownIngressCannotBeVerified(t *testing.T) {
+	changes := []parse.ResourceChange{
+		{
+			Address: "aws_security_group.web",
+			Type:    "aws_security_group",
+			Action:  parse.ActionUpdate,
+			After: mustRawJSON(t, map[string]any{
+				"ingress": []any{map[string]any{"from_port": 22, "to_port": 22, "protocol": "tcp"}},
+			}),
+			ChangePaths:  []string{"ingress[0].cidr_blocks"},
+			UnknownPaths: []string{"ingress[0].cidr_blocks"},
+		},
+		{
+			Address:      "aws_lb.app",
+			Type:         "aws_lb",
+			Action:       parse.ActionCreate,
+			After:        mustRawJSON(t, map[string]any{}),
+			UnknownPaths: []string{"internal"},
+		},
+		{
+			Address: "aws_security_group_rule.egress",
+			Type:    "aws_security_group_rule",
+			Action:  parse.ActionCreate,
+			After: mustRawJSON(t, map[string]any{
+				"type": "egress", "from_port": 0, "to_port": 0, "protocol": "-1",
+			}),
+			ChangePaths:  []string{"cidr_blocks"},
+			UnknownPaths: []string{"cidr_blocks"},
+		},
+	}
+
+	findings := Analyze(changes)
+	for _, title := range []string{"Public ingress cannot be verified", "Load balancer exposure cannot be verified"} {
+		if !hasFindingTitle(findings, title) {
+			t.Fatalf("expected %q finding, got %#v", title, findings)
+		}
+	}
+	for _, f := range findings {
+		if f.Address == "aws_security_group_rule.egress" {
+			t.Errorf("egress rule reported: %+v", f)
+		}
+	}
+	for _, f := range findings {
+		if f.Confidence != ConfidenceUnverified {
+			t.Errorf("expected unverified confidence for %q, got %s", f.Title, f.Confidence)
+		}
+		if f.Severity != SeverityMedium {
+			t.Errorf("expected medium severity for %q, got %s", f.Title, f.Severity)
+		}
+	}
+}
+
 func TestInternetFacingLoadBalancerDetection(t *testing.T) {
 	changes := []parse.ResourceChange{
 		{
@@ -288,6 +416,128 @@ func TestNetworkRoutingChangeDetection(t *testing.T) {
 	}
 }
 
+func TestPureMoveIsInfoOnly(t *testing.T) {
+	changes := []parse.ResourceChange{
+		{
+			Address:         "module.new.aws_iam_role_policy_attachment.app",
+			PreviousAddress: "module.old.aws_iam_role_policy_attachment.app",
+			Type:            "aws_iam_role_policy_attachment",
+			Action:          parse.ActionMove,
+		},
+	}
+	findings := Analyze(changes)
+	if len(findings) != 1 {
+		t.Fatalf("expected 1 finding for a pure move, got %#v", findings)
+	}
+	if findings[0].Severity != SeverityInfo || findings[0].Title != "Resource moved" {
+		t.Errorf("unexpected finding: %s %s", findings[0].Severity, findings[0].Title)
+	}
+}
+
+func TestMoveWithReplaceStillFlagged(t *testing.T) {
+	changes := []parse.ResourceChange{
+		{
+			Address:         "module.new.aws_instance.web",
+			PreviousAddress: "module.old.aws_instance.web",
+			Type:            "aws_instance",
+			Action:          parse.ActionReplace,
+		},
+	}
+	findings := Analyze(changes)
+	if len(findings) != 1 {
+		t.Fatalf("expected 1 finding, got %d", len(findings))
+	}
+	if findings[0].Severity != SeverityHigh {
+		t.Errorf("expected high severity, got %s", findings[0].Severity)
+	}
+	if findings[0].Evidence.PreviousAddress != "module.old.aws_instance.web" {
+		t.Errorf("expected previous address in evidence, got %q", findings[0].Evidence.PreviousAddress)
+	}
+}
+
+func TestStateOnlyActions(t *testing.T) {
+	tests := []struct {
+		action   parse.Action
+		title    string
+		severity Severity
+	}{
+		{parse.ActionForget, "Resource will be removed from state but not destroyed", SeverityMedium},
+		{parse.ActionImport, "Resource will be imported", SeverityInfo},
+		{parse.ActionRead, "Data source read deferred to apply", SeverityLow},
+		{parse.ActionUnknown, "Unrecognized plan action", SeverityMedium},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.action), func(t *testing.T) {
+			// An IAM attachment would otherwise trigger the IAM rules.
+			findings := Analyze([]parse.ResourceChange{{
+				Address:  "aws_iam_role_policy_attachment.app",
+				Type:     "aws_iam_role_policy_attachment",
+				Action:   tt.action,
+				ImportID: "app-role/arn",
+			}})
+			if len(findings) != 1 {
+				t.Fatalf("expected exactly 1 finding, got %#v", findings)
+			}
+			if findings[0].Title != tt.title || findings[0].Severity != tt.severity {
+				t.Errorf("unexpected finding: %s %s", findings[0].Severity, findings[0].Title)
+			}
+		})
+	}
+}
+
+func TestDeferredChangeFlagged(t *testing.T) {
+	findings := Analyze([]parse.ResourceChange{{
+		Address:        "aws_instance.web",
+		Type:           "aws_instance",
+		Action:         parse.ActionReplace,
+		DeferredReason: "resource_config_unknown",
+	}})
+	if !hasFindingTitle(findings, "Change deferred to a later plan") {
+		t.Fatalf("expected deferred finding, got %#v", findings)
+	}
+	if !hasFindingTitle(findings, "Resource replacement detected") {
+		t.Fatalf("expected deferred replacement to still be flagged, got %#v", findings)
+	}
+}
+
+func TestDriftFindings(t *testing.T) {
+	drift := []parse.ResourceDrift{
+		{ResourceChange: parse.ResourceChange{
+			Address:     "aws_security_group.web",
+			Type:        "aws_security_group",
+			Action:      parse.ActionUpdate,
+			ChangePaths: []string{"ingress[0].cidr_blocks[0]"},
+		}},
+		{ResourceChange: parse.ResourceChange{
+			Address: "aws_db_instance.main",
+			Type:    "aws_db_instance",
+			Action:  parse.ActionDelete,
+		}},
+	}
+	changes := []parse.ResourceChange{
+		{Address: "aws_security_group.web", Type: "aws_security_group", Action: parse.ActionUpdate},
+	}
+
+	findings := AnalyzeDrift(drift, changes)
+	if !hasFindingTitle(findings, "Security group modified out-of-band") {
+		t.Fatalf("expected security group drift finding, got %#v", findings)
+	}
+	if !hasFindingTitle(findings, "Plan reverts out-of-band change") {
+		t.Fatalf("expected revert finding, got %#v", findings)
+	}
+	for _, f := range findings {
+		if !f.Evidence.Drift {
+			t.Errorf("expected drift evidence on %q", f.Title)
+		}
+		if f.Title == "Stateful resource drifted" && f.Severity != SeverityHigh {
+			t.Errorf("expected high severity for stateful resource deleted out-of-band, got %s", f.Severity)
+		}
+	}
+	if !hasFindingTitle(findings, "Stateful resource drifted") {
+		t.Fatalf("expected stateful drift finding, got %#v", findings)
+	}
+}
+
 func hasFindingTitle(findings []Finding, title string) bool {
 	for _, f := range findings {
 		if f.Title == title {
@@ -305,3 +555,672 @@ func mustRawJSON(t *testing.T, v any) json.RawMessage {
 	}
 	return b
 }
+
+func TestFindingsKeepStack(t *testing.T) {
+	changes := []parse.ResourceChange{
+		{Address: "aws_db_instance.main", Type: "aws_db_instance", Action: parse.ActionDelete, Stack: "prod/db"},
+		{Address: "aws_db_instance.main", Type: "aws_db_instance", Action: parse.ActionDelete, Stack: "staging/db"},
+	}
+	findings := Analyze(changes)
+	stacks := map[string]bool{}
+	for _, f := range findings {
+		stacks[f.Stack] = true
+	}
+	if !stacks["prod/db"] || !stacks["staging/db"] {
+		t.Errorf("expected a finding per stack for the same address, got %+v", findings)
+	}
+}
+
+func TestOutputFindings(t *testing.T) {
+	outputs := []parse.OutputChange{
+		{Name: "legacy", Action: parse.ActionDelete, Before: json.RawMessage(`"x"`), After: json.RawMessage(`null`)},
+		{Name: "password", Action: parse.ActionUpdate, Before: json.RawMessage(`"a"`), After: json.RawMessage(`"a"`), BeforeSensitive: true},
+		{Name: "vpc_id", Action: parse.ActionUpdate, Before: json.RawMessage(`"a"`), After: json.RawMessage(`"a"`), AfterSensitive: true},
+		{Name: "subnets", Action: parse.ActionUpdate, Before: json.RawMessage(`"a,b"`), After: json.RawMessage(`["a","b"]`), Stack: "prod"},
+		{Name: "pending", Action: parse.ActionUpdate, Before: json.RawMessage(`"a"`), After: json.RawMessage(`null`), AfterUnknown: true},
+		{Name: "cleared", Action: parse.ActionUpdate, Before: json.RawMessage(`"a"`), After: json.RawMessage(`null`)},
+		{Name: "fresh", Action: parse.ActionCreate, After: json.RawMessage(`"a"`), AfterSensitive: true},
+	}
+	got := map[string]Finding{}
+	for _, f := range AnalyzeOutputs(outputs) {
+		if _, dup := got[f.Address]; dup {
+			t.Errorf("more than one finding for %s", f.Address)
+		}
+		got[f.Address] = f
+	}
+
+	want := map[string]struct {
+		title    string
+		severity Severity
+	}{
+		"output.legacy":   {"Output removed", SeverityHigh},
+		"output.password": {"Output no longer sensitive", SeverityHigh},
+		"output.vpc_id":   {"Output became sensitive", SeverityMedium},
+		"output.subnets":  {"Output type changed", SeverityMedium},
+	}
+	if len(got) != len(want) {
+		t.Errorf("got findings for %d outputs, want %d: %+v", len(got), len(want), got)
+	}
+	for addr, w := range want {
+		f, ok := got[addr]
+		if !ok {
+			t.Errorf("no finding for %s", addr)
+			continue
+		}
+		if f.Title != w.title || f.Severity != w.severity {
+			t.Errorf("%s: got %q (%s), want %q (%s)", addr, f.Title, f.Severity, w.title, w.severity)
+		}
+		if f.Evidence.ResourceType != "output" {
+			t.Errorf("%s: resource type = %q, want output", addr, f.Evidence.ResourceType)
+		}
+	}
+	if f := got["output.subnets"]; f.Stack != "prod" || !strings.Contains(f.Description, "string to list") {
+		t.Errorf("type change finding = %+v", f)
+	}
+}
+
+func TestAddBlastRadius(t *testing.T) {
+	plan := &parse.Plan{
+		Configuration: parse.Configuration{Resources: []parse.ConfigResource{
+			{Address: "aws_vpc.main", Mode: parse.ModeManaged},
+			{Address: "aws_security_group.web", Mode: parse.ModeManaged, References: []string{"aws_vpc.main.id"}},
+		}},
+		ResourceChanges: []parse.ResourceChange{
+			{Address: "aws_vpc.main", Type: "aws_vpc", Action: parse.ActionReplace},
+			{Address: "aws_security_group.web", Type: "aws_security_group", Action: parse.ActionUpdate},
+		},
+	}
+	findings := AddBlastRadius(Analyze(plan.ResourceChanges), graph.Build(plan))
+	var replacement Finding
+	for _, f := range findings {
+		if f.Title == titleReplacement {
+			replacement = f
+		} else if len(f.Evidence.BlastRadius) > 0 {
+			t.Errorf("blast radius attached to %q", f.Title)
+		}
+	}
+	deps := replacement.Evidence.BlastRadius
+	if len(deps) != 1 || deps[0].Address != "aws_security_group.web" || deps[0].Action != parse.ActionUpdate {
+		t.Errorf("blast radius = %+v", deps)
+	}
+}
+
+func TestLifecycleFindings(t *testing.T) {
+	disabled, enabled := false, true
+	changes := []parse.ResourceChange{
+		{Address: "aws_db_instance.main", Type: "aws_db_instance", Action: parse.ActionDelete, Lifecycle: &parse.Lifecycle{PreventDestroy: &disabled}},
+		{Address: "aws_s3_bucket.logs", Type: "aws_s3_bucket", Action: parse.ActionUpdate, Lifecycle: &parse.Lifecycle{PreventDestroy: &enabled}},
+		{Address: "aws_instance.web", Type: "aws_instance", Action: parse.ActionReplace, Lifecycle: &parse.Lifecycle{CreateBeforeDestroy: true}},
+		{Address: "aws_security_group.web", Type: "aws_security_group", Action: parse.ActionUpdate, Lifecycle: &parse.Lifecycle{IgnoreChanges: []string{"tags", "ingress", "egress[0].cidr_blocks"}}},
+		{Address: "aws_instance.tagged", Type: "aws_instance", Action: parse.ActionUpdate, Lifecycle: &parse.Lifecycle{IgnoreChanges: []string{"tags"}}},
+		{Address: "aws_iam_role.ci", Type: "aws_iam_role", Action: parse.ActionCreate, Lifecycle: &parse.Lifecycle{IgnoreChanges: []string{parse.IgnoreAll}}},
+		{Address: "aws_rds_cluster.legacy", Type: "aws_rds_cluster", Action: parse.ActionDelete, ActionReason: parse.ReasonNoResourceConfig},
+		{Address: "module.old.aws_s3_bucket.data", Type: "aws_s3_bucket", Action: parse.ActionDelete, ActionReason: parse.ReasonNoModule},
+		{Address: "aws_iam_role.unused", Type: "aws_iam_role", Action: parse.ActionDelete, ActionReason: parse.ReasonNoResourceConfig},
+		{Address: "aws_dynamodb_table.locks", Type: "aws_dynamodb_table", Action: parse.ActionDelete},
+	}
+	findings := Analyze(changes)
+
+	var protection, removed, ignored []Finding
+	for _, f := range findings {
+		switch f.Title {
+		case "Deletion protection explicitly disabled":
+			protection = append(protection, f)
+		case "Deletion protection may have been removed":
+			removed = append(removed, f)
+		case "Security-relevant changes ignored":
+			ignored = append(ignored, f)
+		}
+	}
+	if len(protection) != 1 || protection[0].Address != "aws_db_instance.main" || protection[0].Severity != SeverityCritical {
+		t.Errorf("deletion protection findings = %+v", protection)
+	}
+	// Only stateful deletions whose block or module call is gone.
+	if len(removed) != 2 || removed[0].Address != "aws_rds_cluster.legacy" || removed[1].Address != "module.old.aws_s3_bucket.data" {
+		t.Fatalf("removed protection findings = %+v", removed)
+	}
+	for _, f := range removed {
+		if f.Severity != SeverityCritical || f.RuleID != RuleProtectionRemoved || !strings.Contains(f.Description, "cannot tell whether the resource was protected") {
+			t.Errorf("removed protection finding = %+v", f)
+		}
+	}
+	if len(ignored) != 2 {
+		t.Fatalf("ignore_changes findings = %+v, want aws_security_group.web and aws_iam_role.ci", ignored)
+	}
+	for _, f := range ignored {
+		switch f.Address {
+		case "aws_security_group.web":
+			if want := []string{"ingress", "egress[0].cidr_blocks"}; !reflect.DeepEqual(f.Evidence.Matches, want) {
+				t.Errorf("matches = %v, want %v", f.Evidence.Matches, want)
+			}
+		case "aws_iam_role.ci":
+			if !strings.Contains(f.Description, "all changes") {
+				t.Errorf("ignore_changes = all description = %q", f.Description)
+			}
+		default:
+			t.Errorf("unexpected ignore_changes finding for %s", f.Address)
+		}
+	}
+}
+
+func countChange(t *testing.T, index int, action parse.Action, before, after map[string]any, changePaths ...string) parse.ResourceChange {
+	t.Helper()
+	ch := parse.ResourceChange{
+		Address:     fmt.Sprintf("aws_iam_user.ops[%d]", index),
+		Type:        "aws_iam_user",
+		Action:      action,
+		ChangePaths: changePaths,
+	}
+	if before != nil {
+		ch.Before = mustRawJSON(t, before)
+	}
+	if after != nil {
+		ch.After = mustRawJSON(t, after)
+	}
+	return ch
+}
+
+func TestCountIndexShiftCollapsesFindings(t *testing.T) {
+	user := func(name string) map[string]any { return map[string]any{"name": name, "path": "/"} }
+
+	// Removing "bob" from ["alice", "bob", "carol", "dave"].
+	removal := []parse.ResourceChange{
+		countChange(t, 1, parse.ActionReplace, user("bob"), user("carol"), "name"),
+		countChange(t, 2, parse.ActionReplace, user("carol"), user("dave"), "name"),
+		countChange(t, 3, parse.ActionDelete, user("dave"), nil, "name", "path"),
+	}
+	findings := Analyze(removal)
+	if len(findings) != 1 {
+		t.Fatalf("expected one collapsed finding, got %d: %+v", len(findings), findings)
+	}
+	f := findings[0]
+	if f.Title != titleIndexShift || f.Address != "aws_iam_user.ops" || f.Severity != SeverityHigh {
+		t.Errorf("finding = %q %s (%s)", f.Title, f.Address, f.Severity)
+	}
+	if !strings.Contains(f.Description, "Removing") || !strings.Contains(f.Description, "for_each") {
+		t.Errorf("description = %q", f.Description)
+	}
+	if want := []string{"[1] ← [2]", "[2] ← [3]"}; !reflect.DeepEqual(f.Evidence.IndexShifts, want) {
+		t.Errorf("index shifts = %v, want %v", f.Evidence.IndexShifts, want)
+	}
+	if len(f.Collapsed) != 3 {
+		t.Errorf("collapsed %d findings, want 3", len(f.Collapsed))
+	}
+
+	// Inserting "aaron" at the front of ["alice", "bob"].
+	insertion := []parse.ResourceChange{
+		countChange(t, 0, parse.ActionUpdate, user("alice"), user("aaron"), "name"),
+		countChange(t, 1, parse.ActionUpdate, user("bob"), user("alice"), "name"),
+		countChange(t, 2, parse.ActionCreate, nil, user("bob"), "name", "path"),
+	}
+	findings = Analyze(insertion)
+	if len(findings) != 1 || !strings.Contains(findings[0].Description, "Adding") || findings[0].Severity != SeverityMedium {
+		t.Errorf("insertion findings = %+v", findings)
+	}
+
+	// Independent changes to count instances are not a shift.
+	independent := []parse.ResourceChange{
+		countChange(t, 0, parse.ActionReplace, user("alice"), user("alicia"), "name"),
+		countChange(t, 1, parse.ActionReplace, user("bob"), user("robert"), "name"),
+	}
+	for _, f := range Analyze(independent) {
+		if f.Title == titleIndexShift {
+			t.Errorf("independent replacements reported as index shift: %+v", f)
+		}
+	}
+}
+
+func TestCountIndexShiftLeavesOtherInstances(t *testing.T) {
+	rule := func(port int, cidr string) map[string]any {
+		return map[string]any{"type": "ingress", "from_port": port, "to_port": port, "protocol": "tcp", "cidr_blocks": []any{cidr}}
+	}
+	instance := func(index int, action parse.Action, before, after map[string]any, changePaths ...string) parse.ResourceChange {
+		ch := countChange(t, index, action, before, after, changePaths...)
+		ch.Address = fmt.Sprintf("aws_security_group_rule.r[%d]", index)
+		ch.Type = "aws_security_group_rule"
+		return ch
+	}
+
+	// r[0] opens SSH to the internet on its own; removing the element at
+	// [1] shifts r[2] and r[3] down and destroys r[3].
+	changes := []parse.ResourceChange{
+		instance(0, parse.ActionUpdate, rule(22, "10.0.0.0/8"), rule(22, "0.0.0.0/0"), "cidr_blocks[0]"),
+		instance(1, parse.ActionReplace, rule(8080, "10.0.0.0/8"), rule(8081, "10.0.0.0/8"), "from_port", "to_port"),
+		instance(2, parse.ActionReplace, rule(8081, "10.0.0.0/8"), rule(8082, "10.0.0.0/8"), "from_port", "to_port"),
+		instance(3, parse.ActionDelete, rule(8082, "10.0.0.0/8"), nil, "from_port", "to_port"),
+	}
+	var shift *Finding
+	var topLevel []string
+	for _, f := range Analyze(changes) {
+		if f.RuleID == RuleIndexShift {
+			shift = &f
+			continue
+		}
+		topLevel = append(topLevel, f.Address+" "+f.RuleID)
+	}
+	if shift == nil {
+		t.Fatal("no index shift finding")
+	}
+	if want := []string{"aws_security_group_rule.r[0] " + RulePublicIngress}; !reflect.DeepEqual(topLevel, want) {
+		t.Errorf("top-level findings = %v, want %v", topLevel, want)
+	}
+	for _, c := range shift.Collapsed {
+		if c.Address == "aws_security_group_rule.r[0]" {
+			t.Errorf("collapsed a finding on an instance that did not shift: %+v", c)
+		}
+	}
+	if strings.Contains(shift.Description, "update") {
+		t.Errorf("description counts the unshifted update: %q", shift.Description)
+	}
+}
+
+func TestCountIndexShiftTieIsDeterministic(t *testing.T) {
+	user := func(name string) map[string]any { return map[string]any{"name": name} }
+
+	// Swapping two elements votes equally for a removal and an addition.
+	swap := []parse.ResourceChange{
+		countChange(t, 0, parse.ActionUpdate, user("alice"), user("bob"), "name"),
+		countChange(t, 1, parse.ActionUpdate, user("bob"), user("alice"), "name"),
+	}
+	for range 20 {
+		findings := Analyze(swap)
+		if len(findings) != 1 || !strings.Contains(findings[0].Description, "Removing") {
+			t.Fatalf("findings = %+v, want a removal", findings)
+		}
+	}
+}
+
+func TestFindingsCarryRuleIDs(t *testing.T) {
+	changes := []parse.ResourceChange{
+		{Address: "aws_db_instance.main", Type: "aws_db_instance", Action: parse.ActionDelete},
+		{Address: "aws_lb.public", Type: "aws_lb", Action: parse.ActionCreate, After: json.RawMessage(`{"internal":false}`)},
+	}
+	var got []string
+	for _, f := range Analyze(changes) {
+		got = append(got, f.RuleID)
+	}
+	want := []string{RuleDeletion, RuleInternetFacingLB}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rule IDs = %v, want %v", got, want)
+	}
+
+	drift := []parse.ResourceDrift{{ResourceChange: parse.ResourceChange{Address: "aws_security_group.web", Type: "aws_security_group", Action: parse.ActionUpdate}}}
+	if fs := AnalyzeDrift(drift, nil); len(fs) != 1 || fs[0].RuleID != RuleSecurityGroupDrift {
+		t.Errorf("drift findings = %+v", fs)
+	}
+	outputs := []parse.OutputChange{{Name: "vpc_id", Action: parse.ActionDelete}}
+	if fs := AnalyzeOutputs(outputs); len(fs) != 1 || fs[0].RuleID != RuleOutputRemoved {
+		t.Errorf("output findings = %+v", fs)
+	}
+}
+
+func TestDefaultRegistryRules(t *testing.T) {
+	r := DefaultRegistry()
+	rules := r.Rules()
+	if len(rules) == 0 {
+		t.Fatal("no built-in rules")
+	}
+	for _, rule := range rules {
+		if rule.Description() == "" {
+			t.Errorf("%s has no description", rule.ID())
+		}
+		if !r.Enabled(rule.ID()) {
+			t.Errorf("%s is not enabled by default", rule.ID())
+		}
+	}
+	if _, ok := r.Lookup(RuleIndexShift); !ok {
+		t.Error("index shift rule not registered")
+	}
+}
+
+func TestRegistryDisableAndEnable(t *testing.T) {
+	r := DefaultRegistry()
+	changes := []parse.ResourceChange{{Address: "aws_instance.web", Type: "aws_instance", Action: parse.ActionDelete}}
+
+	if err := r.Disable(RuleDeletion); err != nil {
+		t.Fatal(err)
+	}
+	if fs := r.Analyze(changes); len(fs) != 0 {
+		t.Errorf("disabled rule still reported: %+v", fs)
+	}
+	if fs := Analyze(changes); len(fs) != 1 {
+		t.Errorf("disabling a rule affected the default registry: %+v", fs)
+	}
+	if err := r.Enable(RuleDeletion); err != nil {
+		t.Fatal(err)
+	}
+	if fs := r.Analyze(changes); len(fs) != 1 {
+		t.Errorf("re-enabled rule not reported: %+v", fs)
+	}
+	if err := r.Disable("DIFFY-NOPE-001"); err == nil {
+		t.Error("disabling an unknown rule succeeded")
+	}
+}
+
+func TestRegistryDisableIndexShift(t *testing.T) {
+	user := func(name string) map[string]any { return map[string]any{"name": name} }
+	changes := []parse.ResourceChange{
+		countChange(t, 0, parse.ActionReplace, user("bob"), user("carol"), "name"),
+		countChange(t, 1, parse.ActionDelete, user("carol"), nil, "name"),
+	}
+	if fs := Analyze(changes); len(fs) != 1 || fs[0].RuleID != RuleIndexShift {
+		t.Fatalf("expected one index shift finding, got %+v", fs)
+	}
+	r := DefaultRegistry()
+	if err := r.Disable(RuleIndexShift); err != nil {
+		t.Fatal(err)
+	}
+	fs := r.Analyze(changes)
+	if len(fs) != 2 || fs[0].RuleID != RuleReplacement || fs[1].RuleID != RuleDeletion {
+		t.Errorf("findings with index shift disabled = %+v", fs)
+	}
+}
+
+func TestRegisterCustomRule(t *testing.T) {
+	r := DefaultRegistry()
+	rule := NewRule("ACME-NET-001", SeverityLow, "VPC changes", func(in Input) []Finding {
+		if in.Kind != InputChange || in.Change.Type != "aws_vpc" {
+			return nil
+		}
+		return []Finding{{Severity: SeverityLow, Title: "VPC changed", Address: in.Change.Address}}
+	})
+	if err := r.Register(rule); err != nil {
+		t.Fatal(err)
+	}
+	fs := r.Analyze([]parse.ResourceChange{{Address: "aws_vpc.main", Type: "aws_vpc", Action: parse.ActionUpdate}})
+	if len(fs) != 1 || fs[0].RuleID != "ACME-NET-001" {
+		t.Errorf("custom rule findings = %+v", fs)
+	}
+
+	if err := r.Register(rule); err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Errorf("duplicate registration error = %v", err)
+	}
+	for _, id := range []string{"", "acme-net-001", "ACME", "ACME NET 1"} {
+		if err := r.Register(NewRule(id, SeverityLow, "bad", nil)); err == nil {
+			t.Errorf("Register(%q) succeeded", id)
+		}
+	}
+}
+
+func TestSuppressions(t *testing.T) {
+	changes := []parse.ResourceChange{
+		{Address: "module.edge.aws_lb.public", Type: "aws_lb", Action: parse.ActionCreate, After: json.RawMessage(`{"internal":false}`)},
+		{Address: "aws_lb.admin", Type: "aws_lb", Action: parse.ActionCreate, After: json.RawMessage(`{"internal":false}`)},
+		{Address: `module.legacy["a"].module.db.aws_db_instance.main`, Type: "aws_db_instance", Action: parse.ActionDelete},
+		{Address: "aws_subnet.private[0]", Type: "aws_subnet", Action: parse.ActionDelete},
+	}
+	day := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	now := day("2026-10-17").Add(15 * time.Hour)
+	suppressions := []Suppression{
+		{Rule: RuleInternetFacingLB, Address: "module.edge.*", Justification: "Public site", Owner: "web", Expires: day("2026-10-17")},
+		{Module: "module.legacy*", ResourceType: "aws_db_*", Justification: "Decommissioning", Owner: "data", Expires: day("2027-01-01")},
+		{Address: "aws_subnet.private[0]", Justification: "Old", Owner: "net", Expires: day("2026-10-16"), Source: "s.yaml:9"},
+	}
+
+	findings := Suppress(Analyze(changes), suppressions, now)
+	active, suppressed := SplitSuppressed(findings)
+
+	var got []string
+	for _, f := range suppressed {
+		got = append(got, f.Address+" by "+f.Suppression.Owner)
+	}
+	want := []string{"module.edge.aws_lb.public by web", `module.legacy["a"].module.db.aws_db_instance.main by data`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("suppressed = %v, want %v", got, want)
+	}
+
+	got = nil
+	for _, f := range active {
+		got = append(got, f.Address+" "+f.RuleID)
+	}
+	want = []string{"aws_lb.admin " + RuleInternetFacingLB, "aws_subnet.private[0] " + RuleDeletion, "s.yaml:9 " + RuleSuppressionExpired}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("active = %v, want %v", got, want)
+	}
+	expired := active[len(active)-1]
+	if expired.Severity != SeverityMedium || !strings.Contains(expired.Description, "aws_subnet.private[0]") || !strings.Contains(expired.Description, "2026-10-16") {
+		t.Errorf("expired suppression finding = %+v", expired)
+	}
+
+	if ExceedsThreshold(suppressed, SeverityInfo) {
+		t.Error("suppressed findings count toward the threshold")
+	}
+	if !ExceedsThreshold(findings, SeverityHigh) {
+		t.Error("unsuppressed high finding does not count toward the threshold")
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"aws_lb.*", "aws_lb.public", true},
+		{"aws_subnet.private[0]", "aws_subnet.private[0]", true},
+		{"aws_subnet.private[?]", "aws_subnet.private[1]", true},
+		{"aws_subnet.private[?]", "aws_subnet.private[10]", false},
+		{"*.aws_lb.*", "module.edge.aws_lb.public", true},
+		{"*.aws_lb.*", "aws_lb.public", false},
+		{"*", "", true},
+		{"a*b*c", "aXbYbZc", true},
+		{"a*b*c", "aXbYbZ", false},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestFingerprints(t *testing.T) {
+	changes := []parse.ResourceChange{
+		{Address: "aws_db_instance.main", Type: "aws_db_instance", Action: parse.ActionDelete},
+		{Address: "aws_db_instance.replica", Type: "aws_db_instance", Action: parse.ActionDelete},
+	}
+	findings := Analyze(changes)
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %d", len(findings))
+	}
+	for _, f := range findings {
+		if len(f.Fingerprint) != 32 {
+			t.Errorf("%s: fingerprint %q is not 32 hex digits", f.Address, f.Fingerprint)
+		}
+	}
+	if findings[0].Fingerprint == findings[1].Fingerprint {
+		t.Error("findings on different resources share a fingerprint")
+	}
+
+	// Severity overrides and wording do not change the fingerprint; the
+	// order of matches does not either.
+	r := DefaultRegistry()
+	if err := r.SetSeverity(RuleDeletion, SeverityLow); err != nil {
+		t.Fatal(err)
+	}
+	again := r.Analyze(changes)
+	if again[0].Fingerprint != findings[0].Fingerprint {
+		t.Error("a severity override changed the fingerprint")
+	}
+	f := findings[0]
+	f.Description = "reworded"
+	f.Evidence.Matches = []string{"b", "a", "b"}
+	g := findings[0]
+	g.Evidence.Matches = []string{"a", "b"}
+	if Fingerprint(f) != Fingerprint(g) {
+		t.Error("fingerprint depends on wording or match order")
+	}
+
+	// Findings in the baseline do not count toward the threshold.
+	marked := Baseline{findings[0].Fingerprint: true, findings[1].Fingerprint: true}.Mark(findings)
+	if !marked[0].InBaseline || ExceedsThreshold(marked, SeverityHigh) {
+		t.Errorf("baseline findings still count: %+v", marked)
+	}
+}
+
+func TestCustomRules(t *testing.T) {
+	change := func(addr, typ string, action parse.Action, before, after string, paths ...string) parse.ResourceChange {
+		ch := parse.ResourceChange{Address: addr, Type: typ, Action: action, ChangePaths: paths}
+		if before != "" {
+			ch.Before = json.RawMessage(before)
+		}
+		if after != "" {
+			ch.After = json.RawMessage(after)
+		}
+		return ch
+	}
+	kms := change("aws_kms_key.main", "aws_kms_key", parse.ActionUpdate,
+		`{"deletion_window_in_days":30,"tags":{"team":"sec"}}`, `{"deletion_window_in_days":7,"tags":{"team":"sec"}}`,
+		"deletion_window_in_days")
+	sg := change("aws_security_group.web", "aws_security_group", parse.ActionUpdate,
+		`{"ingress":[{"cidr_blocks":["10.0.0.0/8"]}],"description":"web"}`,
+		`{"ingress":[{"cidr_blocks":["10.0.0.0/8","0.0.0.0/0"]}],"description":"web tier"}`,
+		"description", "ingress[0].cidr_blocks[1]")
+
+	tests := []struct {
+		name    string
+		spec    RuleSpec
+		changes []parse.ResourceChange
+		want    []string // address: matches
+	}{
+		{
+			name: "decreased",
+			spec: RuleSpec{Types: []string{"aws_kms_*"}, Paths: []string{"deletion_window_*"},
+				Conditions: []Condition{{Path: "deletion_window_in_days", Op: OpDecreased}}},
+			changes: []parse.ResourceChange{kms, sg},
+			want:    []string{"aws_kms_key.main: deletion_window_in_days: 30 → 7"},
+		},
+		{
+			name:    "increased does not hold",
+			spec:    RuleSpec{Conditions: []Condition{{Path: "deletion_window_in_days", Op: OpIncreased}}},
+			changes: []parse.ResourceChange{kms},
+		},
+		{
+			name:    "equals before",
+			spec:    RuleSpec{Conditions: []Condition{{Path: "deletion_window_in_days", Op: OpEquals, Value: 30, Before: true}}},
+			changes: []parse.ResourceChange{kms},
+			want:    []string{"aws_kms_key.main: deletion_window_in_days=30"},
+		},
+		{
+			name:    "changed with wildcards",
+			spec:    RuleSpec{Conditions: []Condition{{Path: "*", Op: OpChanged}}},
+			changes: []parse.ResourceChange{kms, sg},
+			want: []string{
+				"aws_kms_key.main: deletion_window_in_days: 30 → 7",
+				`aws_security_group.web: description: web → web tier, ingress: [{"cidr_blocks":["10.0.0.0/8"]}] → [{"cidr_blocks":["10.0.0.0/8","0.0.0.0/0"]}]`,
+			},
+		},
+		{
+			name:    "contains",
+			spec:    RuleSpec{Conditions: []Condition{{Path: "ingress[*].cidr_blocks", Op: OpContains, Value: "0.0.0.0/0"}, {Path: "description", Op: OpContains, Value: "tier"}}},
+			changes: []parse.ResourceChange{sg},
+			want:    []string{`aws_security_group.web: ingress[0].cidr_blocks=["10.0.0.0/8","0.0.0.0/0"], description=web tier`},
+		},
+		{
+			name:    "cidr contains",
+			spec:    RuleSpec{Conditions: []Condition{{Path: "ingress[*].cidr_blocks", Op: OpCIDRContains, Value: "203.0.113.7"}}},
+			changes: []parse.ResourceChange{sg},
+			want:    []string{`aws_security_group.web: ingress[0].cidr_blocks=["10.0.0.0/8","0.0.0.0/0"]`},
+		},
+		{
+			name:    "cidr contains before",
+			spec:    RuleSpec{Conditions: []Condition{{Path: "ingress[0].cidr_blocks", Op: OpCIDRContains, Value: "203.0.113.0/24", Before: true}}},
+			changes: []parse.ResourceChange{sg},
+		},
+		{
+			name:    "action set",
+			spec:    RuleSpec{Actions: []parse.Action{parse.ActionDelete}},
+			changes: []parse.ResourceChange{kms, sg},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.spec.ID, tt.spec.Severity = "ACME-TEST-001", SeverityHigh
+			tt.spec.Title, tt.spec.Description = "Test rule", "Test rule on {{.Address}}."
+			rule, err := NewCustomRule(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := DefaultRegistry()
+			if err := r.Register(rule); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range r.Analyze(tt.changes) {
+				if f.RuleID == "ACME-TEST-001" {
+					got = append(got, f.Address+": "+strings.Join(f.Evidence.Matches, ", "))
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findings = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomRuleTemplates(t *testing.T) {
+	rule, err := NewCustomRule(RuleSpec{
+		ID:          "ACME-KMS-001",
+		Severity:    SeverityHigh,
+		Title:       "KMS deletion window shortened on {{.Name}}",
+		Description: `{{.Address}} ({{.Type}}, {{.Action}}) shortens its deletion window from {{.Before "deletion_window_in_days"}} to {{.After "deletion_window_in_days"}} days; {{.After "missing"}}.`,
+		Conditions:  []Condition{{Path: "deletion_window_in_days", Op: OpDecreased}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings := rule.Match(Input{Kind: InputChange, Change: parse.ResourceChange{
+		Address: "module.keys.aws_kms_key.main",
+		Type:    "aws_kms_key",
+		Action:  parse.ActionUpdate,
+		Before:  json.RawMessage(`{"deletion_window_in_days":30}`),
+		After:   json.RawMessage(`{"deletion_window_in_days":7}`),
+	}})
+	if len(findings) != 1 {
+		t.Fatalf("expected 1 finding, got %d", len(findings))
+	}
+	f := findings[0]
+	if f.Title != "KMS deletion window shortened on main" || f.Severity != SeverityHigh {
+		t.Errorf("title = %q, severity = %s", f.Title, f.Severity)
+	}
+	if want := "module.keys.aws_kms_key.main (aws_kms_key, update) shortens its deletion window from 30 to 7 days; null."; f.Description != want {
+		t.Errorf("description = %q, want %q", f.Description, want)
+	}
+	// Rule listings describe what the rule matches, not the raw templates.
+	if want := "Resource is created, updated, replaced or deleted where deletion_window_in_days decreased"; rule.Description() != want {
+		t.Errorf("rule description = %q, want %q", rule.Description(), want)
+	}
+	listed, err := NewCustomRule(RuleSpec{
+		ID:          "ACME-NET-001",
+		Title:       "{{.Address}} opens to the internet",
+		Description: "{{.Address}}",
+		Types:       []string{"aws_security_group", "aws_security_group_rule"},
+		Actions:     []parse.Action{parse.ActionCreate, parse.ActionUpdate},
+		Paths:       []string{"ingress*", "cidr_blocks*"},
+		Conditions:  []Condition{{Path: "ingress[*].cidr_blocks", Op: OpCIDRContains, Value: "203.0.113.7"}, {Path: "description", Op: OpEquals, Value: "temp", Before: true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `aws_security_group or aws_security_group_rule is created or updated with changes to ingress* or cidr_blocks* where ingress[*].cidr_blocks has a CIDR block containing "203.0.113.7" and description before the change equals "temp"`; listed.Description() != want {
+		t.Errorf("rule description = %q, want %q", listed.Description(), want)
+	}
+
+	for _, spec := range []RuleSpec{
+		{ID: "acme-1", Title: "t", Description: "d"},
+		{ID: "ACME-X-001", Title: "{{.Adress}}", Description: "d"},
+		{ID: "ACME-X-001", Title: "t", Description: ""},
+		{ID: "ACME-X-001", Title: "t", Description: "d", Conditions: []Condition{{Path: "a[x]", Op: OpChanged}}},
+		{ID: "ACME-X-001", Title: "t", Description: "d", Conditions: []Condition{{Path: "a", Op: OpEquals}}},
+		{ID: "ACME-X-001", Title: "t", Description: "d", Conditions: []Condition{{Path: "a", Op: OpChanged, Value: 1}}},
+		{ID: "ACME-X-001", Title: "t", Description: "d", Conditions: []Condition{{Path: "a", Op: OpCIDRContains, Value: "nope"}}},
+	} {
+		if _, err := NewCustomRule(spec); err == nil {
+			t.Errorf("NewCustomRule(%+v) succeeded", spec)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package analyze
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sgr0691/diffy/internal/parse"
+)
+
+// suppressionResourceType is the evidence resource type of expired
+// suppression findings.
+const suppressionResourceType = "suppression"
+
+// dateLayout is the format of suppression expiry dates.
+const dateLayout = "2006-01-02"
+
+// Suppression acknowledges findings that are known and accepted, e.g. an
+// intentionally internet-facing load balancer. A finding is suppressed when
+// it matches every matcher that is set.
+type Suppression struct {
+	// Rule is a rule ID.
+	Rule string `json:"rule,omitempty"`
+	// Address is a glob over finding addresses, where "*" matches any run
+	// of characters and "?" any single one, e.g. "module.edge.aws_lb.*".
+	Address string `json:"address,omitempty"`
+	// ResourceType is a glob over resource types, e.g. "aws_lb".
+	ResourceType string `json:"resource_type,omitempty"`
+	// Module is a glob over module paths. It matches resources in the
+	// module and in modules nested in it; "" is not a matcher, so use
+	// Address to target the root module.
+	Module string `json:"module,omitempty"`
+
+	Justification string `json:"justification"`
+	Owner         string `json:"owner"`
+	// Expires is the last day the suppression applies.
+	Expires time.Time `json:"expires"`
+	// Source locates the entry, e.g. ".diffy-suppressions.yaml:12".
+	Source string `json:"source,omitempty"`
+}
+
+// Expired reports whether the suppression no longer applies on the day of
+// now.
+func (s Suppression) Expired(now time.Time) bool {
+	return now.Format(dateLayout) > s.Expires.Format(dateLayout)
+}
+
+// Matches reports whether the suppression covers f.
+func (s Suppression) Matches(f Finding) bool {
+	if s.Rule != "" && s.Rule != f.RuleID {
+		return false
+	}
+	if s.Address != "" && !matchGlob(s.Address, f.Address) {
+		return false
+	}
+	if s.ResourceType != "" && !matchGlob(s.ResourceType, f.Evidence.ResourceType) {
+		return false
+	}
+	if s.Module != "" && !matchModule(s.Module, f.Address) {
+		return false
+	}
+	return true
+}
+
+// describe names what the suppression covers, e.g.
+// "DIFFY-AWS-002 on module.edge.*".
+func (s Suppression) describe() string {
+	var parts []string
+	if s.Rule != "" {
+		parts = append(parts, s.Rule)
+	}
+	if s.Address != "" {
+		parts = append(parts, "on "+s.Address)
+	}
+	if s.ResourceType != "" {
+		parts = append(parts, "for "+s.ResourceType)
+	}
+	if s.Module != "" {
+		parts = append(parts, "in "+s.Module)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Suppress marks the findings covered by an unexpired suppression, and
+// appends a finding for each expired one so it is renewed or removed rather
+// than silently ignored. The first matching suppression wins; now decides
+// which have expired.
+func (r *Registry) Suppress(findings []Finding, suppressions []Suppression, now time.Time) []Finding {
+	var expired []Suppression
+	var active []Suppression
+	for _, s := range suppressions {
+		if s.Expired(now) {
+			expired = append(expired, s)
+		} else {
+			active = append(active, s)
+		}
+	}
+
+	for i, f := range findings {
+		for _, s := range active {
+			if s.Matches(f) {
+				s := s
+				findings[i].Suppression = &s
+				break
+			}
+		}
+	}
+
+	if !r.Enabled(RuleSuppressionExpired) {
+		return findings
+	}
+	for _, s := range expired {
+		f := Finding{
+			RuleID:     RuleSuppressionExpired,
+			Severity:   r.Severity(RuleSuppressionExpired),
+			Confidence: ConfidenceConfirmed,
+			Title:      "Suppression expired",
+			Description: fmt.Sprintf("The suppression of %s owned by %s expired on %s and no longer applies. Review the findings it covered, then renew or remove it.",
+				s.describe(), s.Owner, s.Expires.Format(dateLayout)),
+			Address: s.Source,
+			Evidence: Evidence{
+				Action:       parse.ActionNoop,
+				ResourceType: suppressionResourceType,
+				Matches:      []string{"justification=" + s.Justification, "expires=" + s.Expires.Format(dateLayout)},
+			},
+		}
+		f.Fingerprint = Fingerprint(f)
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// Suppress applies suppressions using the built-in rules' settings.
+func Suppress(findings []Finding, suppressions []Suppression, now time.Time) []Finding {
+	return defaultRegistry.Suppress(findings, suppressions, now)
+}
+
+// SplitSuppressed separates suppressed findings from the ones still in
+// effect, keeping their order.
+func SplitSuppressed(findings []Finding) (active, suppressed []Finding) {
+	for _, f := range findings {
+		if f.Suppression != nil {
+			suppressed = append(suppressed, f)
+		} else {
+			active = append(active, f)
+		}
+	}
+	return active, suppressed
+}
+
+// matchModule reports whether the resource at address is in a module
+// matching pattern or nested in one.
+func matchModule(pattern, address string) bool {
+	addr, err := parse.ParseAddress(address)
+	if err != nil {
+		return false
+	}
+	for i := len(addr.Module); i > 0; i-- {
+		if matchGlob(pattern, strings.Join(addr.Module[:i], ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches s against pattern, where "*" matches any run of
+// characters and "?" any single one. Unlike path.Match, brackets are
+// literal, so instance keys such as `[0]` can be written as-is.
+func matchGlob(pattern, s string) bool {
+	p, i := 0, 0
+	star, mark := -1, 0
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star, mark = p, i
+			p++
+		case star >= 0:
+			p = star + 1
+			mark++
+			i = mark
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
@@ -0,0 +1,137 @@
+// Package compare reports how a plan changed between two runs, e.g. before
+// and after a pull request was updated.
+package compare
+
+import (
+	"sort"
+
+	"github.com/sgr0691/diffy/internal/analyze"
+	"github.com/sgr0691/diffy/internal/parse"
+)
+
+// Plan is one side of a comparison: a plan's changes and the findings
+// Diffy raised for them.
+type Plan struct {
+	Changes  []parse.ResourceChange
+	Findings []analyze.Finding
+}
+
+// ActionChange is a resource planned in both runs with a different action,
+// e.g. update in the old plan and replace in the new one.
+type ActionChange struct {
+	Old parse.ResourceChange
+	New parse.ResourceChange
+}
+
+// Result is the difference between two plans. Resources are matched by
+// stack and address, findings by fingerprint, so a finding whose severity or
+// wording changed is still matched to its old counterpart.
+type Result struct {
+	// Added resources are planned only in the new plan.
+	Added []parse.ResourceChange
+	// Dropped resources were planned only in the old plan.
+	Dropped []parse.ResourceChange
+	// Changed resources are planned in both with different actions.
+	Changed []ActionChange
+	// Unchanged counts resources planned in both with the same action.
+	Unchanged int
+	// NewFindings appear only in the new plan.
+	NewFindings []analyze.Finding
+	// ResolvedFindings appeared only in the old plan.
+	ResolvedFindings []analyze.Finding
+}
+
+// Empty reports whether the two plans are equivalent.
+func (r Result) Empty() bool {
+	return len(r.Added) == 0 && len(r.Dropped) == 0 && len(r.Changed) == 0 &&
+		len(r.NewFindings) == 0 && len(r.ResolvedFindings) == 0
+}
+
+// Compare reports what changed from the before plan to the after plan.
+// Results follow the order of the plan each entry came from.
+func Compare(before, after Plan) Result {
+	var res Result
+
+	oldChanges := make(map[string]parse.ResourceChange, len(before.Changes))
+	for _, ch := range before.Changes {
+		oldChanges[resourceKey(ch.Stack, ch.Address)] = ch
+	}
+	newChanges := make(map[string]bool, len(after.Changes))
+	for _, ch := range after.Changes {
+		key := resourceKey(ch.Stack, ch.Address)
+		newChanges[key] = true
+		prev, ok := oldChanges[key]
+		switch {
+		case !ok:
+			res.Added = append(res.Added, ch)
+		case actionLabel(prev) != actionLabel(ch):
+			res.Changed = append(res.Changed, ActionChange{Old: prev, New: ch})
+		default:
+			res.Unchanged++
+		}
+	}
+	for _, ch := range before.Changes {
+		if !newChanges[resourceKey(ch.Stack, ch.Address)] {
+			res.Dropped = append(res.Dropped, ch)
+		}
+	}
+
+	res.NewFindings = findingsOnlyIn(after.Findings, before.Findings)
+	res.ResolvedFindings = findingsOnlyIn(before.Findings, after.Findings)
+	return res
+}
+
+// NewFindingsAtOrAbove reports whether any newly introduced finding meets
+// threshold.
+func (r Result) NewFindingsAtOrAbove(threshold analyze.Severity) bool {
+	return analyze.ExceedsThreshold(r.NewFindings, threshold)
+}
+
+// findingsOnlyIn returns the findings in a without a match in b, sorted by
+// severity (highest first) and address.
+func findingsOnlyIn(a, b []analyze.Finding) []analyze.Finding {
+	inB := make(map[string]bool, len(b))
+	for _, f := range b {
+		inB[findingKey(f)] = true
+	}
+	var out []analyze.Finding
+	for _, f := range a {
+		if !inB[findingKey(f)] {
+			out = append(out, f)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Severity != out[j].Severity {
+			return out[i].Severity > out[j].Severity
+		}
+		return resourceKey(out[i].Stack, out[i].Address) < resourceKey(out[j].Stack, out[j].Address)
+	})
+	return out
+}
+
+// actionLabel is what must match for a resource's action to count as
+// unchanged: its classification, ordering for replacements, and whether it
+// was deferred.
+func actionLabel(ch parse.ResourceChange) string {
+	label := ch.Classification()
+	if ch.ReplaceOrder != "" {
+		label += "/" + ch.ReplaceOrder
+	}
+	if ch.Deferred() {
+		label += " (deferred)"
+	}
+	return label
+}
+
+// findingKey matches findings across the two plans by fingerprint, so a
+// severity override or reworded title does not make a finding look new.
+func findingKey(f analyze.Finding) string {
+	if f.Fingerprint != "" {
+		return f.Fingerprint
+	}
+	return analyze.Fingerprint(f)
+}
+
+func resourceKey(stack, address string) string {
+	return stack + "\x00" + address
+}
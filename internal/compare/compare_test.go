@@ -0,0 +1,113 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/sgr0691/diffy/internal/analyze"
+	"github.com/sgr0691/diffy/internal/parse"
+)
+
+func TestCompare(t *testing.T) {
+	before := []parse.ResourceChange{
+		{Address: "aws_instance.web", Type: "aws_instance", Action: parse.ActionUpdate},
+		{Address: "aws_s3_bucket.logs", Type: "aws_s3_bucket", Action: parse.ActionUpdate},
+		{Address: "aws_iam_role.ci", Type: "aws_iam_role", Action: parse.ActionCreate},
+		{Address: "aws_lb.public", Type: "aws_lb", Action: parse.ActionReplace, ReplaceOrder: parse.ReplaceCreateBeforeDestroy},
+	}
+	after := []parse.ResourceChange{
+		{Address: "aws_instance.web", Type: "aws_instance", Action: parse.ActionReplace, ReplaceOrder: parse.ReplaceDestroyBeforeCreate},
+		{Address: "aws_s3_bucket.logs", Type: "aws_s3_bucket", Action: parse.ActionUpdate},
+		{Address: "aws_db_instance.main", Type: "aws_db_instance", Action: parse.ActionDelete},
+		{Address: "aws_lb.public", Type: "aws_lb", Action: parse.ActionReplace, ReplaceOrder: parse.ReplaceDestroyBeforeCreate},
+	}
+
+	res := Compare(
+		Plan{Changes: before, Findings: analyze.Analyze(before)},
+		Plan{Changes: after, Findings: analyze.Analyze(after)},
+	)
+
+	if len(res.Added) != 1 || res.Added[0].Address != "aws_db_instance.main" {
+		t.Errorf("unexpected added: %+v", res.Added)
+	}
+	if len(res.Dropped) != 1 || res.Dropped[0].Address != "aws_iam_role.ci" {
+		t.Errorf("unexpected dropped: %+v", res.Dropped)
+	}
+	if len(res.Changed) != 2 {
+		t.Fatalf("expected 2 action changes, got %+v", res.Changed)
+	}
+	if ch := res.Changed[0]; ch.Old.Action != parse.ActionUpdate || ch.New.Action != parse.ActionReplace {
+		t.Errorf("expected update → replace, got %s → %s", ch.Old.Action, ch.New.Action)
+	}
+	if ch := res.Changed[1]; ch.New.Address != "aws_lb.public" {
+		t.Errorf("a replacement ordering switch should count as an action change, got %+v", ch)
+	}
+	if res.Unchanged != 1 {
+		t.Errorf("expected 1 unchanged, got %d", res.Unchanged)
+	}
+
+	newTitles := map[string]bool{}
+	for _, f := range res.NewFindings {
+		newTitles[f.Address+"|"+f.Title] = true
+	}
+	if !newTitles["aws_db_instance.main|Resource deletion detected"] || !newTitles["aws_instance.web|Resource replacement detected"] {
+		t.Errorf("unexpected new findings: %+v", res.NewFindings)
+	}
+	if res.NewFindings[0].Severity != analyze.SeverityCritical {
+		t.Errorf("new findings should be sorted by severity, got %+v", res.NewFindings)
+	}
+	if !res.NewFindingsAtOrAbove(analyze.SeverityCritical) {
+		t.Error("expected a new critical finding")
+	}
+
+	// The load balancer's CBD replacement (medium) became DBC (high).
+	var resolvedLB bool
+	for _, f := range res.ResolvedFindings {
+		if f.Address == "aws_lb.public" && f.Severity == analyze.SeverityMedium {
+			resolvedLB = true
+		}
+	}
+	if !resolvedLB {
+		t.Errorf("expected the medium load balancer finding to be resolved, got %+v", res.ResolvedFindings)
+	}
+}
+
+func TestCompareIdenticalPlans(t *testing.T) {
+	changes := []parse.ResourceChange{
+		{Address: "aws_db_instance.main", Type: "aws_db_instance", Action: parse.ActionDelete},
+	}
+	p := Plan{Changes: changes, Findings: analyze.Analyze(changes)}
+	res := Compare(p, p)
+	if !res.Empty() || res.Unchanged != 1 {
+		t.Errorf("expected no differences, got %+v", res)
+	}
+	if res.NewFindingsAtOrAbove(analyze.SeverityInfo) {
+		t.Error("existing findings must not gate --fail-on-new")
+	}
+}
+
+func TestCompareMatchesByStack(t *testing.T) {
+	before := []parse.ResourceChange{{Address: "aws_vpc.main", Action: parse.ActionUpdate, Stack: "prod"}}
+	after := []parse.ResourceChange{{Address: "aws_vpc.main", Action: parse.ActionUpdate, Stack: "staging"}}
+	res := Compare(Plan{Changes: before}, Plan{Changes: after})
+	if len(res.Added) != 1 || len(res.Dropped) != 1 {
+		t.Errorf("the same address in different stacks is a different resource, got %+v", res)
+	}
+}
+
+func TestCompareIgnoresSeverityOverrides(t *testing.T) {
+	changes := []parse.ResourceChange{{Address: "aws_sqs_queue.jobs", Type: "aws_sqs_queue", Action: parse.ActionDelete}}
+	overridden := analyze.DefaultRegistry()
+	if err := overridden.SetSeverity(analyze.RuleDeletion, analyze.SeverityCritical); err != nil {
+		t.Fatal(err)
+	}
+	reworded := overridden.Analyze(changes)
+	reworded[0].Title = "Queue deletion detected"
+
+	res := Compare(
+		Plan{Changes: changes, Findings: analyze.Analyze(changes)},
+		Plan{Changes: changes, Findings: reworded},
+	)
+	if len(res.NewFindings) != 0 || len(res.ResolvedFindings) != 0 {
+		t.Errorf("new = %+v, resolved = %+v", res.NewFindings, res.ResolvedFindings)
+	}
+}
@@ -0,0 +1,393 @@
+// Package config loads and validates Diffy's project configuration file,
+// .diffy.yaml.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sgr0691/diffy/internal/analyze"
+)
+
+// FileName is the configuration file Diffy looks for.
+const FileName = ".diffy.yaml"
+
+// Config is a project's Diffy configuration. Zero values mean "not set".
+type Config struct {
+	// Path is the file the configuration was read from.
+	Path string
+	// Format is the default output format: md, text or json.
+	Format string
+	// FailOn is the default --fail-on severity.
+	FailOn string
+	Rules  Rules
+	// StatefulTypes are resource types treated as stateful in addition to
+	// Diffy's own list. A trailing "*" matches a prefix.
+	StatefulTypes []string
+	// PublicPorts are ports public ingress is flagged on in addition to
+	// Diffy's own list.
+	PublicPorts []int
+	// Suppressions is the path of the suppression file. A relative path in
+	// the file is resolved against the configuration file's directory.
+	Suppressions string
+	// CustomRules are the rules declared in the file's custom_rules.
+	CustomRules []analyze.Rule
+}
+
+// Rules selects and tunes rules.
+type Rules struct {
+	// Enabled, when set, lists the only rules that run.
+	Enabled []string
+	// Disabled rules do not run.
+	Disabled []string
+	// Severity overrides the severity of every finding of a rule.
+	Severity map[string]analyze.Severity
+}
+
+// Problem is one thing wrong with a configuration file.
+type Problem struct {
+	Line    int
+	Field   string
+	Message string
+}
+
+func (p Problem) String() string {
+	var sb strings.Builder
+	if p.Line > 0 {
+		fmt.Fprintf(&sb, "line %d: ", p.Line)
+	}
+	if p.Field != "" {
+		sb.WriteString(p.Field + ": ")
+	}
+	sb.WriteString(p.Message)
+	return sb.String()
+}
+
+// ValidationError lists every problem found in a configuration or
+// suppression file.
+type ValidationError struct {
+	// Kind is "config" or "suppressions".
+	Kind     string
+	Path     string
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "invalid %s %s:", e.Kind, e.Path)
+	for _, p := range e.Problems {
+		sb.WriteString("\n  " + p.String())
+	}
+	return sb.String()
+}
+
+// Find returns the path of the configuration file in dir or the nearest of
+// its parents, or "" when there is none.
+func Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, FileName)
+		info, err := os.Stat(path)
+		switch {
+		case err == nil && !info.IsDir():
+			return path, nil
+		case err != nil && !errors.Is(err, fs.ErrNotExist):
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// Load reads and validates the configuration file at path.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(path, data)
+}
+
+// Parse validates and decodes configuration data read from path. Unknown
+// fields, values of the wrong type and unknown rule IDs are errors; all of
+// them are reported together in a *ValidationError.
+func Parse(path string, data []byte) (*Config, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %s", path, strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+
+	d := decoder{cfg: &Config{Path: path}, rules: analyze.DefaultRegistry()}
+	if len(doc.Content) > 0 {
+		d.decode(doc.Content[0])
+	}
+	if len(d.problems) > 0 {
+		return nil, &ValidationError{Kind: "config", Path: path, Problems: d.problems}
+	}
+	return d.cfg, nil
+}
+
+// Apply configures r: it registers the custom rules, selects the enabled
+// rules, applies severity overrides and passes on the extra stateful types
+// and public ports.
+func (c *Config) Apply(r *analyze.Registry) error {
+	for _, rule := range c.CustomRules {
+		if err := r.Register(rule); err != nil {
+			return err
+		}
+	}
+	if len(c.Rules.Enabled) > 0 {
+		enabled := make(map[string]bool, len(c.Rules.Enabled))
+		for _, id := range c.Rules.Enabled {
+			enabled[id] = true
+		}
+		for _, rule := range r.Rules() {
+			if !enabled[rule.ID()] {
+				if err := r.Disable(rule.ID()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for _, id := range c.Rules.Disabled {
+		if err := r.Disable(id); err != nil {
+			return err
+		}
+	}
+	for id, sev := range c.Rules.Severity {
+		if err := r.SetSeverity(id, sev); err != nil {
+			return err
+		}
+	}
+	r.SetSettings(analyze.Settings{StatefulTypes: c.StatefulTypes, PublicPorts: c.PublicPorts})
+	return nil
+}
+
+var (
+	topLevelFields = []string{"format", "fail_on", "rules", "stateful_types", "public_ports", "suppressions", "custom_rules"}
+	rulesFields    = []string{"enabled", "disabled", "severity"}
+
+	resourceTypePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*\*?$`)
+)
+
+type decoder struct {
+	cfg          *Config
+	suppressions []analyze.Suppression
+	rules        *analyze.Registry
+	problems     []Problem
+}
+
+func (d *decoder) problem(n *yaml.Node, field, format string, args ...any) {
+	d.problems = append(d.problems, Problem{Line: n.Line, Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (d *decoder) decode(root *yaml.Node) {
+	if root.Kind == yaml.ScalarNode && root.ShortTag() == "!!null" {
+		return // empty file
+	}
+	// Custom rules first, so the rules section can refer to them.
+	if root.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if root.Content[i].Value == "custom_rules" {
+				d.decodeCustomRules(root.Content[i+1])
+				break
+			}
+		}
+	}
+	d.fields(root, "", topLevelFields, func(key string, value *yaml.Node) {
+		switch key {
+		case "format":
+			if s, ok := d.str(value, key); ok {
+				if s != "md" && s != "text" && s != "json" {
+					d.problem(value, key, "must be md, text, or json, got %q", s)
+				}
+				d.cfg.Format = s
+			}
+		case "fail_on":
+			if s, ok := d.str(value, key); ok {
+				if _, ok := analyze.ParseSeverity(s); !ok {
+					d.problem(value, key, "must be info, low, medium, high, or critical, got %q", s)
+				}
+				d.cfg.FailOn = s
+			}
+		case "rules":
+			d.decodeRules(value)
+		case "stateful_types":
+			d.seq(value, key, func(field string, item *yaml.Node) {
+				if s, ok := d.str(item, field); ok {
+					if !resourceTypePattern.MatchString(s) {
+						d.problem(item, field, "%q is not a resource type, e.g. aws_opensearch_domain or aws_opensearch_*", s)
+					}
+					d.cfg.StatefulTypes = append(d.cfg.StatefulTypes, s)
+				}
+			})
+		case "public_ports":
+			d.seq(value, key, func(field string, item *yaml.Node) {
+				var port int
+				if item.Kind != yaml.ScalarNode || item.ShortTag() != "!!int" || item.Decode(&port) != nil {
+					d.problem(item, field, "must be a port number, got %s", describe(item))
+					return
+				}
+				if port < 1 || port > 65535 {
+					d.problem(item, field, "port %d is out of range 1-65535", port)
+				}
+				d.cfg.PublicPorts = append(d.cfg.PublicPorts, port)
+			})
+		case "suppressions":
+			if s, ok := d.str(value, key); ok {
+				if s == "" {
+					d.problem(value, key, "must not be empty")
+					return
+				}
+				if !filepath.IsAbs(s) {
+					s = filepath.Join(filepath.Dir(d.cfg.Path), s)
+				}
+				d.cfg.Suppressions = s
+			}
+		case "custom_rules":
+			// Decoded above.
+		}
+	})
+}
+
+func (d *decoder) decodeRules(n *yaml.Node) {
+	listed := make(map[string]string)
+	d.fields(n, "rules", rulesFields, func(key string, value *yaml.Node) {
+		field := "rules." + key
+		switch key {
+		case "enabled", "disabled":
+			d.seq(value, field, func(itemField string, item *yaml.Node) {
+				id, ok := d.ruleID(item, itemField)
+				if !ok {
+					return
+				}
+				if other, ok := listed[id]; ok && other != key {
+					d.problem(item, itemField, "rule %s is both enabled and disabled", id)
+				}
+				listed[id] = key
+				if key == "enabled" {
+					d.cfg.Rules.Enabled = append(d.cfg.Rules.Enabled, id)
+				} else {
+					d.cfg.Rules.Disabled = append(d.cfg.Rules.Disabled, id)
+				}
+			})
+		case "severity":
+			d.fields(value, field, nil, func(id string, sevNode *yaml.Node) {
+				if _, ok := d.rules.Lookup(id); !ok {
+					d.problem(sevNode, field, "unknown rule %q (run diffy rules to list them)", id)
+					return
+				}
+				s, ok := d.str(sevNode, field+"."+id)
+				if !ok {
+					return
+				}
+				sev, ok := analyze.ParseSeverity(s)
+				if !ok {
+					d.problem(sevNode, field+"."+id, "must be info, low, medium, high, or critical, got %q", s)
+					return
+				}
+				if d.cfg.Rules.Severity == nil {
+					d.cfg.Rules.Severity = make(map[string]analyze.Severity)
+				}
+				d.cfg.Rules.Severity[id] = sev
+			})
+		}
+	})
+}
+
+// fields calls fn for each key of a mapping. When allowed is non-nil, other
+// keys are reported as unknown.
+func (d *decoder) fields(n *yaml.Node, field string, allowed []string, fn func(key string, value *yaml.Node)) {
+	if n.Kind != yaml.MappingNode {
+		name := field
+		if name == "" {
+			name = "config"
+		}
+		d.problem(n, field, "%s must be a mapping, got %s", name, describe(n))
+		return
+	}
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		keyNode, value := n.Content[i], n.Content[i+1]
+		key := keyNode.Value
+		path := key
+		if field != "" {
+			path = field + "." + key
+		}
+		if seen[key] {
+			d.problem(keyNode, path, "duplicate field")
+			continue
+		}
+		seen[key] = true
+		if allowed != nil && !slices.Contains(allowed, key) {
+			d.problem(keyNode, path, "unknown field (allowed: %s)", strings.Join(allowed, ", "))
+			continue
+		}
+		fn(key, value)
+	}
+}
+
+// seq calls fn for each item of a sequence.
+func (d *decoder) seq(n *yaml.Node, field string, fn func(field string, item *yaml.Node)) {
+	if n.Kind != yaml.SequenceNode {
+		d.problem(n, field, "must be a list, got %s", describe(n))
+		return
+	}
+	for i, item := range n.Content {
+		fn(fmt.Sprintf("%s[%d]", field, i), item)
+	}
+}
+
+func (d *decoder) str(n *yaml.Node, field string) (string, bool) {
+	if n.Kind != yaml.ScalarNode || n.ShortTag() != "!!str" {
+		d.problem(n, field, "must be a string, got %s", describe(n))
+		return "", false
+	}
+	return n.Value, true
+}
+
+func (d *decoder) ruleID(n *yaml.Node, field string) (string, bool) {
+	id, ok := d.str(n, field)
+	if !ok {
+		return "", false
+	}
+	if _, ok := d.rules.Lookup(id); !ok {
+		d.problem(n, field, "unknown rule %q (run diffy rules to list them)", id)
+		return "", false
+	}
+	return id, true
+}
+
+// describe names a node's kind for error messages, e.g. "a list" or
+// "string \"yes\"".
+func describe(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	case yaml.AliasNode:
+		return "an alias"
+	}
+	switch n.ShortTag() {
+	case "!!null":
+		return "nothing"
+	case "!!str":
+		return fmt.Sprintf("string %q", n.Value)
+	}
+	return fmt.Sprintf("%s %s", strings.TrimPrefix(n.ShortTag(), "!!"), n.Value)
+}
@@ -0,0 +1,421 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgr0691/diffy/internal/analyze"
+	"github.com/sgr0691/diffy/internal/parse"
+)
+
+const validConfig = `
+format: text
+fail_on: high
+rules:
+  disabled: [DIFFY-CORE-011]
+  severity:
+    DIFFY-CORE-001: critical
+stateful_types:
+  - aws_instance
+  - aws_opensearch_*
+public_ports: [8080]
+`
+
+func TestParseValidConfig(t *testing.T) {
+	cfg, err := Parse(".diffy.yaml", []byte(validConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Config{
+		Path:   ".diffy.yaml",
+		Format: "text",
+		FailOn: "high",
+		Rules: Rules{
+			Disabled: []string{analyze.RuleTagOnly},
+			Severity: map[string]analyze.Severity{analyze.RuleDeletion: analyze.SeverityCritical},
+		},
+		StatefulTypes: []string{"aws_instance", "aws_opensearch_*"},
+		PublicPorts:   []int{8080},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("config = %+v, want %+v", cfg, want)
+	}
+
+	for _, empty := range []string{"", "# nothing yet\n"} {
+		cfg, err := Parse(".diffy.yaml", []byte(empty))
+		if err != nil || cfg.Format != "" || cfg.Rules.Severity != nil {
+			t.Errorf("Parse(%q) = %+v, %v", empty, cfg, err)
+		}
+	}
+}
+
+func TestParseReportsEveryProblem(t *testing.T) {
+	data := `format: html
+fail_on: hgh
+colour: red
+rules:
+  disabled: [DIFFY-CORE-011, DIFFY-AWS-999]
+  enabled: [DIFFY-CORE-011]
+  severity:
+    DIFFY-CORE-002: urgent
+    DIFFY-NOPE-001: low
+stateful_types: [aws_opensearch_*, "Bad Type"]
+public_ports: [8080, 70000, x]
+`
+	_, err := Parse(".diffy.yaml", []byte(data))
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	var got []string
+	for _, p := range verr.Problems {
+		got = append(got, p.String())
+	}
+	want := []string{
+		`line 1: format: must be md, text, or json, got "html"`,
+		`line 2: fail_on: must be info, low, medium, high, or critical, got "hgh"`,
+		`line 3: colour: unknown field (allowed: format, fail_on, rules, stateful_types, public_ports, suppressions, custom_rules)`,
+		`line 5: rules.disabled[1]: unknown rule "DIFFY-AWS-999" (run diffy rules to list them)`,
+		`line 6: rules.enabled[0]: rule DIFFY-CORE-011 is both enabled and disabled`,
+		`line 8: rules.severity.DIFFY-CORE-002: must be info, low, medium, high, or critical, got "urgent"`,
+		`line 9: rules.severity: unknown rule "DIFFY-NOPE-001" (run diffy rules to list them)`,
+		`line 10: stateful_types[1]: "Bad Type" is not a resource type, e.g. aws_opensearch_domain or aws_opensearch_*`,
+		`line 11: public_ports[1]: port 70000 is out of range 1-65535`,
+		`line 11: public_ports[2]: must be a port number, got string "x"`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if !strings.HasPrefix(err.Error(), "invalid config .diffy.yaml:\n  line 1: format:") {
+		t.Errorf("error = %q", err)
+	}
+}
+
+func TestParseRejectsWrongShapes(t *testing.T) {
+	tests := []struct {
+		data, want string
+	}{
+		{"- format: md\n", "line 1: config must be a mapping, got a list"},
+		{"rules: [DIFFY-CORE-001]\n", "line 1: rules: rules must be a mapping, got a list"},
+		{"public_ports: 8080\n", "line 1: public_ports: must be a list, got int 8080"},
+		{"format: md\nformat: json\n", "line 2: format: duplicate field"},
+		{"format: [md\n", "invalid config .diffy.yaml: line 1: did not find expected ',' or ']'"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(".diffy.yaml", []byte(tt.data))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q) error = %v, want it to contain %q", tt.data, err, tt.want)
+		}
+	}
+}
+
+func TestFindSearchesParents(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "live", "prod")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if path, err := Find(nested); err != nil || path != "" {
+		t.Fatalf("Find without a config = %q, %v", path, err)
+	}
+
+	want := filepath.Join(root, FileName)
+	if err := os.WriteFile(want, []byte("format: json\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path, err := Find(nested)
+	if err != nil || path != want {
+		t.Fatalf("Find = %q, %v, want %q", path, err, want)
+	}
+	cfg, err := Load(path)
+	if err != nil || cfg.Format != "json" || cfg.Path != want {
+		t.Errorf("Load = %+v, %v", cfg, err)
+	}
+}
+
+func TestApplyConfiguresRegistry(t *testing.T) {
+	cfg, err := Parse(".diffy.yaml", []byte(validConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := analyze.DefaultRegistry()
+	if err := cfg.Apply(r); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Enabled(analyze.RuleTagOnly) {
+		t.Error("disabled rule is still enabled")
+	}
+	if got := r.Severity(analyze.RuleDeletion); got != analyze.SeverityCritical {
+		t.Errorf("deletion severity = %s, want critical", got)
+	}
+
+	changes := []parse.ResourceChange{
+		// Stateful through stateful_types: replacement becomes critical.
+		{Address: "aws_instance.web", Type: "aws_instance", Action: parse.ActionReplace},
+		{Address: "aws_opensearch_domain.logs", Type: "aws_opensearch_domain", Action: parse.ActionReplace},
+		// Public through public_ports.
+		{
+			Address: "aws_security_group_rule.app",
+			Type:    "aws_security_group_rule",
+			Action:  parse.ActionCreate,
+			After:   json.RawMessage(`{"type":"ingress","from_port":8080,"to_port":8080,"protocol":"tcp","cidr_blocks":["0.0.0.0/0"]}`),
+		},
+		// Overridden severity.
+		{Address: "aws_sqs_queue.jobs", Type: "aws_sqs_queue", Action: parse.ActionDelete},
+		// Disabled rule.
+		{Address: "aws_vpc.main", Type: "aws_vpc", Action: parse.ActionUpdate, ChangePaths: []string{"tags.team"}},
+	}
+	var got []string
+	for _, f := range r.Analyze(changes) {
+		got = append(got, f.Address+" "+f.RuleID+" "+f.Severity.String())
+	}
+	want := []string{
+		"aws_instance.web DIFFY-CORE-002 critical",
+		"aws_opensearch_domain.logs DIFFY-CORE-002 critical",
+		"aws_security_group_rule.app DIFFY-AWS-001 high",
+		"aws_sqs_queue.jobs DIFFY-CORE-001 critical",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findings = %v, want %v", got, want)
+	}
+}
+
+func TestApplyEnabledIsAnAllowList(t *testing.T) {
+	cfg, err := Parse(".diffy.yaml", []byte("rules:\n  enabled: [DIFFY-CORE-001]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := analyze.DefaultRegistry()
+	if err := cfg.Apply(r); err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range r.Rules() {
+		if want := rule.ID() == analyze.RuleDeletion; r.Enabled(rule.ID()) != want {
+			t.Errorf("%s enabled = %v, want %v", rule.ID(), r.Enabled(rule.ID()), want)
+		}
+	}
+}
+
+func TestParseSuppressions(t *testing.T) {
+	data := `suppressions:
+  - rule: DIFFY-AWS-002
+    address: module.edge.aws_lb.*
+    justification: Public ALB for the marketing site
+    owner: platform-team
+    expires: 2026-12-31
+  - module: module.legacy
+    resource_type: "aws_*"
+    justification: Being decommissioned
+    owner: "@ops"
+    expires: "2026-06-30"
+`
+	got, err := ParseSuppressions("s.yaml", []byte(data), analyze.DefaultRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []analyze.Suppression{
+		{
+			Rule:          analyze.RuleInternetFacingLB,
+			Address:       "module.edge.aws_lb.*",
+			Justification: "Public ALB for the marketing site",
+			Owner:         "platform-team",
+			Expires:       time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC),
+			Source:        "s.yaml:2",
+		},
+		{
+			Module:        "module.legacy",
+			ResourceType:  "aws_*",
+			Justification: "Being decommissioned",
+			Owner:         "@ops",
+			Expires:       time.Date(2026, 6, 30, 0, 0, 0, 0, time.UTC),
+			Source:        "s.yaml:7",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("suppressions = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseSuppressionsRequiresFields(t *testing.T) {
+	data := `suppressions:
+  - rule: DIFFY-AWS-002
+    owner: ""
+  - justification: no matcher
+    owner: me
+    expires: 31/12/2026
+    reason: typo
+`
+	_, err := ParseSuppressions("s.yaml", []byte(data), analyze.DefaultRegistry())
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	var got []string
+	for _, p := range verr.Problems {
+		got = append(got, p.String())
+	}
+	want := []string{
+		`line 3: suppressions[0].owner: must not be empty`,
+		`line 2: suppressions[0]: justification is required`,
+		`line 2: suppressions[0]: expires is required`,
+		`line 6: suppressions[1].expires: must be a date like 2026-12-31, got "31/12/2026"`,
+		`line 7: suppressions[1].reason: unknown field (allowed: rule, address, resource_type, module, justification, owner, expires)`,
+		`line 4: suppressions[1]: needs at least one of rule, address, resource_type, module`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if !strings.HasPrefix(err.Error(), "invalid suppressions s.yaml:") {
+		t.Errorf("error = %q", err)
+	}
+}
+
+func TestSuppressionsPathIsRelativeToConfig(t *testing.T) {
+	cfg, err := Parse(filepath.Join("repo", FileName), []byte("suppressions: "+SuppressionsFileName+"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join("repo", SuppressionsFileName); cfg.Suppressions != want {
+		t.Errorf("suppressions path = %q, want %q", cfg.Suppressions, want)
+	}
+}
+
+func TestParseCustomRules(t *testing.T) {
+	data := `rules:
+  severity:
+    ACME-KMS-001: critical
+custom_rules:
+  - id: ACME-KMS-001
+    severity: high
+    title: KMS deletion window shortened
+    description: '{{.Address}} shortens its deletion window to {{.After "deletion_window_in_days"}} days.'
+    match:
+      types: [aws_kms_key]
+      actions: [update, replace]
+      paths: [deletion_window_in_days]
+    conditions:
+      - path: deletion_window_in_days
+        op: decreased
+`
+	cfg, err := Parse(".diffy.yaml", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.CustomRules) != 1 || cfg.CustomRules[0].ID() != "ACME-KMS-001" {
+		t.Fatalf("custom rules = %v", cfg.CustomRules)
+	}
+
+	r := analyze.DefaultRegistry()
+	if err := cfg.Apply(r); err != nil {
+		t.Fatal(err)
+	}
+	findings := r.Analyze([]parse.ResourceChange{{
+		Address:     "aws_kms_key.main",
+		Type:        "aws_kms_key",
+		Action:      parse.ActionUpdate,
+		Before:      json.RawMessage(`{"deletion_window_in_days":30}`),
+		After:       json.RawMessage(`{"deletion_window_in_days":7}`),
+		ChangePaths: []string{"deletion_window_in_days"},
+	}})
+	if len(findings) != 1 {
+		t.Fatalf("expected 1 finding, got %+v", findings)
+	}
+	f := findings[0]
+	if f.RuleID != "ACME-KMS-001" || f.Severity != analyze.SeverityCritical ||
+		f.Description != "aws_kms_key.main shortens its deletion window to 7 days." {
+		t.Errorf("unexpected finding: %+v", f)
+	}
+}
+
+func TestParseCustomRulesReportsProblems(t *testing.T) {
+	data := `custom_rules:
+  - id: DIFFY-CORE-099
+    severity: urgent
+    title: t
+    match: {actions: [destroy], colour: red}
+    conditions:
+      - {path: a, op: bigger}
+  - id: ACME-X-001
+    severity: low
+    title: '{{.Adress}}'
+    description: d
+  - id: ACME-X-002
+    severity: low
+    title: t
+    description: d
+    conditions:
+      - {path: b, op: cidr_contains, value: nope, of: during}
+`
+	_, err := Parse(".diffy.yaml", []byte(data))
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	var got []string
+	for _, p := range verr.Problems {
+		got = append(got, p.String())
+	}
+	want := []string{
+		`line 2: custom_rules[0].id: the DIFFY- prefix is reserved for built-in rules, e.g. use ACME-KMS-001`,
+		`line 3: custom_rules[0].severity: must be info, low, medium, high, or critical, got "urgent"`,
+		`line 5: custom_rules[0].match.actions[0]: must be one of create, update, replace, delete, move, import, forget, read, got "destroy"`,
+		`line 5: custom_rules[0].match.colour: unknown field (allowed: types, actions, paths)`,
+		`line 7: custom_rules[0].conditions[0].op: must be one of equals, changed, increased, decreased, contains, cidr_contains, got "bigger"`,
+		`line 2: custom_rules[0]: description is required`,
+		`line 8: custom_rules[1]: title: executing "title" at <.Adress>: can't evaluate field Adress in type analyze.RuleData`,
+		`line 17: custom_rules[2].conditions[0].of: must be before or after, got "during"`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSuppressCustomRule(t *testing.T) {
+	cfg, err := Parse(".diffy.yaml", []byte(`custom_rules:
+  - id: ACME-KMS-001
+    severity: high
+    title: KMS key changed
+    description: '{{.Address}} changes.'
+    match:
+      types: [aws_kms_key]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := analyze.DefaultRegistry()
+	if err := cfg.Apply(r); err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte(`suppressions:
+  - rule: ACME-KMS-001
+    justification: Key rotation is reviewed separately
+    owner: security
+    expires: 2099-12-31
+`)
+	// Without the custom rules the ID is unknown, and only that is reported.
+	_, err = ParseSuppressions("s.yaml", data, analyze.DefaultRegistry())
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Problems) != 1 || !strings.Contains(verr.Problems[0].Message, `unknown rule "ACME-KMS-001"`) {
+		t.Errorf("error without the custom rules = %v", err)
+	}
+	suppressions, err := ParseSuppressions("s.yaml", data, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	findings := r.Analyze([]parse.ResourceChange{{Address: "aws_kms_key.main", Type: "aws_kms_key", Action: parse.ActionUpdate}})
+	findings = r.Suppress(findings, suppressions, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	active, suppressed := analyze.SplitSuppressed(findings)
+	if len(active) != 0 || len(suppressed) != 1 || suppressed[0].RuleID != "ACME-KMS-001" {
+		t.Errorf("active = %+v, suppressed = %+v", active, suppressed)
+	}
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sgr0691/diffy/internal/analyze"
+	"github.com/sgr0691/diffy/internal/parse"
+)
+
+var (
+	customRuleFields = []string{"id", "severity", "title", "description", "match", "conditions"}
+	matchFields      = []string{"types", "actions", "paths"}
+	conditionFields  = []string{"path", "op", "value", "of"}
+
+	ruleActions = []parse.Action{
+		parse.ActionCreate, parse.ActionUpdate, parse.ActionReplace, parse.ActionDelete,
+		parse.ActionMove, parse.ActionImport, parse.ActionForget, parse.ActionRead,
+	}
+)
+
+// builtinPrefix is reserved for Diffy's own rule IDs.
+const builtinPrefix = "DIFFY-"
+
+// decodeCustomRules decodes the custom_rules list and registers each rule
+// with d.rules, so the rules section can refer to them.
+func (d *decoder) decodeCustomRules(n *yaml.Node) {
+	d.seq(n, "custom_rules", func(field string, item *yaml.Node) {
+		before := len(d.problems)
+		spec, idNode := d.decodeCustomRule(field, item)
+		if len(d.problems) > before || item.Kind != yaml.MappingNode {
+			return
+		}
+		rule, err := analyze.NewCustomRule(spec)
+		if err != nil {
+			d.problem(item, field, "%s", err)
+			return
+		}
+		if err := d.rules.Register(rule); err != nil {
+			d.problem(idNode, field+".id", "%s", err)
+			return
+		}
+		d.cfg.CustomRules = append(d.cfg.CustomRules, rule)
+	})
+}
+
+func (d *decoder) decodeCustomRule(field string, n *yaml.Node) (analyze.RuleSpec, *yaml.Node) {
+	var spec analyze.RuleSpec
+	var idNode *yaml.Node
+	set := make(map[string]bool)
+	d.fields(n, field, customRuleFields, func(key string, value *yaml.Node) {
+		path := field + "." + key
+		set[key] = true
+		switch key {
+		case "id":
+			idNode = value
+			if id, ok := d.str(value, path); ok {
+				if strings.HasPrefix(id, builtinPrefix) {
+					d.problem(value, path, "the %s prefix is reserved for built-in rules, e.g. use ACME-KMS-001", builtinPrefix)
+				}
+				spec.ID = id
+			}
+		case "severity":
+			if s, ok := d.str(value, path); ok {
+				sev, ok := analyze.ParseSeverity(s)
+				if !ok {
+					d.problem(value, path, "must be info, low, medium, high, or critical, got %q", s)
+				}
+				spec.Severity = sev
+			}
+		case "title":
+			spec.Title, _ = d.str(value, path)
+		case "description":
+			spec.Description, _ = d.str(value, path)
+		case "match":
+			d.decodeRuleMatch(path, value, &spec)
+		case "conditions":
+			d.seq(value, path, func(condField string, item *yaml.Node) {
+				if c, ok := d.decodeCondition(condField, item); ok {
+					spec.Conditions = append(spec.Conditions, c)
+				}
+			})
+		}
+	})
+	if n.Kind != yaml.MappingNode {
+		return spec, idNode
+	}
+	for _, required := range []string{"id", "severity", "title", "description"} {
+		if !set[required] {
+			d.problem(n, field, "%s is required", required)
+		}
+	}
+	return spec, idNode
+}
+
+func (d *decoder) decodeRuleMatch(field string, n *yaml.Node, spec *analyze.RuleSpec) {
+	d.fields(n, field, matchFields, func(key string, value *yaml.Node) {
+		path := field + "." + key
+		d.seq(value, path, func(itemField string, item *yaml.Node) {
+			s, ok := d.str(item, itemField)
+			if !ok {
+				return
+			}
+			if strings.TrimSpace(s) == "" {
+				d.problem(item, itemField, "must not be empty")
+				return
+			}
+			switch key {
+			case "types":
+				spec.Types = append(spec.Types, s)
+			case "actions":
+				action := parse.Action(s)
+				if !slices.Contains(ruleActions, action) {
+					d.problem(item, itemField, "must be one of %s, got %q", actionList(), s)
+					return
+				}
+				spec.Actions = append(spec.Actions, action)
+			case "paths":
+				spec.Paths = append(spec.Paths, s)
+			}
+		})
+	})
+}
+
+func (d *decoder) decodeCondition(field string, n *yaml.Node) (analyze.Condition, bool) {
+	var c analyze.Condition
+	before := len(d.problems)
+	set := make(map[string]bool)
+	d.fields(n, field, conditionFields, func(key string, value *yaml.Node) {
+		path := field + "." + key
+		set[key] = true
+		switch key {
+		case "path":
+			c.Path, _ = d.str(value, path)
+		case "op":
+			if s, ok := d.str(value, path); ok {
+				c.Op = analyze.Operator(s)
+				if !slices.Contains(analyze.Operators, c.Op) {
+					d.problem(value, path, "must be one of %s, got %q", operatorList(), s)
+				}
+			}
+		case "value":
+			if err := value.Decode(&c.Value); err != nil {
+				d.problem(value, path, "%s", strings.TrimPrefix(err.Error(), "yaml: "))
+			}
+		case "of":
+			if s, ok := d.str(value, path); ok {
+				if s != "before" && s != "after" {
+					d.problem(value, path, "must be before or after, got %q", s)
+				}
+				c.Before = s == "before"
+			}
+		}
+	})
+	if n.Kind != yaml.MappingNode {
+		return c, false
+	}
+	for _, required := range []string{"path", "op"} {
+		if !set[required] {
+			d.problem(n, field, "%s is required", required)
+		}
+	}
+	return c, len(d.problems) == before
+}
+
+func actionList() string {
+	names := make([]string, len(ruleActions))
+	for i, a := range ruleActions {
+		names[i] = string(a)
+	}
+	return strings.Join(names, ", ")
+}
+
+func operatorList() string {
+	names := make([]string, len(analyze.Operators))
+	for i, op := range analyze.Operators {
+		names[i] = string(op)
+	}
+	return strings.Join(names, ", ")
+}
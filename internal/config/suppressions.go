@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sgr0691/diffy/internal/analyze"
+)
+
+// SuppressionsFileName is the conventional name of a suppression file.
+const SuppressionsFileName = ".diffy-suppressions.yaml"
+
+var (
+	suppressionFields = []string{"rule", "address", "resource_type", "module", "justification", "owner", "expires"}
+	matcherFields     = []string{"rule", "address", "resource_type", "module"}
+)
+
+// LoadSuppressions reads and validates the suppression file at path. Rule
+// IDs are checked against rules, which should hold any custom rules too.
+func LoadSuppressions(path string, rules *analyze.Registry) ([]analyze.Suppression, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSuppressions(path, data, rules)
+}
+
+// ParseSuppressions validates and decodes suppression data read from path.
+// Every entry needs at least one matcher, a justification, an owner and an
+// expiry date; expired entries are valid and reported when Diffy runs.
+func ParseSuppressions(path string, data []byte, rules *analyze.Registry) ([]analyze.Suppression, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid suppressions %s: %s", path, strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+
+	d := decoder{cfg: &Config{Path: path}, rules: rules}
+	if len(doc.Content) > 0 {
+		d.decodeSuppressionFile(doc.Content[0])
+	}
+	if len(d.problems) > 0 {
+		return nil, &ValidationError{Kind: "suppressions", Path: path, Problems: d.problems}
+	}
+	return d.suppressions, nil
+}
+
+func (d *decoder) decodeSuppressionFile(root *yaml.Node) {
+	if root.Kind == yaml.ScalarNode && root.ShortTag() == "!!null" {
+		return // empty file
+	}
+	d.fields(root, "", []string{"suppressions"}, func(key string, value *yaml.Node) {
+		d.seq(value, key, d.decodeSuppression)
+	})
+}
+
+func (d *decoder) decodeSuppression(field string, n *yaml.Node) {
+	s := analyze.Suppression{Source: fmt.Sprintf("%s:%d", d.cfg.Path, n.Line)}
+	set := make(map[string]bool)
+	d.fields(n, field, suppressionFields, func(key string, value *yaml.Node) {
+		path := field + "." + key
+		if key == "rule" {
+			if id, ok := d.ruleID(value, path); ok {
+				s.Rule = id
+				set[key] = true
+			}
+			return
+		}
+		if key == "expires" && value.Kind == yaml.ScalarNode && value.ShortTag() == "!!timestamp" {
+			// Unquoted dates are YAML timestamps.
+			value = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value.Value, Line: value.Line}
+		}
+		v, ok := d.str(value, path)
+		if !ok {
+			return
+		}
+		if strings.TrimSpace(v) == "" {
+			d.problem(value, path, "must not be empty")
+			return
+		}
+		set[key] = true
+		switch key {
+		case "address":
+			s.Address = v
+		case "resource_type":
+			s.ResourceType = v
+		case "module":
+			s.Module = v
+		case "justification":
+			s.Justification = v
+		case "owner":
+			s.Owner = v
+		case "expires":
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				d.problem(value, path, "must be a date like 2026-12-31, got %q", v)
+				return
+			}
+			s.Expires = t
+		}
+	})
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+
+	if !slices.ContainsFunc(matcherFields, func(f string) bool { return set[f] || d.reported(field+"."+f) }) {
+		d.problem(n, field, "needs at least one of %s", strings.Join(matcherFields, ", "))
+	}
+	for _, required := range []string{"justification", "owner", "expires"} {
+		if !set[required] && !d.reported(field+"."+required) {
+			d.problem(n, field, "%s is required", required)
+		}
+	}
+	d.suppressions = append(d.suppressions, s)
+}
+
+// reported reports whether a problem was already recorded for field.
+func (d *decoder) reported(field string) bool {
+	for _, p := range d.problems {
+		if p.Field == field {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,248 @@
+// Package graph builds a resource dependency graph from the references a
+// plan's configuration records and the depends_on of its prior state, so
+// Diffy can tell what else a replacement or deletion may affect.
+package graph
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/sgr0691/diffy/internal/parse"
+)
+
+// Dependent is a resource instance that depends, directly or transitively,
+// on another.
+type Dependent struct {
+	Address string `json:"address"`
+	// Action is the dependent's planned action, or "" when this plan does
+	// not change it.
+	Action parse.Action `json:"action,omitempty"`
+}
+
+// Changed reports whether the plan also changes the dependent.
+func (d Dependent) Changed() bool {
+	return d.Action != ""
+}
+
+// Graph links configuration objects to the objects that refer to them.
+// Nodes are absolute configuration addresses: resources (e.g.
+// "module.network.aws_subnet.private"), module input variables
+// ("module.network.var.vpc_id") and module outputs
+// ("module.network.output.subnet_ids"). Passing through variables and
+// outputs lets references cross module boundaries.
+type Graph struct {
+	// dependents maps a node to the nodes that refer to it.
+	dependents map[string][]string
+	// resources marks the nodes that are managed resources.
+	resources map[string]bool
+	// instances maps a resource's configuration address to its instance
+	// addresses known from prior state and the plan.
+	instances map[string][]string
+	// planned maps instance addresses to their planned action.
+	planned map[string]parse.Action
+}
+
+// Build returns the dependency graph of plan. References through locals are
+// not recorded in plan JSON; prior state's depends_on covers those for
+// resources that already exist.
+func Build(plan *parse.Plan) *Graph {
+	g := &Graph{
+		dependents: make(map[string][]string),
+		resources:  make(map[string]bool),
+		instances:  make(map[string][]string),
+		planned:    make(map[string]parse.Action),
+	}
+
+	for _, r := range plan.Configuration.Resources {
+		node := joinNode(r.Module, r.Address)
+		if r.Mode != parse.ModeData {
+			g.resources[node] = true
+		}
+		for _, ref := range r.References {
+			g.link(resolve(r.Module, ref), node)
+		}
+	}
+	for _, call := range plan.Configuration.ModuleCalls {
+		parent := parentModule(call.Module)
+		for input, refs := range call.Inputs {
+			for _, ref := range refs {
+				g.link(resolve(parent, ref), joinNode(call.Module, "var."+input))
+			}
+		}
+	}
+	for _, o := range plan.Configuration.Outputs {
+		for _, ref := range o.References {
+			g.link(resolve(o.Module, ref), joinNode(o.Module, "output."+o.Name))
+		}
+	}
+
+	for _, r := range plan.PriorState {
+		node, ok := g.addInstance(r.Address)
+		if !ok {
+			continue
+		}
+		// depends_on in state holds absolute configuration addresses.
+		for _, dep := range r.DependsOn {
+			g.link(dep, node)
+		}
+	}
+	for _, ch := range plan.ResourceChanges {
+		if _, ok := g.addInstance(ch.Address); ok {
+			g.planned[ch.Address] = ch.Action
+		}
+	}
+	return g
+}
+
+// Dependents returns the resource instances that depend on the resource
+// instance at address, directly or transitively, sorted by address. Other
+// instances of the same resource and data sources are not included.
+func (g *Graph) Dependents(address string) []Dependent {
+	start := configAddress(address)
+	if start == "" {
+		return nil
+	}
+
+	seen := map[string]bool{start: true}
+	queue := []string{start}
+	var affected []string
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, next := range g.dependents[node] {
+			if seen[next] {
+				continue
+			}
+			seen[next] = true
+			queue = append(queue, next)
+			if g.resources[next] {
+				affected = append(affected, next)
+			}
+		}
+	}
+
+	var out []Dependent
+	for _, node := range affected {
+		instances := g.instances[node]
+		if len(instances) == 0 {
+			// Not in state or the plan, e.g. a resource with count = 0.
+			continue
+		}
+		for _, addr := range instances {
+			out = append(out, Dependent{Address: addr, Action: g.planned[addr]})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Address < out[j].Address })
+	return out
+}
+
+func (g *Graph) link(from, to string) {
+	if from == "" || from == to {
+		return
+	}
+	for _, existing := range g.dependents[from] {
+		if existing == to {
+			return
+		}
+	}
+	g.dependents[from] = append(g.dependents[from], to)
+}
+
+// addInstance records a resource instance and returns its configuration
+// address. Data sources are not recorded.
+func (g *Graph) addInstance(address string) (string, bool) {
+	addr, err := parse.ParseAddress(address)
+	if err != nil || addr.Mode == parse.ModeData {
+		return "", false
+	}
+	node := addr.ConfigAddress()
+	g.resources[node] = true
+	for _, existing := range g.instances[node] {
+		if existing == address {
+			return node, true
+		}
+	}
+	g.instances[node] = append(g.instances[node], address)
+	return node, true
+}
+
+// resolve turns a reference as written in module into the node it refers
+// to, or "" for references that are not graph nodes (locals, count.index,
+// each.key, path.module and the like).
+func resolve(module, ref string) string {
+	parts := strings.Split(stripIndexes(ref), ".")
+	if len(parts) < 2 {
+		return ""
+	}
+	switch parts[0] {
+	case "var":
+		return joinNode(module, "var."+parts[1])
+	case "module":
+		// "module.x" alone is also listed next to "module.x.out"; the
+		// output-level reference is the precise one.
+		if len(parts) < 3 {
+			return ""
+		}
+		return joinNode(module, "module."+parts[1]+".output."+parts[2])
+	case "data":
+		if len(parts) < 3 {
+			return ""
+		}
+		return joinNode(module, "data."+parts[1]+"."+parts[2])
+	case "local", "each", "count", "path", "terraform", "self":
+		return ""
+	}
+	return joinNode(module, parts[0]+"."+parts[1])
+}
+
+// stripIndexes removes bracketed index keys from a reference, e.g.
+// `aws_instance.web["a"].id` becomes "aws_instance.web.id".
+func stripIndexes(ref string) string {
+	var sb strings.Builder
+	depth := 0
+	inString := false
+	for i := 0; i < len(ref); i++ {
+		c := ref[i]
+		switch {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case depth > 0 && c == '"':
+			inString = true
+		case c == '[':
+			depth++
+		case c == ']' && depth > 0:
+			depth--
+		case depth == 0:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+func joinNode(module, address string) string {
+	if module == "" {
+		return address
+	}
+	return module + "." + address
+}
+
+// parentModule returns the path of the module that calls module, e.g.
+// "module.network" for "module.network.module.vpc".
+func parentModule(module string) string {
+	if i := strings.LastIndex(module, ".module."); i >= 0 {
+		return module[:i]
+	}
+	return ""
+}
+
+func configAddress(address string) string {
+	addr, err := parse.ParseAddress(address)
+	if err != nil {
+		return ""
+	}
+	return addr.ConfigAddress()
+}
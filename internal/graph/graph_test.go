@@ -0,0 +1,115 @@
+package graph
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sgr0691/diffy/internal/parse"
+)
+
+func loadExample(t *testing.T) *parse.Plan {
+	t.Helper()
+	plan, err := parse.LoadFile("../../examples/plan/blast_radius.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plan
+}
+
+func addresses(deps []Dependent) []string {
+	var out []string
+	for _, d := range deps {
+		out = append(out, d.Address)
+	}
+	return out
+}
+
+func TestDependentsFollowReferencesAcrossModules(t *testing.T) {
+	g := Build(loadExample(t))
+
+	got := g.Dependents("aws_vpc.main")
+	want := []string{
+		// Through state depends_on only: the config goes through a local.
+		"aws_flow_log.main",
+		// Through module.app's input, instance and output.
+		"aws_route53_record.api",
+		"aws_subnet.private[0]",
+		"aws_subnet.private[1]",
+		"module.app.aws_instance.web",
+		"module.app.aws_security_group.web",
+	}
+	if !reflect.DeepEqual(addresses(got), want) {
+		t.Fatalf("dependents of aws_vpc.main = %v, want %v", addresses(got), want)
+	}
+
+	actions := map[string]parse.Action{}
+	for _, d := range got {
+		actions[d.Address] = d.Action
+	}
+	if actions["aws_subnet.private[0]"] != parse.ActionReplace || actions["module.app.aws_instance.web"] != parse.ActionUpdate {
+		t.Errorf("planned actions not attached: %v", actions)
+	}
+	if actions["aws_flow_log.main"] != "" {
+		t.Errorf("unchanged dependent should have no action, got %q", actions["aws_flow_log.main"])
+	}
+}
+
+func TestDependentsExcludeSiblingInstances(t *testing.T) {
+	g := Build(loadExample(t))
+
+	got := addresses(g.Dependents("aws_subnet.private[1]"))
+	want := []string{"aws_route53_record.api", "module.app.aws_instance.web"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dependents of aws_subnet.private[1] = %v, want %v", got, want)
+	}
+	if deps := g.Dependents("aws_route53_record.api"); len(deps) != 0 {
+		t.Errorf("leaf resource has dependents: %v", addresses(deps))
+	}
+	if deps := g.Dependents("not an address"); deps != nil {
+		t.Errorf("invalid address has dependents: %v", addresses(deps))
+	}
+}
+
+func TestDataSourcesAreTraversedButNotReported(t *testing.T) {
+	plan := &parse.Plan{
+		Configuration: parse.Configuration{Resources: []parse.ConfigResource{
+			{Address: "aws_vpc.main", Mode: parse.ModeManaged},
+			{Address: "data.aws_subnets.all", Mode: parse.ModeData, References: []string{"aws_vpc.main.id"}},
+			{Address: "aws_instance.web", Mode: parse.ModeManaged, References: []string{"data.aws_subnets.all.ids", "each.key"}},
+		}},
+		ResourceChanges: []parse.ResourceChange{
+			{Address: "aws_vpc.main", Action: parse.ActionDelete},
+			{Address: `aws_instance.web["a"]`, Action: parse.ActionCreate},
+			{Address: `aws_instance.web["b"]`, Action: parse.ActionCreate},
+		},
+	}
+	got := addresses(Build(plan).Dependents("aws_vpc.main"))
+	want := []string{`aws_instance.web["a"]`, `aws_instance.web["b"]`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dependents = %v, want %v", got, want)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		module, ref, want string
+	}{
+		{"", "aws_vpc.main.id", "aws_vpc.main"},
+		{"", `aws_instance.web["a.b"].id`, "aws_instance.web"},
+		{"module.app", "var.subnet_id", "module.app.var.subnet_id"},
+		{"module.app", "data.aws_ami.ubuntu.id", "module.app.data.aws_ami.ubuntu"},
+		{"", "module.app.private_ip", "module.app.output.private_ip"},
+		{"", "module.app", ""},
+		{"", "local.name", ""},
+		{"", "count.index", ""},
+		{"", "path.module", ""},
+	}
+	for _, tt := range tests {
+		if got := resolve(tt.module, tt.ref); got != tt.want {
+			t.Errorf("resolve(%q, %q) = %q, want %q", tt.module, tt.ref, got, tt.want)
+		}
+	}
+	if got := parentModule("module.a.module.b"); got != "module.a" {
+		t.Errorf("parentModule = %q", got)
+	}
+}
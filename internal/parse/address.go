@@ -0,0 +1,149 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Resource modes as they appear in addresses and plan JSON.
+const (
+	ModeManaged = "managed"
+	ModeData    = "data"
+)
+
+// Address is a Terraform resource instance address split into its parts,
+// e.g. module.network.module.vpc.aws_route_table.private[0].
+type Address struct {
+	// Module holds one "module.<name>[key]" step per nesting level; empty
+	// for the root module.
+	Module []string
+	Mode   string
+	Type   string
+	Name   string
+	// Key is the count or for_each key as written in the address, e.g. `0`
+	// or `"a"`, without brackets. Empty when the resource is not indexed.
+	Key string
+}
+
+// ParseAddress splits a resource instance address into its parts.
+func ParseAddress(s string) (Address, error) {
+	steps, err := splitAddress(s)
+	if err != nil {
+		return Address{}, fmt.Errorf("invalid address %q: %w", s, err)
+	}
+
+	var a Address
+	i := 0
+	for i+1 < len(steps) && steps[i] == "module" {
+		a.Module = append(a.Module, "module."+steps[i+1])
+		i += 2
+	}
+
+	a.Mode = ModeManaged
+	if i < len(steps) && steps[i] == "data" {
+		a.Mode = ModeData
+		i++
+	}
+
+	if len(steps)-i != 2 {
+		return Address{}, fmt.Errorf("invalid address %q: expected <type>.<name> after module path", s)
+	}
+	a.Type = steps[i]
+	name, key, err := splitKey(steps[i+1])
+	if err != nil {
+		return Address{}, fmt.Errorf("invalid address %q: %w", s, err)
+	}
+	a.Name = name
+	a.Key = key
+	return a, nil
+}
+
+// ModulePath returns the module part of the address, e.g.
+// "module.network.module.vpc", or "" for the root module.
+func (a Address) ModulePath() string {
+	return strings.Join(a.Module, ".")
+}
+
+// Resource returns the address without the instance key, e.g.
+// "module.network.aws_route_table.private".
+func (a Address) Resource() string {
+	parts := append([]string{}, a.Module...)
+	if a.Mode == ModeData {
+		parts = append(parts, "data")
+	}
+	parts = append(parts, a.Type, a.Name)
+	return strings.Join(parts, ".")
+}
+
+// ConfigAddress returns the configuration address of the resource: the
+// address without any instance keys, in the module path or on the resource,
+// e.g. "module.network.aws_subnet.private".
+func (a Address) ConfigAddress() string {
+	parts := make([]string, 0, len(a.Module)+3)
+	for _, step := range a.Module {
+		name, _, _ := strings.Cut(step, "[")
+		parts = append(parts, name)
+	}
+	if a.Mode == ModeData {
+		parts = append(parts, "data")
+	}
+	parts = append(parts, a.Type, a.Name)
+	return strings.Join(parts, ".")
+}
+
+// splitAddress splits an address on dots that are outside index brackets.
+func splitAddress(s string) ([]string, error) {
+	var steps []string
+	var cur strings.Builder
+	depth := 0
+	inQuote := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case inQuote:
+			if c == '\\' && i+1 < len(s) {
+				cur.WriteByte(c)
+				i++
+				c = s[i]
+			} else if c == '"' {
+				inQuote = false
+			}
+		case c == '"':
+			inQuote = true
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced ']'")
+			}
+		case c == '.' && depth == 0:
+			if cur.Len() == 0 {
+				return nil, fmt.Errorf("empty address step")
+			}
+			steps = append(steps, cur.String())
+			cur.Reset()
+			continue
+		}
+		cur.WriteByte(c)
+	}
+	if inQuote || depth != 0 {
+		return nil, fmt.Errorf("unterminated index key")
+	}
+	if cur.Len() == 0 {
+		return nil, fmt.Errorf("empty address step")
+	}
+	return append(steps, cur.String()), nil
+}
+
+// splitKey separates "name[key]" into name and key.
+func splitKey(step string) (string, string, error) {
+	idx := strings.IndexByte(step, '[')
+	if idx < 0 {
+		return step, "", nil
+	}
+	if !strings.HasSuffix(step, "]") || idx == 0 {
+		return "", "", fmt.Errorf("malformed index in %q", step)
+	}
+	return step[:idx], step[idx+1 : len(step)-1], nil
+}
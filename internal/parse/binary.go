@@ -0,0 +1,152 @@
+package parse
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BinaryEnv names the environment variable that overrides which binary
+// renders binary plan files.
+const BinaryEnv = "DIFFY_TF_BINARY"
+
+// Tools that can produce a plan.
+const (
+	ToolTerraform = "terraform"
+	ToolOpenTofu  = "opentofu"
+)
+
+// defaultBinaries are tried in order when no binary is configured.
+var defaultBinaries = []string{"terraform", "tofu"}
+
+// ResolveBinary finds the executable used to render binary plans. An
+// explicit name (from --binary) wins, then $DIFFY_TF_BINARY, then the first
+// of terraform and tofu found in PATH. Names may be bare commands or paths.
+func ResolveBinary(name string) (string, error) {
+	source := "--binary"
+	if name == "" {
+		name, source = os.Getenv(BinaryEnv), "$"+BinaryEnv
+	}
+	if name != "" {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			return "", fmt.Errorf("%s not found (set by %s) — check the name or install it and try again", name, source)
+		}
+		return path, nil
+	}
+
+	for _, candidate := range defaultBinaries {
+		if path, err := exec.LookPath(candidate); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("neither terraform nor tofu found in PATH — install Terraform from https://developer.hashicorp.com/terraform/install or OpenTofu from https://opentofu.org/docs/intro/install/, or point --binary at one")
+}
+
+// LoadPlanBinary runs `terraform show -json <path>` (or `tofu show -json`,
+// see ResolveBinary) and returns the full normalized plan, including
+// resource drift.
+func LoadPlanBinary(path string) (*Plan, error) {
+	return LoadPlanBinaryWith("", path)
+}
+
+// LoadPlanBinaryWith is LoadPlanBinary using the given binary, resolved as
+// ResolveBinary does.
+func LoadPlanBinaryWith(binary, path string) (*Plan, error) {
+	return LoadPlanBinaryContext(context.Background(), binary, path)
+}
+
+// LoadPlanBinaryContext is LoadPlanBinaryWith bound to ctx. When ctx is done
+// the show command and its process group are killed; a deadline yields a
+// *TimeoutError. The command's output is streamed into the parser.
+func LoadPlanBinaryContext(ctx context.Context, binary, path string) (*Plan, error) {
+	binPath, err := ResolveBinary(binary)
+	if err != nil {
+		return nil, err
+	}
+	name := binaryName(binPath)
+
+	cmd := exec.CommandContext(ctx, binPath, "show", "-json", path)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error { return killProcessGroup(cmd) }
+	// Stop waiting on output pipes held open by stray children.
+	cmd.WaitDelay = waitDelay
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("running %s show: %w", name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("running %s show: %w", name, err)
+	}
+
+	plan, parseErr := decodePlan(stdout)
+	// Drain whatever the parser did not read so the process can exit.
+	_, _ = io.Copy(io.Discard, stdout)
+	waitErr := cmd.Wait()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		// Killing the command truncates its output, so the context error
+		// explains any wait or parse failure.
+		if errors.Is(ctxErr, context.DeadlineExceeded) {
+			return nil, &TimeoutError{Command: name + " show", Err: ctxErr}
+		}
+		return nil, fmt.Errorf("%s show interrupted: %w", name, ctxErr)
+	}
+	if err := waitErr; err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("%s show failed: %s", name, strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("running %s show: %w", name, err)
+	}
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	if plan.Metadata.Tool == "" {
+		plan.Metadata.Tool = toolForBinary(name)
+	}
+	return plan, nil
+}
+
+// waitDelay bounds how long Wait blocks on output after the command exits or
+// is killed.
+const waitDelay = 5 * time.Second
+
+// TimeoutError reports that a plan command did not finish before the
+// context deadline.
+type TimeoutError struct {
+	Command string
+	Err     error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out and was killed", e.Command)
+}
+
+// Hint suggests how to avoid the timeout.
+func (e *TimeoutError) Hint() string {
+	return "Check that `" + e.Command + "` is not waiting on a state or provider lock or an interactive prompt, or raise --timeout."
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// binaryName is the command name without directory, e.g. "tofu".
+func binaryName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), ".exe")
+}
+
+func toolForBinary(name string) string {
+	if strings.Contains(name, "tofu") {
+		return ToolOpenTofu
+	}
+	return ToolTerraform
+}
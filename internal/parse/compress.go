@@ -0,0 +1,46 @@
+package parse
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Load reads a plan JSON document from r and returns the full normalized
+// plan. Gzip and zstd compressed input is detected from its magic bytes and
+// decompressed transparently; anything else is parsed as plain JSON.
+func Load(r io.Reader) (*Plan, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, fmt.Errorf("reading plan: %w", err)
+	}
+
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing gzip plan: %w", err)
+		}
+		defer zr.Close()
+		return decodePlan(zr)
+	case bytes.HasPrefix(head, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing zstd plan: %w", err)
+		}
+		defer zr.Close()
+		return decodePlan(zr)
+	default:
+		return decodePlan(br)
+	}
+}
@@ -0,0 +1,220 @@
+package parse
+
+import "encoding/json"
+
+// Configuration is the dependency information recorded in a plan's
+// configuration block. Addresses and references are relative to the module
+// they appear in, as Terraform writes them.
+type Configuration struct {
+	Resources   []ConfigResource
+	ModuleCalls []ModuleCall
+	Outputs     []ConfigOutput
+}
+
+// ConfigResource is a resource or data block in the configuration.
+type ConfigResource struct {
+	// Module is the module path, e.g. "module.network", or "" for the root
+	// module.
+	Module string
+	// Address is the module-relative address, e.g. "aws_subnet.private".
+	Address string
+	Mode    string
+	// References are the objects the block's arguments, count, for_each and
+	// depends_on refer to, e.g. "var.vpc_id" or "aws_vpc.main.id".
+	References []string
+	// Lifecycle is nil when the block has no lifecycle settings.
+	Lifecycle *Lifecycle
+}
+
+// ModuleCall is a module block.
+type ModuleCall struct {
+	// Module is the path of the called module, e.g. "module.network".
+	Module string
+	// Inputs maps each argument to the references in its expression, which
+	// are relative to the calling module.
+	Inputs map[string][]string
+}
+
+// ConfigOutput is an output block.
+type ConfigOutput struct {
+	Module     string
+	Name       string
+	References []string
+}
+
+// StateResource is a resource instance recorded in the plan's prior_state.
+type StateResource struct {
+	Address string
+	// DependsOn lists the resources, by configuration address, that
+	// Terraform recorded the instance as depending on.
+	DependsOn []string
+}
+
+type tfConfigResource struct {
+	Address           string          `json:"address"`
+	Mode              string          `json:"mode"`
+	Expressions       json.RawMessage `json:"expressions"`
+	CountExpression   json.RawMessage `json:"count_expression"`
+	ForEachExpression json.RawMessage `json:"for_each_expression"`
+	DependsOn         []string        `json:"depends_on"`
+	Lifecycle         *tfLifecycle    `json:"lifecycle"`
+}
+
+type tfConfigOutput struct {
+	Expression json.RawMessage `json:"expression"`
+	DependsOn  []string        `json:"depends_on"`
+}
+
+type tfStateResource struct {
+	Address   string   `json:"address"`
+	DependsOn []string `json:"depends_on"`
+}
+
+// decodeConfiguration streams the configuration block, keeping the
+// references of its resources, module calls and outputs.
+func (d *planDecoder) decodeConfiguration(path string) error {
+	return d.decodeObject(path, func(name, path string) error {
+		if fieldName(name, "root_module") != "" {
+			return d.decodeConfigModule(path, "")
+		}
+		return d.skipValue(path)
+	})
+}
+
+func (d *planDecoder) decodeConfigModule(path, module string) error {
+	return d.decodeObject(path, func(name, path string) error {
+		switch fieldName(name, "resources", "outputs", "module_calls") {
+		case "resources":
+			return d.decodeArray(path, func(path string) error {
+				var r tfConfigResource
+				if err := d.decodeValue(path, &r); err != nil {
+					return err
+				}
+				refs := collectReferences(r.Expressions)
+				refs = append(refs, collectReferences(r.CountExpression)...)
+				refs = append(refs, collectReferences(r.ForEachExpression)...)
+				refs = append(refs, r.DependsOn...)
+				d.b.config.Resources = append(d.b.config.Resources, ConfigResource{
+					Module:     module,
+					Address:    r.Address,
+					Mode:       r.Mode,
+					References: sortedUnique(refs),
+					Lifecycle:  toLifecycle(r.Lifecycle),
+				})
+				return nil
+			})
+		case "outputs":
+			return d.decodeObject(path, func(output, path string) error {
+				var o tfConfigOutput
+				if err := d.decodeValue(path, &o); err != nil {
+					return err
+				}
+				refs := append(collectReferences(o.Expression), o.DependsOn...)
+				d.b.config.Outputs = append(d.b.config.Outputs, ConfigOutput{
+					Module:     module,
+					Name:       output,
+					References: sortedUnique(refs),
+				})
+				return nil
+			})
+		case "module_calls":
+			return d.decodeObject(path, func(call, path string) error {
+				return d.decodeModuleCall(path, joinModule(module, "module."+call))
+			})
+		}
+		return d.skipValue(path)
+	})
+}
+
+func (d *planDecoder) decodeModuleCall(path, module string) error {
+	return d.decodeObject(path, func(name, path string) error {
+		switch fieldName(name, "expressions", "module") {
+		case "expressions":
+			var exprs map[string]json.RawMessage
+			if err := d.decodeValue(path, &exprs); err != nil {
+				return err
+			}
+			call := ModuleCall{Module: module, Inputs: make(map[string][]string, len(exprs))}
+			for input, expr := range exprs {
+				call.Inputs[input] = sortedUnique(collectReferences(expr))
+			}
+			d.b.config.ModuleCalls = append(d.b.config.ModuleCalls, call)
+			return nil
+		case "module":
+			return d.decodeConfigModule(path, module)
+		}
+		return d.skipValue(path)
+	})
+}
+
+// decodePriorState streams prior_state, keeping each resource instance's
+// address and depends_on. Attribute values are not retained.
+func (d *planDecoder) decodePriorState(path string) error {
+	return d.decodeObject(path, func(name, path string) error {
+		if fieldName(name, "values") == "" {
+			return d.skipValue(path)
+		}
+		return d.decodeObject(path, func(name, path string) error {
+			if fieldName(name, "root_module") != "" {
+				return d.decodeStateModule(path)
+			}
+			return d.skipValue(path)
+		})
+	})
+}
+
+func (d *planDecoder) decodeStateModule(path string) error {
+	return d.decodeObject(path, func(name, path string) error {
+		switch fieldName(name, "resources", "child_modules") {
+		case "resources":
+			return d.decodeArray(path, func(path string) error {
+				var r tfStateResource
+				if err := d.decodeValue(path, &r); err != nil {
+					return err
+				}
+				d.b.state = append(d.b.state, StateResource{Address: r.Address, DependsOn: r.DependsOn})
+				return nil
+			})
+		case "child_modules":
+			return d.decodeArray(path, d.decodeStateModule)
+		}
+		return d.skipValue(path)
+	})
+}
+
+// collectReferences returns every entry of the "references" arrays nested
+// anywhere in an expression, including nested blocks.
+func collectReferences(raw json.RawMessage) []string {
+	var out []string
+	var walk func(v any)
+	walk = func(v any) {
+		switch t := v.(type) {
+		case map[string]any:
+			for k, child := range t {
+				if refs, ok := child.([]any); ok && k == "references" {
+					for _, r := range refs {
+						if s, ok := r.(string); ok {
+							out = append(out, s)
+						}
+					}
+					continue
+				}
+				walk(child)
+			}
+		case []any:
+			for _, child := range t {
+				walk(child)
+			}
+		}
+	}
+	walk(decodeValue(raw))
+	return out
+}
+
+// joinModule appends a "module.<name>" step to a module path.
+func joinModule(module, step string) string {
+	if module == "" {
+		return step
+	}
+	return module + "." + step
+}
@@ -0,0 +1,288 @@
+package parse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// ParseError locates malformed or wrongly-typed data in a plan document.
+type ParseError struct {
+	// Offset is the byte offset just past the offending data.
+	Offset int64
+	// Line and Column (1-based) point at the offending byte. They are zero
+	// when the position fell outside the retained input window.
+	Line   int
+	Column int
+	// Path is the logical JSON path, e.g. resource_changes[17].change.actions.
+	Path string
+	// Excerpt is a short single-line snippet around the offending byte with
+	// string values masked, and Caret is the rune index into it that marks
+	// the problem.
+	Excerpt string
+	Caret   int
+	Err     error
+}
+
+func (e *ParseError) Error() string {
+	var loc []string
+	if e.Path != "" {
+		loc = append(loc, "at "+e.Path)
+	}
+	if e.Line > 0 {
+		loc = append(loc, fmt.Sprintf("(line %d, column %d)", e.Line, e.Column))
+	} else {
+		loc = append(loc, fmt.Sprintf("(byte %d)", e.Offset))
+	}
+	return fmt.Sprintf("parsing plan JSON %s: %s", strings.Join(loc, " "), e.Message())
+}
+
+// Message describes what is wrong without the location.
+func (e *ParseError) Message() string {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(e.Err, &typeErr):
+		return fmt.Sprintf("expected %s, got %s", jsonKind(typeErr.Type), typeErr.Value)
+	case errors.As(e.Err, &syntaxErr) && syntaxErr.Error() != "unexpected end of JSON input":
+		return syntaxErr.Error()
+	case errors.As(e.Err, &syntaxErr), errors.Is(e.Err, io.ErrUnexpectedEOF), errors.Is(e.Err, io.EOF):
+		return "unexpected end of input (is the plan truncated?)"
+	}
+	return e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func newParseError(err error, path string, offset int64, pos *positionReader) *ParseError {
+	pe := &ParseError{Offset: offset, Path: path, Err: err}
+	// Offsets point just past the problem; report the offending byte itself.
+	at := offset - 1
+	if at < 0 {
+		at = 0
+	}
+	pe.Line, pe.Column = pos.lineColumn(at)
+	pe.Excerpt, pe.Caret = pos.excerpt(at)
+	return pe
+}
+
+// jsonKind names the JSON type a Go type decodes from.
+func jsonKind(t reflect.Type) string {
+	if t == nil {
+		return "value"
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Name() == "" {
+			return "value"
+		}
+		return "array"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Pointer:
+		return jsonKind(t.Elem())
+	}
+	return "value"
+}
+
+// positionWindow is how much recently read input positionReader keeps for
+// line/column lookups and excerpts.
+const positionWindow = 64 << 10
+
+// excerptRadius is how many bytes of context an excerpt shows on each side.
+const excerptRadius = 40
+
+// positionReader records a sliding window of the input it passes through,
+// along with the line and string-literal state at the start of the window,
+// so errors can be located without buffering the whole plan.
+type positionReader struct {
+	r      io.Reader
+	window []byte
+	total  int64
+
+	base      int64 // input offset of window[0]
+	lines     int   // newlines before base
+	lineStart int64 // offset of the first byte of the line containing base
+	inString  bool  // base falls inside a string literal
+	escaped   bool  // the byte before base was an unescaped backslash
+}
+
+func newPositionReader(r io.Reader) *positionReader {
+	return &positionReader{r: r}
+}
+
+func (p *positionReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.window = append(p.window, b[:n]...)
+	p.total += int64(n)
+	if len(p.window) > 2*positionWindow {
+		drop := len(p.window) - positionWindow
+		p.advance(p.window[:drop])
+		p.window = append(p.window[:0], p.window[drop:]...)
+	}
+	return n, err
+}
+
+// advance moves the window start past dropped, updating the tracked state.
+func (p *positionReader) advance(dropped []byte) {
+	for i, c := range dropped {
+		p.inString, p.escaped = stepString(c, p.inString, p.escaped)
+		if c == '\n' {
+			p.lines++
+			p.lineStart = p.base + int64(i) + 1
+		}
+	}
+	p.base += int64(len(dropped))
+}
+
+// skipSeparators returns the offset of the first byte at or after off that
+// is not whitespace or a comma, i.e. where the next value starts.
+func (p *positionReader) skipSeparators(off int64) int64 {
+	for off >= p.base && off-p.base < int64(len(p.window)) {
+		switch p.window[off-p.base] {
+		case ' ', '\t', '\r', '\n', ',':
+			off++
+		default:
+			return off
+		}
+	}
+	return off
+}
+
+// lineColumn returns the 1-based line and byte column of off, or zeros if
+// off is no longer in the window.
+func (p *positionReader) lineColumn(off int64) (int, int) {
+	if off < p.base || off-p.base > int64(len(p.window)) {
+		return 0, 0
+	}
+	line, start := p.lines+1, p.lineStart
+	for i, c := range p.window[:off-p.base] {
+		if c == '\n' {
+			line++
+			start = p.base + int64(i) + 1
+		}
+	}
+	return line, int(off-start) + 1
+}
+
+// excerpt returns up to excerptRadius bytes either side of off on its line,
+// with string values masked, and the rune index of off in the result.
+func (p *positionReader) excerpt(off int64) (string, int) {
+	if off < p.base || off-p.base >= int64(len(p.window)) {
+		return "", 0
+	}
+	at := int(off - p.base)
+	from, to := at, at+1
+	for from > 0 && at-from < excerptRadius && p.window[from-1] != '\n' {
+		from--
+	}
+	for to < len(p.window) && to-at <= excerptRadius && p.window[to] != '\n' && p.window[to] != '\r' {
+		to++
+	}
+
+	inString, escaped := p.inString, p.escaped
+	for _, c := range p.window[:from] {
+		inString, escaped = stepString(c, inString, escaped)
+	}
+	text, caret := maskStrings(p.window[from:to], at-from, inString, escaped)
+
+	if from > 0 && p.window[from-1] != '\n' {
+		text = "..." + text
+		caret += 3
+	}
+	if to < len(p.window) && p.window[to] != '\n' && p.window[to] != '\r' {
+		text += "..."
+	}
+	return text, caret
+}
+
+// stepString advances string-literal tracking by one byte.
+func stepString(c byte, inString, escaped bool) (bool, bool) {
+	switch {
+	case !inString:
+		return c == '"', false
+	case escaped:
+		return true, false
+	case c == '\\':
+		return true, true
+	case c == '"':
+		return false, false
+	}
+	return true, false
+}
+
+// maskStrings replaces the contents of string values in b with "***" so an
+// excerpt never echoes secrets from the plan. Object keys are kept since
+// they name the attribute at fault. caret is a byte index into b; the
+// returned caret is the matching rune index into the masked text.
+func maskStrings(b []byte, caret int, inString, escaped bool) (string, int) {
+	var sb strings.Builder
+	newCaret := -1
+	i := 0
+	for i < len(b) {
+		if !inString && b[i] != '"' {
+			if i == caret {
+				newCaret = runeLen(sb.String())
+			}
+			sb.WriteByte(b[i])
+			i++
+			continue
+		}
+
+		// A string literal starts here, or was already open at the start of
+		// the excerpt. Find where it ends.
+		start := i
+		if !inString {
+			i++
+			escaped = false
+		}
+		inString = true
+		for i < len(b) && inString {
+			inString, escaped = stepString(b[i], inString, escaped)
+			i++
+		}
+		if caret >= start && caret < i {
+			newCaret = runeLen(sb.String())
+		}
+		if isKey(b[i:]) {
+			sb.Write(b[start:i])
+		} else {
+			sb.WriteString(`"***"`)
+		}
+	}
+	if newCaret < 0 {
+		newCaret = runeLen(sb.String())
+	}
+	return sb.String(), newCaret
+}
+
+// isKey reports whether the bytes after a closing quote start with a colon.
+func isKey(rest []byte) bool {
+	for _, c := range rest {
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case ':':
+			return true
+		}
+		return false
+	}
+	return false
+}
+
+func runeLen(s string) int {
+	return len([]rune(s))
+}
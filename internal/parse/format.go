@@ -0,0 +1,126 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SupportedFormatMajor is the plan JSON format_version major Diffy reads.
+// Terraform 0.12 through 0.15 wrote 0.x plans in the same shape, so
+// legacyFormatMajor is read as well.
+const SupportedFormatMajor = "1"
+
+const legacyFormatMajor = "0"
+
+// DocumentKind classifies a JSON document by its top-level keys.
+type DocumentKind string
+
+const (
+	DocumentPlan    DocumentKind = "plan"
+	DocumentState   DocumentKind = "state"
+	DocumentUnknown DocumentKind = "unknown"
+)
+
+// planKeys are top-level keys only a plan document carries.
+var planKeys = []string{
+	"resource_changes",
+	"resource_drift",
+	"deferred_changes",
+	"output_changes",
+	"planned_values",
+	"prior_state",
+	"configuration",
+	"relevant_attributes",
+	"applyable",
+	"complete",
+}
+
+// stateKeys identify `terraform show -json` of state ("values") and raw
+// terraform.tfstate files ("lineage", "serial", "resources").
+var stateKeys = []string{"values", "lineage", "serial", "resources"}
+
+// Metadata describes the tool and format that produced a plan. OpenTofu
+// reuses Terraform's plan format and no OpenTofu-only fields are read, so
+// TerraformVersion holds the OpenTofu version when Tool is ToolOpenTofu.
+// Tool is a best-effort label from the binary or provider registry.
+type Metadata struct {
+	Tool             string `json:"tool,omitempty"`
+	FormatVersion    string `json:"format_version,omitempty"`
+	TerraformVersion string `json:"terraform_version,omitempty"`
+}
+
+// openTofuRegistry is the provider registry host OpenTofu records in
+// provider_name, e.g. "registry.opentofu.org/hashicorp/aws".
+const openTofuRegistry = "registry.opentofu.org/"
+
+// UnsupportedDocumentError is returned when the input is valid JSON but not
+// a plan Diffy can read: a state file, unrelated JSON, or a plan in a format
+// major version Diffy does not support.
+type UnsupportedDocumentError struct {
+	Kind          DocumentKind
+	FormatVersion string
+}
+
+func (e *UnsupportedDocumentError) Error() string {
+	switch e.Kind {
+	case DocumentState:
+		return "input is Terraform state, not a plan"
+	case DocumentPlan:
+		return fmt.Sprintf("unsupported plan format_version %q (Diffy reads %s.x and %s.x)", e.FormatVersion, legacyFormatMajor, SupportedFormatMajor)
+	default:
+		return "input is not a Terraform plan JSON document"
+	}
+}
+
+// Hint suggests how to produce input Diffy can read.
+func (e *UnsupportedDocumentError) Hint() string {
+	switch e.Kind {
+	case DocumentState:
+		return "Save a plan with `terraform plan -out=plan.out`, then run `diffy explain --from-plan plan.out` or pipe `terraform show -json plan.out` to `diffy explain -`."
+	case DocumentPlan:
+		return "Upgrade Diffy to a release that supports this plan format, or generate the plan with a Terraform version that emits format " + SupportedFormatMajor + ".x."
+	default:
+		return "Generate plan JSON with `terraform show -json plan.out` (or `tofu show -json plan.out`) and pass that file to `diffy explain`."
+	}
+}
+
+// classifyDocument decides what kind of document the seen top-level keys
+// describe and checks the plan format version.
+func classifyDocument(seen map[string]bool, formatVersion string) error {
+	kind := DocumentUnknown
+	switch {
+	case hasAny(seen, planKeys):
+		kind = DocumentPlan
+	case hasAny(seen, stateKeys):
+		kind = DocumentState
+	}
+
+	if kind != DocumentPlan {
+		return &UnsupportedDocumentError{Kind: kind, FormatVersion: formatVersion}
+	}
+	// Hand-written and older plan fixtures often omit format_version; only
+	// reject a version that is present and incompatible.
+	if formatVersion != "" && !supportedFormat(formatVersion) {
+		return &UnsupportedDocumentError{Kind: kind, FormatVersion: formatVersion}
+	}
+	return nil
+}
+
+func supportedFormat(version string) bool {
+	major := formatMajor(version)
+	return major == SupportedFormatMajor || major == legacyFormatMajor
+}
+
+func formatMajor(version string) string {
+	major, _, _ := strings.Cut(version, ".")
+	return major
+}
+
+func hasAny(seen map[string]bool, keys []string) bool {
+	for _, k := range keys {
+		if seen[k] {
+			return true
+		}
+	}
+	return false
+}
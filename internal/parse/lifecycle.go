@@ -0,0 +1,77 @@
+package parse
+
+import "encoding/json"
+
+// IgnoreAll is the IgnoreChanges entry for ignore_changes = all.
+const IgnoreAll = "all"
+
+// Lifecycle is a resource's lifecycle block from the plan's configuration.
+type Lifecycle struct {
+	// PreventDestroy is nil when the configuration does not set
+	// prevent_destroy, so an explicit false can be told apart.
+	PreventDestroy      *bool `json:"prevent_destroy,omitempty"`
+	CreateBeforeDestroy bool  `json:"create_before_destroy,omitempty"`
+	// IgnoreChanges lists the ignored attribute paths, in the same notation
+	// as ResourceChange.ChangePaths, or IgnoreAll.
+	IgnoreChanges []string `json:"ignore_changes,omitempty"`
+}
+
+// PreventDestroyDisabled reports whether prevent_destroy is explicitly set
+// to false.
+func (l *Lifecycle) PreventDestroyDisabled() bool {
+	return l != nil && l.PreventDestroy != nil && !*l.PreventDestroy
+}
+
+type tfLifecycle struct {
+	PreventDestroy      *bool           `json:"prevent_destroy"`
+	CreateBeforeDestroy bool            `json:"create_before_destroy"`
+	IgnoreChanges       json.RawMessage `json:"ignore_changes"`
+}
+
+// toLifecycle normalizes a lifecycle block. ignore_changes is either the
+// string "all" or a list of attribute paths, written as strings or as
+// step arrays like replace_paths.
+func toLifecycle(lc *tfLifecycle) *Lifecycle {
+	if lc == nil {
+		return nil
+	}
+	out := &Lifecycle{PreventDestroy: lc.PreventDestroy, CreateBeforeDestroy: lc.CreateBeforeDestroy}
+	switch v := decodeValue(lc.IgnoreChanges).(type) {
+	case string:
+		out.IgnoreChanges = []string{v}
+	case []any:
+		var steps [][]any
+		for _, entry := range v {
+			switch e := entry.(type) {
+			case string:
+				out.IgnoreChanges = append(out.IgnoreChanges, e)
+			case []any:
+				steps = append(steps, e)
+			}
+		}
+		out.IgnoreChanges = append(out.IgnoreChanges, formatReplacePaths(steps)...)
+	}
+	return out
+}
+
+// attachLifecycle sets the lifecycle of each change from its configuration
+// block. The configuration may follow resource_changes in the document, so
+// this runs once everything is decoded.
+func attachLifecycle(changes []ResourceChange, config Configuration) {
+	byAddress := make(map[string]*Lifecycle)
+	for _, r := range config.Resources {
+		if r.Lifecycle != nil {
+			byAddress[joinModule(r.Module, r.Address)] = r.Lifecycle
+		}
+	}
+	if len(byAddress) == 0 {
+		return
+	}
+	for i := range changes {
+		addr, err := ParseAddress(changes[i].Address)
+		if err != nil {
+			continue
+		}
+		changes[i].Lifecycle = byAddress[addr.ConfigAddress()]
+	}
+}
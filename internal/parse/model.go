@@ -1,6 +1,9 @@
 package parse
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"strings"
+)
 
 // Action represents a normalized Terraform change action.
 type Action string
@@ -11,24 +14,159 @@ const (
 	ActionDelete  Action = "delete"
 	ActionReplace Action = "replace"
 	ActionNoop    Action = "no-op"
+	// ActionMove is a pure address change from a moved block, with no other
+	// changes to the object.
+	ActionMove Action = "move"
+	// ActionImport brings an existing object under management with no other
+	// changes to it.
+	ActionImport Action = "import"
+	// ActionForget removes an object from state without destroying it
+	// (a removed block with destroy = false).
+	ActionForget Action = "forget"
+	// ActionRead is a data source read deferred until apply.
+	ActionRead Action = "read"
+	// ActionUnknown is an action verb Diffy does not recognize.
+	ActionUnknown Action = "unknown"
+)
+
+// Action reasons Terraform records for replacements and deletions. Other
+// reasons are kept verbatim in ResourceChange.ActionReason.
+const (
+	ReasonTainted      = "replace_because_tainted"
+	ReasonByRequest    = "replace_by_request"
+	ReasonCannotUpdate = "replace_because_cannot_update"
+	// ReasonNoResourceConfig and ReasonNoModule mark deletions whose
+	// resource block, or the module call containing it, was removed from
+	// the configuration.
+	ReasonNoResourceConfig = "delete_because_no_resource_config"
+	ReasonNoModule         = "delete_because_no_module"
 )
 
+// Replacement orderings. Terraform encodes destroy-before-create as
+// ["delete","create"] and create-before-destroy as ["create","delete"].
+const (
+	ReplaceDestroyBeforeCreate = "destroy_before_create"
+	ReplaceCreateBeforeDestroy = "create_before_destroy"
+)
+
+// Plan is the normalized subset of a Terraform plan that Diffy analyzes.
+type Plan struct {
+	ResourceChanges []ResourceChange
+	ResourceDrift   []ResourceDrift
+	OutputChanges   []OutputChange
+	// Configuration and PriorState carry only the references and
+	// depends_on needed to build a dependency graph.
+	Configuration Configuration
+	PriorState    []StateResource
+	Metadata      Metadata
+}
+
+// TagStack records stack as the source of every change, drift entry and
+// output change.
+func (p *Plan) TagStack(stack string) {
+	for i := range p.ResourceChanges {
+		p.ResourceChanges[i].Stack = stack
+	}
+	for i := range p.ResourceDrift {
+		p.ResourceDrift[i].Stack = stack
+	}
+	for i := range p.OutputChanges {
+		p.OutputChanges[i].Stack = stack
+	}
+}
+
 // ResourceChange is a normalized representation of a single Terraform resource change.
 type ResourceChange struct {
-	Address      string          `json:"address"`
-	Type         string          `json:"type"`
-	ProviderName string          `json:"provider_name,omitempty"`
-	Action       Action          `json:"action"`
-	Before       json.RawMessage `json:"before,omitempty"`
-	After        json.RawMessage `json:"after,omitempty"`
-	ChangePaths  []string        `json:"change_paths,omitempty"`
+	Address         string `json:"address"`
+	PreviousAddress string `json:"previous_address,omitempty"`
+	ModuleAddress   string `json:"module_address,omitempty"`
+	Mode            string `json:"mode,omitempty"`
+	Type            string `json:"type"`
+	Name            string `json:"name,omitempty"`
+	IndexKey        string `json:"index_key,omitempty"`
+	ProviderName    string `json:"provider_name,omitempty"`
+	Action          Action `json:"action"`
+	// Actions are the raw action verbs from the plan.
+	Actions []string `json:"actions,omitempty"`
+	// ImportID is set when the plan imports the object.
+	ImportID string `json:"import_id,omitempty"`
+	// DeferredReason is set for changes Terraform deferred to a later plan.
+	DeferredReason string          `json:"deferred_reason,omitempty"`
+	Before         json.RawMessage `json:"before,omitempty"`
+	After          json.RawMessage `json:"after,omitempty"`
+	AfterUnknown   json.RawMessage `json:"after_unknown,omitempty"`
+	ChangePaths    []string        `json:"change_paths,omitempty"`
+	// UnknownPaths lists the leaves whose values are known only after
+	// apply. They are also included in ChangePaths.
+	UnknownPaths []string `json:"unknown_paths,omitempty"`
+	// BeforeSensitive and AfterSensitive are Terraform's sensitivity masks
+	// for Before and After. Use Redacted before showing any value.
+	BeforeSensitive json.RawMessage `json:"before_sensitive,omitempty"`
+	AfterSensitive  json.RawMessage `json:"after_sensitive,omitempty"`
+	SensitivePaths  []string        `json:"sensitive_paths,omitempty"`
+	// ReplacePaths lists the attributes that forced a replacement, in the
+	// same notation as ChangePaths.
+	ReplacePaths []string `json:"replace_paths,omitempty"`
+	ActionReason string   `json:"action_reason,omitempty"`
+	// ReplaceOrder is set for replacements to ReplaceDestroyBeforeCreate or
+	// ReplaceCreateBeforeDestroy.
+	ReplaceOrder string `json:"replace_order,omitempty"`
+	// Lifecycle holds the resource's lifecycle settings from the plan's
+	// configuration, or nil when it has none.
+	Lifecycle *Lifecycle `json:"lifecycle,omitempty"`
+	// Stack names the plan the change came from when several plans are
+	// explained together. It is empty for a single plan.
+	Stack string `json:"stack,omitempty"`
+}
+
+// Moved reports whether the resource was relocated from a previous address.
+func (ch ResourceChange) Moved() bool {
+	return ch.PreviousAddress != "" && ch.PreviousAddress != ch.Address
+}
+
+// Importing reports whether the plan imports the object.
+func (ch ResourceChange) Importing() bool {
+	return ch.ImportID != ""
+}
+
+// Deferred reports whether Terraform deferred the change to a later plan.
+func (ch ResourceChange) Deferred() bool {
+	return ch.DeferredReason != ""
+}
+
+// Classification describes the change including any move or import, e.g.
+// "move", "move+update", "import+update" or "move+replace". Other changes
+// report their action.
+func (ch ResourceChange) Classification() string {
+	var parts []string
+	if ch.Moved() && ch.Action != ActionMove {
+		parts = append(parts, string(ActionMove))
+	}
+	if ch.Importing() && ch.Action != ActionImport {
+		parts = append(parts, string(ActionImport))
+	}
+	parts = append(parts, string(ch.Action))
+	return strings.Join(parts, "+")
+}
+
+// ResourceDrift is an object that changed outside of Terraform since the last
+// apply, as reported in the plan's resource_drift. Action describes what
+// happened out-of-band (update or delete), not what the plan will do.
+type ResourceDrift struct {
+	ResourceChange
 }
 
 // Counts holds aggregate counts by action type.
 type Counts struct {
-	Create  int `json:"create"`
-	Update  int `json:"update"`
-	Delete  int `json:"delete"`
-	Replace int `json:"replace"`
-	Total   int `json:"total"`
+	Create   int `json:"create"`
+	Update   int `json:"update"`
+	Delete   int `json:"delete"`
+	Replace  int `json:"replace"`
+	Move     int `json:"move"`
+	Import   int `json:"import"`
+	Forget   int `json:"forget"`
+	Read     int `json:"read"`
+	Deferred int `json:"deferred"`
+	Unknown  int `json:"unknown"`
+	Total    int `json:"total"`
 }
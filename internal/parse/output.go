@@ -0,0 +1,157 @@
+package parse
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+type tfOutputChange struct {
+	Actions         json.RawMessage `json:"actions"`
+	Before          json.RawMessage `json:"before"`
+	After           json.RawMessage `json:"after"`
+	AfterUnknown    json.RawMessage `json:"after_unknown"`
+	BeforeSensitive json.RawMessage `json:"before_sensitive"`
+	AfterSensitive  json.RawMessage `json:"after_sensitive"`
+}
+
+// Value types reported by ValueType.
+const (
+	ValueTypeString = "string"
+	ValueTypeNumber = "number"
+	ValueTypeBool   = "bool"
+	ValueTypeList   = "list"
+	ValueTypeObject = "object"
+	ValueTypeNull   = "null"
+)
+
+// OutputChange is a planned change to a root module output, from the plan's
+// output_changes. Downstream stacks read outputs through remote state, so
+// these changes can break consumers outside the plan.
+type OutputChange struct {
+	Name   string `json:"name"`
+	Action Action `json:"action"`
+	// Actions are the raw action verbs from the plan.
+	Actions []string        `json:"actions,omitempty"`
+	Before  json.RawMessage `json:"before,omitempty"`
+	After   json.RawMessage `json:"after,omitempty"`
+	// AfterUnknown is set when the whole new value is known only after
+	// apply.
+	AfterUnknown bool `json:"after_unknown,omitempty"`
+	// BeforeSensitive and AfterSensitive report whether any part of the
+	// value is sensitive. Use Redacted before showing any value.
+	BeforeSensitive bool `json:"before_sensitive,omitempty"`
+	AfterSensitive  bool `json:"after_sensitive,omitempty"`
+	// Stack names the plan the output came from when several plans are
+	// explained together. It is empty for a single plan.
+	Stack string `json:"stack,omitempty"`
+}
+
+// Address is the output's address as Terraform writes it, e.g.
+// "output.vpc_id".
+func (o OutputChange) Address() string {
+	return "output." + o.Name
+}
+
+// SensitivityChanged reports whether the output turns sensitive or stops
+// being sensitive.
+func (o OutputChange) SensitivityChanged() bool {
+	return o.BeforeSensitive != o.AfterSensitive
+}
+
+// BeforeType is the type of the old value, or "" when there was none.
+func (o OutputChange) BeforeType() string {
+	if o.Action == ActionCreate {
+		return ""
+	}
+	return ValueType(o.Before)
+}
+
+// AfterType is the type of the new value, or "" when the output is deleted
+// or its value is known only after apply.
+func (o OutputChange) AfterType() string {
+	if o.Action == ActionDelete || o.AfterUnknown {
+		return ""
+	}
+	return ValueType(o.After)
+}
+
+// Redacted returns a copy of the output with sensitive values replaced by
+// RedactedValue. Output sensitivity applies to the whole value.
+func (o OutputChange) Redacted() OutputChange {
+	out := o
+	if o.BeforeSensitive && len(o.Before) > 0 {
+		out.Before = json.RawMessage(strconv.Quote(RedactedValue))
+	}
+	if o.AfterSensitive && len(o.After) > 0 {
+		out.After = json.RawMessage(strconv.Quote(RedactedValue))
+	}
+	return out
+}
+
+// ValueType names the Terraform type family of a JSON value: string,
+// number, bool, list, object or null. Tuples and sets are lists; maps are
+// objects.
+func ValueType(raw json.RawMessage) string {
+	switch decodeValue(raw).(type) {
+	case string:
+		return ValueTypeString
+	case float64:
+		return ValueTypeNumber
+	case bool:
+		return ValueTypeBool
+	case []any:
+		return ValueTypeList
+	case map[string]any:
+		return ValueTypeObject
+	}
+	return ValueTypeNull
+}
+
+// OutputCounts holds aggregate counts of output changes by action.
+type OutputCounts struct {
+	Create int `json:"create"`
+	Update int `json:"update"`
+	Delete int `json:"delete"`
+	Total  int `json:"total"`
+}
+
+// ComputeOutputCounts tallies output changes by action.
+func ComputeOutputCounts(outputs []OutputChange) OutputCounts {
+	var c OutputCounts
+	for _, o := range outputs {
+		switch o.Action {
+		case ActionCreate:
+			c.Create++
+		case ActionUpdate:
+			c.Update++
+		case ActionDelete:
+			c.Delete++
+		}
+	}
+	c.Total = len(outputs)
+	return c
+}
+
+// toOutputChange normalizes a raw output change. Unchanged outputs are
+// dropped, except that a change in sensitivity alone is kept as an update
+// since it changes what consumers of the output see.
+func toOutputChange(name string, oc tfOutputChange) (OutputChange, bool) {
+	actions := decodeActions(oc.Actions)
+	out := OutputChange{
+		Name:            name,
+		Action:          deriveAction(actions),
+		Actions:         actions,
+		Before:          oc.Before,
+		After:           oc.After,
+		AfterUnknown:    decodeValue(oc.AfterUnknown) == true,
+		BeforeSensitive: hasTrueLeaf(decodeValue(oc.BeforeSensitive)),
+		AfterSensitive:  hasTrueLeaf(decodeValue(oc.AfterSensitive)),
+	}
+	if out.Action == ActionNoop {
+		if !out.SensitivityChanged() {
+			return OutputChange{}, false
+		}
+		out.Action = ActionUpdate
+	}
+	return out, true
+}
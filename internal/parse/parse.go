@@ -1,100 +1,224 @@
 package parse
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"reflect"
 	"sort"
 	"strconv"
 )
 
-// tfPlan is the subset of Terraform's JSON plan format we care about.
-type tfPlan struct {
-	ResourceChanges []tfResourceChange `json:"resource_changes"`
+type tfDeferredChange struct {
+	Reason         string           `json:"reason"`
+	ResourceChange tfResourceChange `json:"resource_change"`
 }
 
 type tfResourceChange struct {
-	Address      string   `json:"address"`
-	Type         string   `json:"type"`
-	ProviderName string   `json:"provider_name"`
-	Change       tfChange `json:"change"`
+	Address         string   `json:"address"`
+	PreviousAddress string   `json:"previous_address"`
+	ModuleAddress   string   `json:"module_address"`
+	Mode            string   `json:"mode"`
+	Type            string   `json:"type"`
+	Name            string   `json:"name"`
+	ProviderName    string   `json:"provider_name"`
+	ActionReason    string   `json:"action_reason"`
+	Change          tfChange `json:"change"`
 }
 
 type tfChange struct {
-	Actions json.RawMessage `json:"actions"`
-	Before  json.RawMessage `json:"before"`
-	After   json.RawMessage `json:"after"`
+	Actions         json.RawMessage `json:"actions"`
+	Before          json.RawMessage `json:"before"`
+	After           json.RawMessage `json:"after"`
+	AfterUnknown    json.RawMessage `json:"after_unknown"`
+	BeforeSensitive json.RawMessage `json:"before_sensitive"`
+	AfterSensitive  json.RawMessage `json:"after_sensitive"`
+	ReplacePaths    [][]any         `json:"replace_paths"`
+	Importing       *tfImporting    `json:"importing"`
+}
+
+type tfImporting struct {
+	ID string `json:"id"`
 }
 
 // FromFile reads and parses a Terraform plan JSON file.
 func FromFile(path string) ([]ResourceChange, error) {
-	data, err := os.ReadFile(path)
+	plan, err := LoadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("reading plan file: %w", err)
+		return nil, err
 	}
-	return parseJSON(data)
+	return plan.ResourceChanges, nil
 }
 
-// FromPlanBinary runs `terraform show -json <path>` and parses the output.
+// FromPlanBinary runs `terraform show -json <path>` (or `tofu show -json`,
+// see ResolveBinary) and parses the output.
 func FromPlanBinary(path string) ([]ResourceChange, error) {
-	tfPath, err := exec.LookPath("terraform")
+	plan, err := LoadPlanBinary(path)
 	if err != nil {
-		return nil, fmt.Errorf("terraform not found in PATH — install it from https://developer.hashicorp.com/terraform/install and try again")
+		return nil, err
 	}
+	return plan.ResourceChanges, nil
+}
 
-	cmd := exec.Command(tfPath, "show", "-json", path)
-	out, err := cmd.Output()
+// FromPlanBinaryContext is FromPlanBinary bound to ctx; see
+// LoadPlanBinaryContext.
+func FromPlanBinaryContext(ctx context.Context, path string) ([]ResourceChange, error) {
+	plan, err := LoadPlanBinaryContext(ctx, "", path)
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			return nil, fmt.Errorf("terraform show failed: %s", string(exitErr.Stderr))
-		}
-		return nil, fmt.Errorf("running terraform show: %w", err)
+		return nil, err
+	}
+	return plan.ResourceChanges, nil
+}
+
+// LoadFile reads a Terraform plan JSON file, optionally gzip or zstd
+// compressed, and returns the full normalized plan, including resource drift.
+// The file is streamed, so memory use is bounded by the changes Diffy keeps
+// rather than by the size of the plan.
+func LoadFile(path string) (*Plan, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading plan file: %w", err)
 	}
-	return parseJSON(out)
+	defer f.Close()
+	return Load(f)
 }
 
 func parseJSON(data []byte) ([]ResourceChange, error) {
-	var plan tfPlan
-	if err := json.Unmarshal(data, &plan); err != nil {
-		return nil, fmt.Errorf("parsing plan JSON: %w", err)
+	plan, err := parsePlan(data)
+	if err != nil {
+		return nil, err
 	}
+	return plan.ResourceChanges, nil
+}
 
-	changes := make([]ResourceChange, 0, len(plan.ResourceChanges))
-	for _, rc := range plan.ResourceChanges {
-		action := deriveAction(rc.Change.Actions)
-		if action == ActionNoop {
-			continue
+func parsePlan(data []byte) (*Plan, error) {
+	return decodePlan(bytes.NewReader(data))
+}
+
+// toResourceChange normalizes a raw resource change. It reports false for
+// no-op changes, which Diffy does not surface. A no-op on a moved or
+// imported resource is a pure move or import and is kept.
+func toResourceChange(rc tfResourceChange) (ResourceChange, bool) {
+	moved := rc.PreviousAddress != "" && rc.PreviousAddress != rc.Address
+	var importID string
+	if rc.Change.Importing != nil {
+		importID = rc.Change.Importing.ID
+		if importID == "" {
+			// Imports by identity carry no ID; keep the change marked.
+			importID = "(identity)"
+		}
+	}
+
+	actions := decodeActions(rc.Change.Actions)
+	action := deriveAction(actions)
+	if action == ActionNoop {
+		switch {
+		case importID != "":
+			action = ActionImport
+		case moved:
+			action = ActionMove
+		default:
+			return ResourceChange{}, false
+		}
+	}
+	changePaths, unknownPaths := computeChangePaths(rc.Change.Before, rc.Change.After, rc.Change.AfterUnknown)
+	var sensitive []string
+	sensitivePaths("", decodeValue(rc.Change.BeforeSensitive), &sensitive)
+	sensitivePaths("", decodeValue(rc.Change.AfterSensitive), &sensitive)
+	sensitive = sortedUnique(sensitive)
+
+	// Prefer the parsed address; fall back to the plan's own fields for
+	// addresses we cannot split.
+	addr, err := ParseAddress(rc.Address)
+	if err != nil {
+		addr = Address{Mode: rc.Mode, Type: rc.Type, Name: rc.Name}
+		if rc.ModuleAddress != "" {
+			addr.Module = []string{rc.ModuleAddress}
 		}
-		changes = append(changes, ResourceChange{
-			Address:      rc.Address,
-			Type:         rc.Type,
-			ProviderName: rc.ProviderName,
-			Action:       action,
-			Before:       rc.Change.Before,
-			After:        rc.Change.After,
-			ChangePaths:  computeChangePaths(rc.Change.Before, rc.Change.After),
-		})
 	}
-	return changes, nil
+
+	return ResourceChange{
+		Address:         rc.Address,
+		PreviousAddress: rc.PreviousAddress,
+		ModuleAddress:   addr.ModulePath(),
+		Mode:            addr.Mode,
+		Type:            rc.Type,
+		Name:            addr.Name,
+		IndexKey:        addr.Key,
+		ProviderName:    rc.ProviderName,
+		Action:          action,
+		Actions:         actions,
+		ImportID:        importID,
+		Before:          rc.Change.Before,
+		After:           rc.Change.After,
+		AfterUnknown:    rc.Change.AfterUnknown,
+		BeforeSensitive: rc.Change.BeforeSensitive,
+		AfterSensitive:  rc.Change.AfterSensitive,
+		ChangePaths:     collapseSensitive(changePaths, sensitive),
+		UnknownPaths:    collapseSensitive(unknownPaths, sensitive),
+		SensitivePaths:  sensitive,
+		ReplacePaths:    formatReplacePaths(rc.Change.ReplacePaths),
+		ActionReason:    rc.ActionReason,
+		ReplaceOrder:    replaceOrder(actions),
+	}, true
 }
 
-func deriveAction(raw json.RawMessage) Action {
+// formatReplacePaths converts Terraform's step lists (e.g. ["ingress", 0,
+// "cidr_blocks"]) into the dotted notation used by change paths.
+func formatReplacePaths(paths [][]any) []string {
+	var out []string
+	for _, steps := range paths {
+		var path string
+		for _, step := range steps {
+			switch v := step.(type) {
+			case string:
+				if path != "" {
+					path += "."
+				}
+				path += v
+			case float64:
+				path += "[" + strconv.Itoa(int(v)) + "]"
+			}
+		}
+		if path != "" {
+			out = append(out, path)
+		}
+	}
+	return out
+}
+
+func decodeActions(raw json.RawMessage) []string {
 	var actions []string
 	if err := json.Unmarshal(raw, &actions); err != nil {
-		return ActionNoop
+		return nil
 	}
+	return actions
+}
+
+// replaceOrder returns the replacement ordering encoded by actions, or ""
+// when actions are not a replacement.
+func replaceOrder(actions []string) string {
+	if len(actions) != 2 {
+		return ""
+	}
+	switch {
+	case actions[0] == "delete" && actions[1] == "create":
+		return ReplaceDestroyBeforeCreate
+	case actions[0] == "create" && actions[1] == "delete":
+		return ReplaceCreateBeforeDestroy
+	}
+	return ""
+}
+
+func deriveAction(actions []string) Action {
 	if len(actions) == 0 {
 		return ActionNoop
 	}
 
-	// Terraform encodes replace as ["delete","create"]
-	if len(actions) == 2 && actions[0] == "delete" && actions[1] == "create" {
-		return ActionReplace
-	}
-	// Also handle ["create","delete"] just in case
-	if len(actions) == 2 && actions[0] == "create" && actions[1] == "delete" {
+	// Both replacement orderings are a replace; replaceOrder tells them apart.
+	if replaceOrder(actions) != "" {
 		return ActionReplace
 	}
 
@@ -105,10 +229,14 @@ func deriveAction(raw json.RawMessage) Action {
 		return ActionUpdate
 	case "delete":
 		return ActionDelete
-	case "no-op", "read":
+	case "read":
+		return ActionRead
+	case "forget":
+		return ActionForget
+	case "no-op":
 		return ActionNoop
 	default:
-		return ActionNoop
+		return ActionUnknown
 	}
 }
 
@@ -116,6 +244,11 @@ func deriveAction(raw json.RawMessage) Action {
 func ComputeCounts(changes []ResourceChange) Counts {
 	var c Counts
 	for _, ch := range changes {
+		// Deferred changes will not be applied by this plan.
+		if ch.Deferred() {
+			c.Deferred++
+			continue
+		}
 		switch ch.Action {
 		case ActionCreate:
 			c.Create++
@@ -125,37 +258,138 @@ func ComputeCounts(changes []ResourceChange) Counts {
 			c.Delete++
 		case ActionReplace:
 			c.Replace++
+		case ActionMove:
+			c.Move++
+		case ActionImport:
+			c.Import++
+		case ActionForget:
+			c.Forget++
+		case ActionRead:
+			c.Read++
+		case ActionUnknown:
+			c.Unknown++
 		}
 	}
-	c.Total = c.Create + c.Update + c.Delete + c.Replace
+	c.Total = c.Create + c.Update + c.Delete + c.Replace + c.Move + c.Import + c.Forget + c.Read + c.Deferred + c.Unknown
 	return c
 }
 
-func computeChangePaths(beforeRaw, afterRaw json.RawMessage) []string {
+// computeChangePaths diffs before against after. Leaves marked in
+// afterUnknown are merged into after as unknown values first, so they are
+// reported as changes and returned separately as unknown paths.
+func computeChangePaths(beforeRaw, afterRaw, afterUnknownRaw json.RawMessage) ([]string, []string) {
 	var before any
 	var after any
+	var afterUnknown any
 	if len(beforeRaw) > 0 {
 		_ = json.Unmarshal(beforeRaw, &before)
 	}
 	if len(afterRaw) > 0 {
 		_ = json.Unmarshal(afterRaw, &after)
 	}
+	if len(afterUnknownRaw) > 0 {
+		_ = json.Unmarshal(afterUnknownRaw, &afterUnknown)
+	}
+
+	var unknown []string
+	after = mergeUnknown("", after, afterUnknown, &unknown)
 
 	var out []string
 	diffAny("", before, after, &out)
-	if len(out) == 0 {
+	return sortedUnique(out), sortedUnique(unknown)
+}
+
+func sortedUnique(paths []string) []string {
+	if len(paths) == 0 {
 		return nil
 	}
-	sort.Strings(out)
-	deduped := out[:0]
-	for i, p := range out {
-		if i == 0 || out[i-1] != p {
+	sort.Strings(paths)
+	deduped := paths[:0]
+	for i, p := range paths {
+		if i == 0 || paths[i-1] != p {
 			deduped = append(deduped, p)
 		}
 	}
 	return deduped
 }
 
+// unknownValue stands in for a value that is known only after apply. It
+// never compares equal to a decoded JSON value.
+type unknownValue struct{}
+
+// mergeUnknown overlays the after_unknown mask onto after, replacing every
+// leaf marked true with unknownValue and recording its path.
+func mergeUnknown(path string, after, mask any, paths *[]string) any {
+	switch m := mask.(type) {
+	case bool:
+		if !m {
+			return after
+		}
+		if path != "" {
+			*paths = append(*paths, path)
+		}
+		return unknownValue{}
+	case map[string]any:
+		afterMap, ok := after.(map[string]any)
+		if !ok {
+			if !hasTrueLeaf(m) {
+				return after
+			}
+			afterMap = make(map[string]any, len(m))
+		}
+		for k, v := range m {
+			if !hasTrueLeaf(v) {
+				continue
+			}
+			child := k
+			if path != "" {
+				child = path + "." + k
+			}
+			afterMap[k] = mergeUnknown(child, afterMap[k], v, paths)
+		}
+		return afterMap
+	case []any:
+		afterList, ok := after.([]any)
+		if !ok && !hasTrueLeaf(m) {
+			return after
+		}
+		for i, v := range m {
+			if !hasTrueLeaf(v) {
+				continue
+			}
+			for len(afterList) <= i {
+				afterList = append(afterList, nil)
+			}
+			afterList[i] = mergeUnknown(path+"["+strconv.Itoa(i)+"]", afterList[i], v, paths)
+		}
+		return afterList
+	default:
+		return after
+	}
+}
+
+// hasTrueLeaf reports whether an after_unknown or sensitivity mask marks
+// any leaf.
+func hasTrueLeaf(mask any) bool {
+	switch m := mask.(type) {
+	case bool:
+		return m
+	case map[string]any:
+		for _, v := range m {
+			if hasTrueLeaf(v) {
+				return true
+			}
+		}
+	case []any:
+		for _, v := range m {
+			if hasTrueLeaf(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func diffAny(path string, before, after any, out *[]string) {
 	if reflect.DeepEqual(before, after) {
 		return
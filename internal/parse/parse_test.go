@@ -1,7 +1,23 @@
 package parse
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
 )
 
 func TestDeriveActionCreate(t *testing.T) {
@@ -42,6 +58,29 @@ func TestDeriveActionReplace(t *testing.T) {
 	}
 }
 
+func TestReplaceOrderKept(t *testing.T) {
+	changes, err := parseJSON([]byte(`{
+		"resource_changes": [{
+			"address": "aws_instance.dbc",
+			"type": "aws_instance",
+			"change": {"actions": ["delete","create"], "before": {}, "after": {}}
+		}, {
+			"address": "aws_instance.cbd",
+			"type": "aws_instance",
+			"change": {"actions": ["create","delete"], "before": {}, "after": {}}
+		}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes[0].Action != ActionReplace || changes[0].ReplaceOrder != ReplaceDestroyBeforeCreate {
+		t.Errorf("expected destroy-before-create replace, got %s %q", changes[0].Action, changes[0].ReplaceOrder)
+	}
+	if changes[1].Action != ActionReplace || changes[1].ReplaceOrder != ReplaceCreateBeforeDestroy {
+		t.Errorf("expected create-before-destroy replace, got %s %q", changes[1].Action, changes[1].ReplaceOrder)
+	}
+}
+
 func TestDeriveActionDelete(t *testing.T) {
 	changes, err := parseJSON([]byte(`{
 		"resource_changes": [{
@@ -77,6 +116,143 @@ func TestNoopIsSkipped(t *testing.T) {
 	}
 }
 
+func TestMovedResources(t *testing.T) {
+	changes, err := parseJSON([]byte(`{
+		"resource_changes": [{
+			"address": "module.new.aws_s3_bucket.x",
+			"previous_address": "module.old.aws_s3_bucket.x",
+			"type": "aws_s3_bucket",
+			"change": {"actions": ["no-op"], "before": {}, "after": {}}
+		}, {
+			"address": "module.new.aws_instance.y",
+			"previous_address": "module.old.aws_instance.y",
+			"type": "aws_instance",
+			"change": {"actions": ["delete","create"], "before": {}, "after": {}}
+		}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes (pure move kept), got %d", len(changes))
+	}
+	if changes[0].Action != ActionMove || changes[0].Classification() != "move" {
+		t.Errorf("expected pure move, got %s (%s)", changes[0].Action, changes[0].Classification())
+	}
+	if changes[0].PreviousAddress != "module.old.aws_s3_bucket.x" {
+		t.Errorf("unexpected previous address: %s", changes[0].PreviousAddress)
+	}
+	if changes[1].Action != ActionReplace || changes[1].Classification() != "move+replace" {
+		t.Errorf("expected move+replace, got %s (%s)", changes[1].Action, changes[1].Classification())
+	}
+
+	c := ComputeCounts(changes)
+	if c.Move != 1 || c.Replace != 1 || c.Total != 2 {
+		t.Errorf("unexpected counts: %+v", c)
+	}
+}
+
+func TestReplacePathsAndActionReason(t *testing.T) {
+	changes, err := parseJSON([]byte(`{
+		"resource_changes": [{
+			"address": "aws_db_instance.main",
+			"type": "aws_db_instance",
+			"action_reason": "replace_because_cannot_update",
+			"change": {
+				"actions": ["delete","create"],
+				"before": {}, "after": {},
+				"replace_paths": [["engine"], ["availability_zone"], ["ebs_block_device", 0, "volume_size"]]
+			}
+		}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("expected 1 change, got %d", len(changes))
+	}
+	want := []string{"engine", "availability_zone", "ebs_block_device[0].volume_size"}
+	if len(changes[0].ReplacePaths) != len(want) {
+		t.Fatalf("expected replace paths %v, got %v", want, changes[0].ReplacePaths)
+	}
+	for i, p := range want {
+		if changes[0].ReplacePaths[i] != p {
+			t.Errorf("replace path %d: expected %s, got %s", i, p, changes[0].ReplacePaths[i])
+		}
+	}
+	if changes[0].ActionReason != ReasonCannotUpdate {
+		t.Errorf("expected action reason %s, got %s", ReasonCannotUpdate, changes[0].ActionReason)
+	}
+}
+
+func TestImportForgetReadAndDeferredActions(t *testing.T) {
+	changes, err := parseJSON([]byte(`{
+		"resource_changes": [{
+			"address": "aws_instance.legacy",
+			"type": "aws_instance",
+			"change": {"actions": ["forget"], "before": {}, "after": null}
+		}, {
+			"address": "aws_s3_bucket.imported",
+			"type": "aws_s3_bucket",
+			"change": {"actions": ["no-op"], "before": {}, "after": {}, "importing": {"id": "my-bucket"}}
+		}, {
+			"address": "aws_s3_bucket.adopted",
+			"type": "aws_s3_bucket",
+			"change": {"actions": ["update"], "before": {}, "after": {"x": 1}, "importing": {"id": "other"}}
+		}, {
+			"address": "data.aws_ami.base",
+			"type": "aws_ami",
+			"change": {"actions": ["read"], "before": null, "after": {}}
+		}, {
+			"address": "aws_thing.x",
+			"type": "aws_thing",
+			"change": {"actions": ["frobnicate"], "before": null, "after": {}}
+		}],
+		"deferred_changes": [{
+			"reason": "provider_config_unknown",
+			"resource_change": {
+				"address": "kubernetes_namespace.app",
+				"type": "kubernetes_namespace",
+				"change": {"actions": ["create"], "before": null, "after": {}}
+			}
+		}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 6 {
+		t.Fatalf("expected 6 changes, got %d", len(changes))
+	}
+
+	want := []struct {
+		action         Action
+		classification string
+	}{
+		{ActionForget, "forget"},
+		{ActionImport, "import"},
+		{ActionUpdate, "import+update"},
+		{ActionRead, "read"},
+		{ActionUnknown, "unknown"},
+		{ActionCreate, "create"},
+	}
+	for i, w := range want {
+		if changes[i].Action != w.action || changes[i].Classification() != w.classification {
+			t.Errorf("change %d (%s): expected %s/%s, got %s/%s", i, changes[i].Address, w.action, w.classification, changes[i].Action, changes[i].Classification())
+		}
+	}
+	if changes[1].ImportID != "my-bucket" {
+		t.Errorf("expected import id my-bucket, got %q", changes[1].ImportID)
+	}
+	if !changes[5].Deferred() || changes[5].DeferredReason != "provider_config_unknown" {
+		t.Errorf("expected deferred change, got reason %q", changes[5].DeferredReason)
+	}
+
+	c := ComputeCounts(changes)
+	if c.Forget != 1 || c.Import != 1 || c.Update != 1 || c.Read != 1 || c.Unknown != 1 || c.Deferred != 1 || c.Create != 0 || c.Total != 6 {
+		t.Errorf("unexpected counts: %+v", c)
+	}
+}
+
 func TestComputeCounts(t *testing.T) {
 	changes := []ResourceChange{
 		{Action: ActionCreate},
@@ -119,6 +295,72 @@ func TestFromFile(t *testing.T) {
 	}
 }
 
+func TestLoadDetectsCompression(t *testing.T) {
+	data, err := os.ReadFile("../../examples/plan/replace.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := parsePlan(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	if _, err := gw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zw, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zst := zw.EncodeAll(data, nil)
+	zw.Close()
+
+	dir := t.TempDir()
+	for name, input := range map[string][]byte{
+		"plain": data,
+		"gzip":  gz.Bytes(),
+		"zstd":  zst,
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, err := Load(bytes.NewReader(input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Load(%s) differs from plain parse", name)
+			}
+
+			// FromFile sniffs the content, not the extension.
+			path := filepath.Join(dir, name+".json")
+			if err := os.WriteFile(path, input, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			changes, err := FromFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(changes, want.ResourceChanges) {
+				t.Errorf("FromFile(%s) differs from plain parse", name)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsCorruptCompressedInput(t *testing.T) {
+	if _, err := Load(bytes.NewReader([]byte{0x1f, 0x8b, 0x00, 0x01})); err == nil {
+		t.Error("expected an error for truncated gzip input")
+	}
+	if _, err := Load(bytes.NewReader(nil)); err == nil {
+		t.Error("expected an error for empty input")
+	}
+}
+
 func TestChangePathsDetectedForUpdates(t *testing.T) {
 	changes, err := parseJSON([]byte(`{
 		"resource_changes": [{
@@ -145,6 +387,852 @@ func TestChangePathsDetectedForUpdates(t *testing.T) {
 	}
 }
 
+func TestResourceDriftParsed(t *testing.T) {
+	plan, err := parsePlan([]byte(`{
+		"resource_drift": [{
+			"address": "aws_security_group.web",
+			"type": "aws_security_group",
+			"change": {
+				"actions": ["update"],
+				"before": {"ingress": [{"cidr_blocks": ["10.0.0.0/8"]}]},
+				"after": {"ingress": [{"cidr_blocks": ["0.0.0.0/0"]}]}
+			}
+		}, {
+			"address": "aws_instance.idle",
+			"type": "aws_instance",
+			"change": {"actions": ["no-op"], "before": {}, "after": {}}
+		}],
+		"resource_changes": [{
+			"address": "aws_instance.test",
+			"type": "aws_instance",
+			"change": {"actions": ["create"], "before": null, "after": {}}
+		}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.ResourceChanges) != 1 {
+		t.Fatalf("expected 1 change, got %d", len(plan.ResourceChanges))
+	}
+	if len(plan.ResourceDrift) != 1 {
+		t.Fatalf("expected 1 drifted resource (no-op skipped), got %d", len(plan.ResourceDrift))
+	}
+	d := plan.ResourceDrift[0]
+	if d.Address != "aws_security_group.web" || d.Action != ActionUpdate {
+		t.Errorf("unexpected drift entry: %s %s", d.Address, d.Action)
+	}
+	if !containsPath(d.ChangePaths, "ingress[0].cidr_blocks[0]") {
+		t.Errorf("expected drift change path, got %#v", d.ChangePaths)
+	}
+}
+
+func TestAfterUnknownMarksPaths(t *testing.T) {
+	changes, err := parseJSON([]byte(`{
+		"resource_changes": [{
+			"address": "aws_security_group.web",
+			"type": "aws_security_group",
+			"change": {
+				"actions": ["update"],
+				"before": {"name": "web", "ingress": [{"from_port": 443, "cidr_blocks": ["10.0.0.0/8"]}]},
+				"after": {"name": "web", "ingress": [{"from_port": 443}]},
+				"after_unknown": {"ingress": [{"cidr_blocks": true}], "arn": false}
+			}
+		}, {
+			"address": "aws_instance.old",
+			"type": "aws_instance",
+			"change": {"actions": ["delete"], "before": {"ami": "ami-1"}, "after": null, "after_unknown": {}}
+		}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %d", len(changes))
+	}
+	ch := changes[0]
+	if len(ch.UnknownPaths) != 1 || ch.UnknownPaths[0] != "ingress[0].cidr_blocks" {
+		t.Fatalf("expected unknown path ingress[0].cidr_blocks, got %#v", ch.UnknownPaths)
+	}
+	if len(ch.ChangePaths) != 1 || ch.ChangePaths[0] != "ingress[0].cidr_blocks" {
+		t.Fatalf("expected only the unknown leaf as change path, got %#v", ch.ChangePaths)
+	}
+	if len(changes[1].ChangePaths) != 0 || len(changes[1].UnknownPaths) != 0 {
+		t.Errorf("expected no paths for delete with empty after_unknown, got %#v / %#v", changes[1].ChangePaths, changes[1].UnknownPaths)
+	}
+}
+
+func TestSensitiveMasksRedactValues(t *testing.T) {
+	changes, err := parseJSON([]byte(`{
+		"resource_changes": [{
+			"address": "aws_db_instance.main",
+			"type": "aws_db_instance",
+			"change": {
+				"actions": ["update"],
+				"before": {"password": "old-secret", "tags": {"secret-key": "a"}, "engine": "postgres"},
+				"after": {"password": "new-secret", "tags": {"secret-key": "b"}, "engine": "postgres"},
+				"before_sensitive": {"password": true, "tags": true},
+				"after_sensitive": {"password": true, "tags": true}
+			}
+		}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := changes[0]
+	if !containsPath(ch.SensitivePaths, "password") || !containsPath(ch.SensitivePaths, "tags") {
+		t.Fatalf("expected password and tags to be sensitive, got %#v", ch.SensitivePaths)
+	}
+	if !containsPath(ch.ChangePaths, "tags") || containsPath(ch.ChangePaths, "tags.secret-key") {
+		t.Errorf("expected change paths collapsed to the sensitive attribute, got %#v", ch.ChangePaths)
+	}
+
+	redacted := ch.Redacted()
+	for _, raw := range []string{string(redacted.Before), string(redacted.After)} {
+		for _, secret := range []string{"old-secret", "new-secret", "secret-key"} {
+			if strings.Contains(raw, secret) {
+				t.Errorf("redacted value still contains %q: %s", secret, raw)
+			}
+		}
+		if !strings.Contains(raw, "postgres") {
+			t.Errorf("expected non-sensitive values to survive redaction: %s", raw)
+		}
+	}
+
+	values := ch.SensitiveValues()
+	for _, want := range []string{"old-secret", "new-secret", "secret-key", "a", "b"} {
+		if !containsPath(values, want) {
+			t.Errorf("expected %q among sensitive values, got %#v", want, values)
+		}
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		in     string
+		module string
+		mode   string
+		typ    string
+		name   string
+		key    string
+	}{
+		{"aws_instance.web", "", ModeManaged, "aws_instance", "web", ""},
+		{"module.network.module.vpc.aws_route_table.private[0]", "module.network.module.vpc", ModeManaged, "aws_route_table", "private", "0"},
+		{`module.app["blue.v2"].data.aws_ami.base`, `module.app["blue.v2"]`, ModeData, "aws_ami", "base", ""},
+		{`aws_s3_bucket.this["logs[1]"]`, "", ModeManaged, "aws_s3_bucket", "this", `"logs[1]"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			a, err := ParseAddress(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a.ModulePath() != tt.module || a.Mode != tt.mode || a.Type != tt.typ || a.Name != tt.name || a.Key != tt.key {
+				t.Errorf("unexpected parse: module=%q mode=%q type=%q name=%q key=%q", a.ModulePath(), a.Mode, a.Type, a.Name, a.Key)
+			}
+		})
+	}
+
+	for _, bad := range []string{"", "aws_instance", "module.x", "aws_instance.web[0", "a.b.c"} {
+		if _, err := ParseAddress(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestResourceChangeCarriesAddressParts(t *testing.T) {
+	changes, err := parseJSON([]byte(`{
+		"resource_changes": [{
+			"address": "module.network.module.vpc.aws_route_table.private[0]",
+			"module_address": "module.network.module.vpc",
+			"type": "aws_route_table",
+			"change": {"actions": ["update"], "before": {}, "after": {}}
+		}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := changes[0]
+	if ch.ModuleAddress != "module.network.module.vpc" || ch.Name != "private" || ch.IndexKey != "0" || ch.Mode != ModeManaged {
+		t.Errorf("unexpected address parts: %+v", ch)
+	}
+}
+
+// unmarshalPlan is the whole-document decoder the streaming parser replaced.
+// It is kept as a reference for equivalence tests and benchmarks.
+func unmarshalPlan(data []byte) (*Plan, error) {
+	var doc struct {
+		FormatVersion    string                    `json:"format_version"`
+		TerraformVersion string                    `json:"terraform_version"`
+		ResourceChanges  []tfResourceChange        `json:"resource_changes"`
+		ResourceDrift    []tfResourceChange        `json:"resource_drift"`
+		DeferredChanges  []tfDeferredChange        `json:"deferred_changes"`
+		OutputChanges    map[string]tfOutputChange `json:"output_changes"`
+		PriorState       json.RawMessage           `json:"prior_state"`
+		Configuration    json.RawMessage           `json:"configuration"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing plan JSON: %w", err)
+	}
+	b := planBuilder{metadata: Metadata{FormatVersion: doc.FormatVersion, TerraformVersion: doc.TerraformVersion}}
+	for _, rc := range doc.ResourceChanges {
+		b.addChange(rc)
+	}
+	for _, rc := range doc.ResourceDrift {
+		b.addDrift(rc)
+	}
+	for _, dc := range doc.DeferredChanges {
+		b.addDeferred(dc)
+	}
+	// Terraform writes outputs sorted by name.
+	for _, name := range sortedKeys(doc.OutputChanges) {
+		b.addOutput(name, doc.OutputChanges[name])
+	}
+
+	var config struct {
+		RootModule refConfigModule `json:"root_module"`
+	}
+	var state struct {
+		Values struct {
+			RootModule refStateModule `json:"root_module"`
+		} `json:"values"`
+	}
+	if len(doc.Configuration) > 0 {
+		if err := json.Unmarshal(doc.Configuration, &config); err != nil {
+			return nil, err
+		}
+	}
+	if len(doc.PriorState) > 0 {
+		if err := json.Unmarshal(doc.PriorState, &state); err != nil {
+			return nil, err
+		}
+	}
+	config.RootModule.addTo(&b, "")
+	state.Values.RootModule.addTo(&b)
+	return b.plan(), nil
+}
+
+type refConfigModule struct {
+	Outputs     map[string]tfConfigOutput `json:"outputs"`
+	Resources   []tfConfigResource        `json:"resources"`
+	ModuleCalls map[string]struct {
+		Expressions map[string]json.RawMessage `json:"expressions"`
+		Module      *refConfigModule           `json:"module"`
+	} `json:"module_calls"`
+}
+
+// addTo records the module in Terraform's key order: outputs, resources,
+// then module calls, each sorted by name.
+func (m refConfigModule) addTo(b *planBuilder, module string) {
+	for _, name := range sortedKeys(m.Outputs) {
+		o := m.Outputs[name]
+		refs := append(collectReferences(o.Expression), o.DependsOn...)
+		b.config.Outputs = append(b.config.Outputs, ConfigOutput{Module: module, Name: name, References: sortedUnique(refs)})
+	}
+	for _, r := range m.Resources {
+		refs := collectReferences(r.Expressions)
+		refs = append(refs, collectReferences(r.CountExpression)...)
+		refs = append(refs, collectReferences(r.ForEachExpression)...)
+		refs = append(refs, r.DependsOn...)
+		b.config.Resources = append(b.config.Resources, ConfigResource{Module: module, Address: r.Address, Mode: r.Mode, References: sortedUnique(refs), Lifecycle: toLifecycle(r.Lifecycle)})
+	}
+	for _, name := range sortedKeys(m.ModuleCalls) {
+		call := m.ModuleCalls[name]
+		path := joinModule(module, "module."+name)
+		if call.Expressions != nil {
+			inputs := make(map[string][]string, len(call.Expressions))
+			for input, expr := range call.Expressions {
+				inputs[input] = sortedUnique(collectReferences(expr))
+			}
+			b.config.ModuleCalls = append(b.config.ModuleCalls, ModuleCall{Module: path, Inputs: inputs})
+		}
+		if call.Module != nil {
+			call.Module.addTo(b, path)
+		}
+	}
+}
+
+type refStateModule struct {
+	Resources    []tfStateResource `json:"resources"`
+	ChildModules []refStateModule  `json:"child_modules"`
+}
+
+func (m refStateModule) addTo(b *planBuilder) {
+	for _, r := range m.Resources {
+		b.state = append(b.state, StateResource{Address: r.Address, DependsOn: r.DependsOn})
+	}
+	for _, child := range m.ChildModules {
+		child.addTo(b)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parityInputs returns the example plans and the duplicate and mixed-case
+// key fixtures, keyed by file name without extension.
+func parityInputs(t *testing.T) map[string][]byte {
+	t.Helper()
+	examples, err := filepath.Glob("../../examples/plan/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(examples) == 0 {
+		t.Fatal("no example plans found")
+	}
+	fixtures, err := filepath.Glob("testdata/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := make(map[string][]byte)
+	for _, p := range append(examples, fixtures...) {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs[strings.TrimSuffix(filepath.Base(p), ".json")] = data
+	}
+	return inputs
+}
+
+// TestStreamingMatchesBaselineParser compares the streaming decoder with
+// testdata/baseline/*.golden, the resource changes and drift the
+// json.Unmarshal parser it replaced produced for the same inputs. That
+// parser predates lifecycle settings, so those are left out of the
+// comparison.
+func TestStreamingMatchesBaselineParser(t *testing.T) {
+	for name, data := range parityInputs(t) {
+		t.Run(name, func(t *testing.T) {
+			golden, err := os.ReadFile(filepath.Join("testdata", "baseline", name+".golden"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			plan, err := parsePlan(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range plan.ResourceChanges {
+				plan.ResourceChanges[i].Lifecycle = nil
+			}
+			got, err := json.Marshal(struct {
+				ResourceChanges []ResourceChange
+				ResourceDrift   []ResourceDrift
+			}{plan.ResourceChanges, plan.ResourceDrift})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var gotValue, wantValue any
+			if err := json.Unmarshal(got, &gotValue); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(golden, &wantValue); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotValue, wantValue) {
+				t.Errorf("streamed plan differs from the baseline parser:\ngot  %s\nwant %s", got, bytes.TrimSpace(golden))
+			}
+		})
+	}
+}
+
+func TestStreamingMatchesWholeDocument(t *testing.T) {
+	inputs := parityInputs(t)
+	inputs["synthetic"] = syntheticPlan(50, 1024)
+
+	for name, data := range inputs {
+		t.Run(name, func(t *testing.T) {
+			want, err := unmarshalPlan(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parsePlan(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("streamed plan differs from whole-document decode")
+			}
+		})
+	}
+}
+
+func TestStreamingSkipsUnusedKeysAndRejectsBadInput(t *testing.T) {
+	plan, err := parsePlan([]byte(`{
+		"format_version": "1.2",
+		"prior_state": {"values": {"root_module": {"resources": [{"a": [1, {"b": null}]}]}}},
+		"resource_changes": null,
+		"configuration": {"provider_config": {}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.ResourceChanges) != 0 {
+		t.Errorf("expected no changes, got %d", len(plan.ResourceChanges))
+	}
+
+	for name, input := range map[string]string{
+		"array":     `[]`,
+		"trailing":  `{"resource_changes": []} {}`,
+		"truncated": `{"resource_changes": [{"address": "aws_instance.a"`,
+		"not array": `{"resource_changes": {}}`,
+	} {
+		if _, err := parsePlan([]byte(input)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestDocumentKindValidation(t *testing.T) {
+	plan, err := parsePlan([]byte(`{"format_version": "1.2", "terraform_version": "1.9.5", "planned_values": {}}`))
+	if err != nil {
+		t.Fatalf("empty plan should parse: %v", err)
+	}
+	if plan.Metadata.TerraformVersion != "1.9.5" || plan.Metadata.FormatVersion != "1.2" {
+		t.Errorf("unexpected metadata: %+v", plan.Metadata)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		kind  DocumentKind
+	}{
+		{"state show", `{"format_version": "1.0", "terraform_version": "1.9.5", "values": {"root_module": {}}}`, DocumentState},
+		{"raw state", `{"version": 4, "terraform_version": "1.9.5", "serial": 3, "lineage": "abc", "resources": []}`, DocumentState},
+		{"unrelated object", `{"name": "diffy"}`, DocumentUnknown},
+		{"empty object", `{}`, DocumentUnknown},
+		{"array", `[]`, DocumentUnknown},
+		{"null", `null`, DocumentUnknown},
+		{"future major", `{"format_version": "2.0", "resource_changes": []}`, DocumentPlan},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parsePlan([]byte(tt.input))
+			var docErr *UnsupportedDocumentError
+			if !errors.As(err, &docErr) {
+				t.Fatalf("expected UnsupportedDocumentError, got %v", err)
+			}
+			if docErr.Kind != tt.kind {
+				t.Errorf("expected kind %s, got %s", tt.kind, docErr.Kind)
+			}
+			if docErr.Hint() == "" {
+				t.Error("expected an actionable hint")
+			}
+		})
+	}
+}
+
+func TestFormatVersionMajor(t *testing.T) {
+	tests := []struct {
+		version string
+		wantErr bool
+	}{
+		{"0.1", false},
+		{"0.2", false},
+		{"1.2", false},
+		{"2.0", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			_, err := parsePlan([]byte(fmt.Sprintf(`{"format_version": %q, "resource_changes": []}`, tt.version)))
+			var docErr *UnsupportedDocumentError
+			if got := errors.As(err, &docErr); got != tt.wantErr {
+				t.Errorf("format_version %s: expected rejection %v, got error %v", tt.version, tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestParseErrorLocatesProblem(t *testing.T) {
+	// A large skipped prior_state pushes the error past the retained window.
+	padding := strings.Repeat(" ", 3*positionWindow)
+	tests := []struct {
+		name    string
+		input   string
+		path    string
+		line    int
+		column  int
+		message string
+	}{
+		{
+			name: "wrong type",
+			input: `{"resource_changes": [
+  {"address": "a", "change": {"actions": ["create"]}},
+  {"address": "b", "change": {"actions": ["update"], "replace_paths": "engine"}}
+]}`,
+			path:    "resource_changes[1].change.replace_paths",
+			line:    3,
+			column:  78,
+			message: "expected array, got string",
+		},
+		{
+			name: "syntax",
+			input: `{"resource_changes": [
+  {"address": "a", "change": {"actions": ["create" "update"]}}
+]}`,
+			path:    "resource_changes[0]",
+			line:    2,
+			column:  52,
+			message: "invalid character",
+		},
+		{
+			name:    "top-level type",
+			input:   `{"format_version": 1.2, "resource_changes": []}`,
+			path:    "format_version",
+			line:    1,
+			column:  20,
+			message: "expected string, got number",
+		},
+		{
+			name:    "not an array",
+			input:   `{"resource_changes": {}}`,
+			path:    "resource_changes",
+			line:    1,
+			column:  22,
+			message: "expected array, got object",
+		},
+		{
+			name:    "truncated",
+			input:   `{"resource_changes": [{"address": "a"`,
+			path:    "resource_changes[0]",
+			message: "unexpected end of input",
+		},
+		{
+			name:    "after large skipped value",
+			input:   `{"prior_state": {"pad": "` + padding + "\"},\n" + `"resource_changes": [{"address": 5}]}`,
+			path:    "resource_changes[0].address",
+			line:    2,
+			column:  34,
+			message: "expected string, got number",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parsePlan([]byte(tt.input))
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("expected ParseError, got %v", err)
+			}
+			if pe.Path != tt.path {
+				t.Errorf("path: expected %q, got %q", tt.path, pe.Path)
+			}
+			if tt.line > 0 && (pe.Line != tt.line || pe.Column != tt.column) {
+				t.Errorf("position: expected %d:%d, got %d:%d", tt.line, tt.column, pe.Line, pe.Column)
+			}
+			if !strings.Contains(pe.Message(), tt.message) {
+				t.Errorf("message: expected %q in %q", tt.message, pe.Message())
+			}
+			if !strings.Contains(pe.Error(), tt.path) {
+				t.Errorf("Error() should include the path: %s", pe.Error())
+			}
+		})
+	}
+}
+
+func TestParseErrorExcerptMasksStrings(t *testing.T) {
+	_, err := parsePlan([]byte(`{"resource_changes": [{"address": "a", "change": {"after": {"password": "hunter2"}, "replace_paths": "engine"}}]}`))
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+	if strings.Contains(pe.Excerpt, "hunter2") || strings.Contains(pe.Excerpt, "engine") {
+		t.Errorf("excerpt leaks string values: %s", pe.Excerpt)
+	}
+	if !strings.Contains(pe.Excerpt, `"replace_paths":"***"`) && !strings.Contains(pe.Excerpt, `"replace_paths": "***"`) {
+		t.Errorf("excerpt should keep keys and mask values: %s", pe.Excerpt)
+	}
+	if got := []rune(pe.Excerpt)[pe.Caret]; got != '"' {
+		t.Errorf("caret should point at the offending value, got %q in %s", got, pe.Excerpt)
+	}
+}
+
+// fakeBinary writes an executable shell script named name into dir. Scripts
+// run with PATH limited to dir, so they may only use shell builtins.
+func fakeBinary(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func skipWithoutShell(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake binaries are shell scripts")
+	}
+}
+
+func TestResolveBinary(t *testing.T) {
+	skipWithoutShell(t)
+
+	onlyTofu := t.TempDir()
+	tofu := fakeBinary(t, onlyTofu, "tofu", "exit 0")
+	both := t.TempDir()
+	terraform := fakeBinary(t, both, "terraform", "exit 0")
+	fakeBinary(t, both, "tofu", "exit 0")
+	custom := fakeBinary(t, t.TempDir(), "tf-wrapper", "exit 0")
+
+	tests := []struct {
+		name    string
+		path    string
+		env     string
+		flag    string
+		want    string
+		wantErr string
+	}{
+		{name: "prefers terraform", path: both, want: terraform},
+		{name: "falls back to tofu", path: onlyTofu, want: tofu},
+		{name: "env override", path: both, env: custom, want: custom},
+		{name: "flag beats env", path: onlyTofu, env: custom, flag: "tofu", want: tofu},
+		{name: "none installed", path: t.TempDir(), wantErr: "neither terraform nor tofu found in PATH"},
+		{name: "missing flag binary", path: both, flag: "tofu-nightly", wantErr: "tofu-nightly not found (set by --binary)"},
+		{name: "missing env binary", path: both, env: "tofu-nightly", wantErr: "set by $" + BinaryEnv},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PATH", tt.path)
+			t.Setenv(BinaryEnv, tt.env)
+			got, err := ResolveBinary(tt.flag)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLoadPlanBinaryRunsShow(t *testing.T) {
+	skipWithoutShell(t)
+
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	fakeBinary(t, dir, "tofu", `echo "$@" > `+argsFile+`
+printf '%s' '{"format_version": "1.2", "terraform_version": "1.8.3", "resource_changes": [{"address": "aws_instance.web", "type": "aws_instance", "provider_name": "registry.opentofu.org/hashicorp/aws", "change": {"actions": ["create"], "before": null, "after": {}}}]}'`)
+	t.Setenv("PATH", dir)
+	t.Setenv(BinaryEnv, "")
+
+	plan, err := LoadPlanBinary("plan.out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(args)); got != "show -json plan.out" {
+		t.Errorf("unexpected arguments %q", got)
+	}
+	if len(plan.ResourceChanges) != 1 || plan.ResourceChanges[0].Action != ActionCreate {
+		t.Errorf("unexpected changes: %+v", plan.ResourceChanges)
+	}
+	if plan.Metadata.Tool != ToolOpenTofu || plan.Metadata.TerraformVersion != "1.8.3" {
+		t.Errorf("unexpected metadata: %+v", plan.Metadata)
+	}
+}
+
+func TestLoadPlanBinaryPropagatesStderr(t *testing.T) {
+	skipWithoutShell(t)
+
+	dir := t.TempDir()
+	fakeBinary(t, dir, "terraform", `echo "Error: Failed to read the given file as a state or plan file" >&2
+exit 1`)
+	t.Setenv("PATH", dir)
+	t.Setenv(BinaryEnv, "")
+
+	_, err := LoadPlanBinary("plan.out")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	want := "terraform show failed: Error: Failed to read the given file as a state or plan file"
+	if err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+}
+
+func TestLoadPlanBinaryContextKillsProcessGroup(t *testing.T) {
+	skipWithoutShell(t)
+	if _, err := os.Stat("/bin/sleep"); err != nil {
+		t.Skip("needs /bin/sleep")
+	}
+
+	dir := t.TempDir()
+	// The grandchild keeps stdout open; only killing the group ends it.
+	fakeBinary(t, dir, "terraform", `/bin/sleep 30 &
+wait`)
+	t.Setenv("PATH", dir)
+	t.Setenv(BinaryEnv, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := LoadPlanBinaryContext(ctx, "", "plan.out")
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected prompt return after timeout, took %s", elapsed)
+	}
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected TimeoutError, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("TimeoutError should wrap context.DeadlineExceeded")
+	}
+	if timeoutErr.Command != "terraform show" {
+		t.Errorf("unexpected command %q", timeoutErr.Command)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	_, err = LoadPlanBinaryContext(ctx, "", "plan.out")
+	if errors.As(err, &timeoutErr) || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a cancellation error distinct from a timeout, got %v", err)
+	}
+}
+
+func TestOpenTofuDetectedFromProviders(t *testing.T) {
+	plan, err := parsePlan([]byte(`{
+		"format_version": "1.2",
+		"terraform_version": "1.8.3",
+		"resource_changes": [{
+			"address": "aws_s3_bucket.logs",
+			"type": "aws_s3_bucket",
+			"provider_name": "registry.opentofu.org/hashicorp/aws",
+			"change": {"actions": ["update"], "before": {"tags": {}}, "after": {"tags": {"a": "b"}}}
+		}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Metadata.Tool != ToolOpenTofu {
+		t.Errorf("expected tool %s, got %q", ToolOpenTofu, plan.Metadata.Tool)
+	}
+
+	plan, err = parsePlan([]byte(`{"resource_changes": [{"address": "a.b", "provider_name": "registry.terraform.io/hashicorp/aws", "change": {"actions": ["create"]}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Metadata.Tool != "" {
+		t.Errorf("a Terraform registry provider alone should not set the tool, got %q", plan.Metadata.Tool)
+	}
+}
+
+// syntheticPlan builds a plan with n updates, each paired with an unused
+// prior_state resource carrying payload bytes, mimicking the large
+// prior_state and configuration blocks of real-world plans.
+func syntheticPlan(n, payload int) []byte {
+	blob := strings.Repeat("x", payload)
+	var changes, resources []string
+	for i := 0; i < n; i++ {
+		addr := fmt.Sprintf("aws_instance.web[%d]", i)
+		changes = append(changes, fmt.Sprintf(`{"address": %q, "type": "aws_instance", "name": "web", "index": %d,
+			"change": {"actions": ["update"], "before": {"ami": "a", "tags": {"n": "%d"}}, "after": {"ami": "b", "tags": {"n": "%d"}}, "after_unknown": {}}}`,
+			addr, i, i, i))
+		resources = append(resources, fmt.Sprintf(`{"address": %q, "values": {"user_data": %q}}`, addr, blob))
+	}
+	return []byte(fmt.Sprintf(`{"format_version": "1.2", "terraform_version": "1.9.5",
+		"prior_state": {"values": {"root_module": {"resources": [%s]}}},
+		"configuration": {"root_module": {"resources": [%s]}},
+		"resource_changes": [%s]}`,
+		strings.Join(resources, ","), strings.Join(resources, ","), strings.Join(changes, ",")))
+}
+
+// peakHeap runs fn while sampling HeapAlloc and returns the highest value
+// seen above the starting heap. B/op reports cumulative allocation, which
+// does not show how much of the plan is held at once.
+func peakHeap(fn func()) uint64 {
+	runtime.GC()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	base := ms.HeapAlloc
+
+	var peak atomic.Uint64
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		var s runtime.MemStats
+		for {
+			runtime.ReadMemStats(&s)
+			if s.HeapAlloc > base && s.HeapAlloc-base > peak.Load() {
+				peak.Store(s.HeapAlloc - base)
+			}
+			select {
+			case <-done:
+				return
+			case <-time.After(100 * time.Microsecond):
+			}
+		}
+	}()
+	fn()
+	close(done)
+	<-stopped
+	return peak.Load()
+}
+
+func writeSyntheticPlan(b *testing.B) string {
+	b.Helper()
+	path := filepath.Join(b.TempDir(), "plan.json")
+	if err := os.WriteFile(path, syntheticPlan(2000, 16*1024), 0o644); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+func BenchmarkLoadFileStreaming(b *testing.B) {
+	path := writeSyntheticPlan(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	var peak uint64
+	for i := 0; i < b.N; i++ {
+		p := peakHeap(func() {
+			if _, err := LoadFile(path); err != nil {
+				b.Fatal(err)
+			}
+		})
+		if p > peak {
+			peak = p
+		}
+	}
+	b.ReportMetric(float64(peak)/(1<<20), "peak-MB")
+}
+
+func BenchmarkUnmarshalWholePlan(b *testing.B) {
+	path := writeSyntheticPlan(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	var peak uint64
+	for i := 0; i < b.N; i++ {
+		p := peakHeap(func() {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := unmarshalPlan(data); err != nil {
+				b.Fatal(err)
+			}
+		})
+		if p > peak {
+			peak = p
+		}
+	}
+	b.ReportMetric(float64(peak)/(1<<20), "peak-MB")
+}
+
 func containsPath(paths []string, want string) bool {
 	for _, p := range paths {
 		if p == want {
@@ -153,3 +1241,235 @@ func containsPath(paths []string, want string) bool {
 	}
 	return false
 }
+
+func TestExpandPlanPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"live/prod/vpc/plan.json",
+		"live/prod/app/plan.json.gz",
+		"live/prod/app/notes.txt",
+		"live/.terragrunt-cache/abc/plan.json",
+		"other/a.json",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	join := func(name string) string { return filepath.Join(dir, name) }
+
+	got, err := ExpandPlanPaths([]string{join("live"), join("other/*.json"), join("live/prod/vpc/plan.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{join("live/prod/app/plan.json.gz"), join("live/prod/vpc/plan.json"), join("other/a.json")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if _, err := ExpandPlanPaths([]string{join("missing/*.json")}); err == nil || !strings.Contains(err.Error(), "no plan files match") {
+		t.Errorf("expected an unmatched glob error, got %v", err)
+	}
+	if _, err := ExpandPlanPaths([]string{join("empty")}); err == nil || !strings.Contains(err.Error(), "no plan JSON files found") {
+		t.Errorf("expected an empty directory error, got %v", err)
+	}
+}
+
+func TestStackNames(t *testing.T) {
+	tests := []struct {
+		paths []string
+		want  []string
+	}{
+		{[]string{"live/prod/vpc/plan.json", "live/prod/app/plan.json"}, []string{"vpc", "app"}},
+		{[]string{"live/prod/vpc/plan.json", "live/staging/vpc/plan.json"}, []string{"prod/vpc", "staging/vpc"}},
+		{[]string{"plans/network.json", "plans/app.json.gz"}, []string{"network", "app"}},
+		{[]string{"plans/network.json"}, []string{"network"}},
+	}
+	for _, tt := range tests {
+		if got := StackNames(tt.paths); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("StackNames(%v) = %v, want %v", tt.paths, got, tt.want)
+		}
+	}
+}
+
+func TestTagStack(t *testing.T) {
+	plan, err := LoadFile("../../examples/plan/drift.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan.TagStack("prod/vpc")
+	for _, ch := range plan.ResourceChanges {
+		if ch.Stack != "prod/vpc" {
+			t.Errorf("change %s not tagged", ch.Address)
+		}
+	}
+	for _, d := range plan.ResourceDrift {
+		if d.Stack != "prod/vpc" {
+			t.Errorf("drift %s not tagged", d.Address)
+		}
+	}
+}
+
+func TestOutputChanges(t *testing.T) {
+	plan, err := LoadFile("../../examples/plan/outputs.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := map[string]OutputChange{}
+	var names []string
+	for _, o := range plan.OutputChanges {
+		byName[o.Name] = o
+		names = append(names, o.Name)
+	}
+	want := []string{"api_endpoint", "db_password", "legacy_endpoint", "subnet_ids", "vpc_id"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("outputs = %v, want %v (unchanged outputs dropped, document order kept)", names, want)
+	}
+
+	if o := byName["api_endpoint"]; o.Action != ActionCreate || !o.AfterUnknown || o.AfterType() != "" {
+		t.Errorf("api_endpoint = %+v, want unknown create", o)
+	}
+	if o := byName["legacy_endpoint"]; o.Action != ActionDelete || o.BeforeType() != ValueTypeString || o.AfterType() != "" {
+		t.Errorf("legacy_endpoint = %+v (before %q, after %q)", o, o.BeforeType(), o.AfterType())
+	}
+	if o := byName["subnet_ids"]; o.BeforeType() != ValueTypeString || o.AfterType() != ValueTypeList {
+		t.Errorf("subnet_ids types = %q → %q, want string → list", o.BeforeType(), o.AfterType())
+	}
+	if o := byName["vpc_id"]; o.Action != ActionUpdate || o.BeforeSensitive || !o.AfterSensitive {
+		t.Errorf("vpc_id = %+v, want sensitivity-only change kept as update", o)
+	}
+
+	o := byName["db_password"]
+	if !o.BeforeSensitive || o.AfterSensitive {
+		t.Errorf("db_password sensitivity = %v → %v, want true → false", o.BeforeSensitive, o.AfterSensitive)
+	}
+	redacted := o.Redacted()
+	if strings.Contains(string(redacted.Before), "hunter2") {
+		t.Errorf("sensitive before value not redacted: %s", redacted.Before)
+	}
+	if string(redacted.After) != string(o.After) {
+		t.Errorf("non-sensitive after value changed by redaction: %s", redacted.After)
+	}
+
+	if got := ComputeOutputCounts(plan.OutputChanges); got != (OutputCounts{Create: 1, Update: 3, Delete: 1, Total: 5}) {
+		t.Errorf("output counts = %+v", got)
+	}
+
+	plan.TagStack("prod/api")
+	for _, o := range plan.OutputChanges {
+		if o.Stack != "prod/api" {
+			t.Errorf("output %s not tagged", o.Name)
+		}
+	}
+}
+
+func TestOutputChangesMustBeObject(t *testing.T) {
+	_, err := parsePlan([]byte(`{"format_version":"1.2","output_changes":[]}`))
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Path != "output_changes" {
+		t.Fatalf("err = %v, want ParseError at output_changes", err)
+	}
+
+	_, err = parsePlan([]byte(`{"format_version":"1.2","output_changes":{"vpc_id":5}}`))
+	if !errors.As(err, &pe) || pe.Path != "output_changes.vpc_id" {
+		t.Fatalf("err = %v, want ParseError at output_changes.vpc_id", err)
+	}
+}
+
+func TestConfigurationAndPriorStateReferences(t *testing.T) {
+	plan, err := LoadFile("../../examples/plan/blast_radius.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resources := map[string]ConfigResource{}
+	for _, r := range plan.Configuration.Resources {
+		resources[joinModule(r.Module, r.Address)] = r
+	}
+	if got := resources["aws_subnet.private"].References; !reflect.DeepEqual(got, []string{"aws_vpc.main", "aws_vpc.main.id", "count.index"}) {
+		t.Errorf("aws_subnet.private references = %v", got)
+	}
+	sg, ok := resources["module.app.aws_security_group.web"]
+	if !ok || sg.Module != "module.app" {
+		t.Fatalf("module resource not recorded with its module: %+v", plan.Configuration.Resources)
+	}
+	if !reflect.DeepEqual(sg.References, []string{"data.aws_vpc.peer", "data.aws_vpc.peer.cidr_block", "var.vpc_id"}) {
+		t.Errorf("nested block references not collected: %v", sg.References)
+	}
+
+	if len(plan.Configuration.ModuleCalls) != 1 || plan.Configuration.ModuleCalls[0].Module != "module.app" {
+		t.Fatalf("module calls = %+v", plan.Configuration.ModuleCalls)
+	}
+	if got := plan.Configuration.ModuleCalls[0].Inputs["vpc_id"]; !reflect.DeepEqual(got, []string{"aws_vpc.main", "aws_vpc.main.id"}) {
+		t.Errorf("vpc_id input references = %v", got)
+	}
+	if len(plan.Configuration.Outputs) != 1 || plan.Configuration.Outputs[0].Name != "private_ip" {
+		t.Errorf("outputs = %+v", plan.Configuration.Outputs)
+	}
+
+	var state []string
+	for _, r := range plan.PriorState {
+		state = append(state, r.Address)
+		if r.Address == "aws_flow_log.main" && !reflect.DeepEqual(r.DependsOn, []string{"aws_vpc.main"}) {
+			t.Errorf("flow log depends_on = %v", r.DependsOn)
+		}
+	}
+	want := []string{"aws_vpc.main", "aws_subnet.private[0]", "aws_subnet.private[1]", "aws_flow_log.main", "aws_route53_record.api", "module.app.aws_instance.web", "module.app.aws_security_group.web"}
+	if !reflect.DeepEqual(state, want) {
+		t.Errorf("prior state = %v, want %v", state, want)
+	}
+}
+
+func TestConfigAddress(t *testing.T) {
+	addr, err := ParseAddress(`module.net["a"].module.vpc[0].aws_subnet.private["x"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := addr.ConfigAddress(); got != "module.net.module.vpc.aws_subnet.private" {
+		t.Errorf("ConfigAddress = %q", got)
+	}
+}
+
+func TestLifecycleAttachedToChanges(t *testing.T) {
+	data := []byte(`{
+		"format_version": "1.2",
+		"resource_changes": [
+			{"address": "module.db[\"a\"].aws_db_instance.main", "type": "aws_db_instance", "change": {"actions": ["delete"]}},
+			{"address": "aws_instance.app[0]", "type": "aws_instance", "change": {"actions": ["update"]}},
+			{"address": "aws_instance.plain", "type": "aws_instance", "change": {"actions": ["update"]}}
+		],
+		"configuration": {"root_module": {
+			"resources": [
+				{"address": "aws_instance.app", "mode": "managed", "lifecycle": {"create_before_destroy": true, "ignore_changes": ["tags", ["ingress", 0, "cidr_blocks"]]}},
+				{"address": "aws_instance.plain", "mode": "managed"}
+			],
+			"module_calls": {"db": {"module": {"resources": [
+				{"address": "aws_db_instance.main", "mode": "managed", "lifecycle": {"prevent_destroy": false, "ignore_changes": "all"}}
+			]}}}
+		}}
+	}`)
+	plan, err := parsePlan(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, app, plain := plan.ResourceChanges[0].Lifecycle, plan.ResourceChanges[1].Lifecycle, plan.ResourceChanges[2].Lifecycle
+
+	if db == nil || !db.PreventDestroyDisabled() || !reflect.DeepEqual(db.IgnoreChanges, []string{IgnoreAll}) {
+		t.Errorf("module resource lifecycle = %+v", db)
+	}
+	if app == nil || !app.CreateBeforeDestroy || app.PreventDestroyDisabled() {
+		t.Errorf("app lifecycle = %+v", app)
+	}
+	if want := []string{"tags", "ingress[0].cidr_blocks"}; app == nil || !reflect.DeepEqual(app.IgnoreChanges, want) {
+		t.Errorf("app ignore_changes = %v, want %v", app.IgnoreChanges, want)
+	}
+	if plain != nil {
+		t.Errorf("resource without a lifecycle block got %+v", plain)
+	}
+}
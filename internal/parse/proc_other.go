@@ -0,0 +1,16 @@
+//go:build !unix
+
+package parse
+
+import "os/exec"
+
+// setProcessGroup is a no-op where process groups are not available.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills cmd itself; children are not tracked.
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
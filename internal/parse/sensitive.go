@@ -0,0 +1,187 @@
+package parse
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RedactedValue replaces every sensitive leaf in redacted output.
+const RedactedValue = "(sensitive value)"
+
+// Redacted returns a copy of the change with every sensitive leaf in Before
+// and After replaced by RedactedValue. Renderers must only read values from
+// a redacted change.
+func (ch ResourceChange) Redacted() ResourceChange {
+	out := ch
+	out.Before = Redact(ch.Before, ch.BeforeSensitive)
+	out.After = Redact(ch.After, ch.AfterSensitive)
+	return out
+}
+
+// SensitiveValues returns the string form of every sensitive scalar in
+// Before and After, so free text derived from them can be scrubbed.
+func (ch ResourceChange) SensitiveValues() []string {
+	var out []string
+	collectSensitive(decodeValue(ch.Before), decodeValue(ch.BeforeSensitive), &out)
+	collectSensitive(decodeValue(ch.After), decodeValue(ch.AfterSensitive), &out)
+	return sortedUnique(out)
+}
+
+// Redact replaces the leaves of value marked true in mask with RedactedValue.
+// A mask of true redacts the whole value.
+func Redact(value, mask json.RawMessage) json.RawMessage {
+	if len(value) == 0 || len(mask) == 0 {
+		return value
+	}
+	m := decodeValue(mask)
+	if !hasTrueLeaf(m) {
+		return value
+	}
+	redacted, err := json.Marshal(redactAny(decodeValue(value), m))
+	if err != nil {
+		return json.RawMessage(strconv.Quote(RedactedValue))
+	}
+	return redacted
+}
+
+func redactAny(value, mask any) any {
+	switch m := mask.(type) {
+	case bool:
+		if m && value != nil {
+			return RedactedValue
+		}
+		return value
+	case map[string]any:
+		v, ok := value.(map[string]any)
+		if !ok {
+			return value
+		}
+		for k, sub := range m {
+			if _, exists := v[k]; exists {
+				v[k] = redactAny(v[k], sub)
+			}
+		}
+		return v
+	case []any:
+		v, ok := value.([]any)
+		if !ok {
+			return value
+		}
+		for i := range v {
+			if i < len(m) {
+				v[i] = redactAny(v[i], m[i])
+			}
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+func collectSensitive(value, mask any, out *[]string) {
+	switch m := mask.(type) {
+	case bool:
+		if m {
+			collectScalars(value, out)
+		}
+	case map[string]any:
+		v, ok := value.(map[string]any)
+		if !ok {
+			return
+		}
+		for k, sub := range m {
+			collectSensitive(v[k], sub, out)
+		}
+	case []any:
+		v, ok := value.([]any)
+		if !ok {
+			return
+		}
+		for i := range v {
+			if i < len(m) {
+				collectSensitive(v[i], m[i], out)
+			}
+		}
+	}
+}
+
+func collectScalars(value any, out *[]string) {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, sub := range v {
+			*out = append(*out, k)
+			collectScalars(sub, out)
+		}
+	case []any:
+		for _, sub := range v {
+			collectScalars(sub, out)
+		}
+	case string:
+		if v != "" {
+			*out = append(*out, v)
+		}
+	case float64:
+		*out = append(*out, strconv.FormatFloat(v, 'f', -1, 64))
+	case bool:
+		*out = append(*out, strconv.FormatBool(v))
+	}
+}
+
+// sensitivePaths returns the paths marked true in a sensitivity mask.
+func sensitivePaths(path string, mask any, out *[]string) {
+	switch m := mask.(type) {
+	case bool:
+		if m && path != "" {
+			*out = append(*out, path)
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := k
+			if path != "" {
+				child = path + "." + k
+			}
+			sensitivePaths(child, m[k], out)
+		}
+	case []any:
+		for i, sub := range m {
+			sensitivePaths(path+"["+strconv.Itoa(i)+"]", sub, out)
+		}
+	}
+}
+
+// collapseSensitive truncates change paths that descend into a sensitive
+// value, so map keys inside a sensitive attribute are not exposed.
+func collapseSensitive(paths, sensitive []string) []string {
+	if len(paths) == 0 || len(sensitive) == 0 {
+		return paths
+	}
+	out := make([]string, 0, len(paths))
+	for _, p := range paths {
+		for _, s := range sensitive {
+			if strings.HasPrefix(p, s+".") || strings.HasPrefix(p, s+"[") {
+				p = s
+				break
+			}
+		}
+		out = append(out, p)
+	}
+	return sortedUnique(out)
+}
+
+func decodeValue(raw json.RawMessage) any {
+	if len(raw) == 0 {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil
+	}
+	return v
+}
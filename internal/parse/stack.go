@@ -0,0 +1,152 @@
+package parse
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// planFileSuffixes are the file names ExpandPlanPaths collects from
+// directories, longest first so stripping removes the whole suffix.
+var planFileSuffixes = []string{".json.gz", ".json.zst", ".json"}
+
+// SourceError attributes an error to the plan file it came from.
+type SourceError struct {
+	Path string
+	Err  error
+}
+
+func (e *SourceError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// ExpandPlanPaths turns command-line arguments into plan file paths. Plain
+// paths are kept as given, glob patterns are expanded, and directories are
+// walked for *.json, *.json.gz and *.json.zst files, skipping hidden
+// directories such as .terraform and .terragrunt-cache. Duplicates are
+// dropped and order follows the arguments.
+func ExpandPlanPaths(args []string) ([]string, error) {
+	var out []string
+	seen := make(map[string]bool)
+	add := func(p string) {
+		p = filepath.Clean(p)
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+
+	for _, arg := range args {
+		matches := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			var err error
+			matches, err = filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no plan files match %q", arg)
+			}
+		}
+
+		for _, m := range matches {
+			info, err := os.Stat(m)
+			if err != nil || !info.IsDir() {
+				// Missing files surface as load errors with the usual message.
+				add(m)
+				continue
+			}
+			found, err := planFilesIn(m)
+			if err != nil {
+				return nil, err
+			}
+			if len(found) == 0 {
+				return nil, fmt.Errorf("no plan JSON files found in %s", m)
+			}
+			for _, f := range found {
+				add(f)
+			}
+		}
+	}
+	return out, nil
+}
+
+func planFilesIn(dir string) ([]string, error) {
+	var found []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if trimPlanSuffix(d.Name()) != d.Name() {
+			found = append(found, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading plan directory: %w", err)
+	}
+	sort.Strings(found)
+	return found, nil
+}
+
+// StackNames derives a short, unique stack name for each plan path: the
+// path relative to the files' common directory, without the plan file
+// suffix. When every file has the same name (e.g. one plan.json per
+// Terragrunt module), the file name is dropped and the directory remains.
+func StackNames(paths []string) []string {
+	rel := make([]string, len(paths))
+	for i, p := range paths {
+		rel[i] = filepath.ToSlash(filepath.Clean(p))
+	}
+
+	common := path.Dir(rel[0])
+	for _, p := range rel[1:] {
+		for common != "." && common != "/" && !strings.HasPrefix(p, common+"/") {
+			common = path.Dir(common)
+		}
+	}
+	sameBase := true
+	for _, p := range rel[1:] {
+		if path.Base(p) != path.Base(rel[0]) {
+			sameBase = false
+			break
+		}
+	}
+
+	names := make([]string, len(rel))
+	for i, p := range rel {
+		if common != "." {
+			p = strings.TrimPrefix(strings.TrimPrefix(p, common), "/")
+		}
+		if sameBase && len(rel) > 1 {
+			p = path.Dir(p)
+		} else {
+			p = trimPlanSuffix(p)
+		}
+		names[i] = p
+	}
+	return names
+}
+
+func trimPlanSuffix(name string) string {
+	for _, suffix := range planFileSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix)
+		}
+	}
+	return name
+}
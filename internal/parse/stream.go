@@ -0,0 +1,353 @@
+package parse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// decodePlan streams a plan document from r. Only the top-level keys Diffy
+// uses are decoded, one array element or object member at a time.
+// configuration and prior_state are walked for references and depends_on
+// only, and everything else (notably planned_values) is skipped token by
+// token without being buffered. Malformed or wrongly-typed input is reported as a
+// *ParseError locating the problem.
+//
+// Keys are read as json.Unmarshal would read them into a struct: they match
+// case-insensitively, and a repeated key replaces the earlier value.
+func decodePlan(r io.Reader) (*Plan, error) {
+	pos := newPositionReader(r)
+	d := &planDecoder{dec: json.NewDecoder(pos), pos: pos}
+
+	tok, err := d.dec.Token()
+	if err != nil {
+		return nil, d.fail(err, "", 0)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, &UnsupportedDocumentError{Kind: DocumentUnknown}
+	}
+
+	seen := make(map[string]bool)
+	for d.dec.More() {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return nil, d.fail(err, "", 0)
+		}
+		name, _ := tok.(string)
+		key := fieldName(name, documentKeys...)
+		if key != "" {
+			seen[key] = true
+		}
+
+		switch key {
+		case "format_version":
+			err = d.decodeValue(key, &d.b.metadata.FormatVersion)
+		case "terraform_version":
+			err = d.decodeValue(key, &d.b.metadata.TerraformVersion)
+		case "resource_changes":
+			d.b.changes = nil
+			err = d.decodeArray(key, func(path string) error {
+				var rc tfResourceChange
+				if err := d.decodeValue(path, &rc); err != nil {
+					return err
+				}
+				d.b.addChange(rc)
+				return nil
+			})
+		case "resource_drift":
+			d.b.drift = nil
+			err = d.decodeArray(key, func(path string) error {
+				var rc tfResourceChange
+				if err := d.decodeValue(path, &rc); err != nil {
+					return err
+				}
+				d.b.addDrift(rc)
+				return nil
+			})
+		case "output_changes":
+			err = d.decodeObject(key, func(name, path string) error {
+				var oc tfOutputChange
+				if err := d.decodeValue(path, &oc); err != nil {
+					return err
+				}
+				d.b.addOutput(name, oc)
+				return nil
+			})
+		case "configuration":
+			d.b.config = Configuration{}
+			err = d.decodeConfiguration(key)
+		case "prior_state":
+			d.b.state = nil
+			err = d.decodePriorState(key)
+		case "deferred_changes":
+			d.b.deferred = nil
+			err = d.decodeArray(key, func(path string) error {
+				var dc tfDeferredChange
+				if err := d.decodeValue(path, &dc); err != nil {
+					return err
+				}
+				d.b.addDeferred(dc)
+				return nil
+			})
+		default:
+			err = d.skipValue(name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Closing '}'
+	if _, err := d.dec.Token(); err != nil {
+		return nil, d.fail(err, "", 0)
+	}
+	if _, err := d.dec.Token(); err != io.EOF {
+		return nil, d.fail(errors.New("unexpected data after top-level value"), "", d.dec.InputOffset())
+	}
+	if err := classifyDocument(seen, d.b.metadata.FormatVersion); err != nil {
+		return nil, err
+	}
+	return d.b.plan(), nil
+}
+
+// documentKeys are the top-level keys decodePlan decodes or classifies a
+// document by.
+var documentKeys = slices.Concat([]string{"format_version", "terraform_version"}, planKeys, stateKeys)
+
+// fieldName returns the field that name matches, ignoring case as
+// json.Unmarshal does, or "" when it matches none of them.
+func fieldName(name string, fields ...string) string {
+	for _, f := range fields {
+		if strings.EqualFold(name, f) {
+			return f
+		}
+	}
+	return ""
+}
+
+// planDecoder walks a plan document and turns decoding failures into
+// located ParseErrors.
+type planDecoder struct {
+	dec *json.Decoder
+	pos *positionReader
+	b   planBuilder
+}
+
+// decodeValue decodes the value at the decoder's position, which lives at
+// path, into v.
+func (d *planDecoder) decodeValue(path string, v any) error {
+	start := d.pos.skipSeparators(d.dec.InputOffset())
+	if err := d.dec.Decode(v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			// Type error offsets are relative to the start of the value.
+			return d.fail(err, joinPath(path, typeErr.Field), start+typeErr.Offset)
+		}
+		return d.fail(err, path, 0)
+	}
+	return nil
+}
+
+// decodeArray calls decodeElem once per element of the array at the
+// decoder's position, passing each element's path. A null array is treated
+// as empty.
+func (d *planDecoder) decodeArray(path string, decodeElem func(path string) error) error {
+	tok, err := d.dec.Token()
+	if err != nil {
+		return d.fail(err, path, 0)
+	}
+	if tok == nil {
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return d.fail(fmt.Errorf("expected array, got %s", tokenKind(tok)), path, d.dec.InputOffset())
+	}
+	for i := 0; d.dec.More(); i++ {
+		if err := decodeElem(fmt.Sprintf("%s[%d]", path, i)); err != nil {
+			return err
+		}
+	}
+	if _, err := d.dec.Token(); err != nil {
+		return d.fail(err, path, 0)
+	}
+	return nil
+}
+
+// decodeObject calls decodeMember once per member of the object at the
+// decoder's position, passing each member's name and path. A null object is
+// treated as empty.
+func (d *planDecoder) decodeObject(path string, decodeMember func(name, path string) error) error {
+	tok, err := d.dec.Token()
+	if err != nil {
+		return d.fail(err, path, 0)
+	}
+	if tok == nil {
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return d.fail(fmt.Errorf("expected object, got %s", tokenKind(tok)), path, d.dec.InputOffset())
+	}
+	for d.dec.More() {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return d.fail(err, path, 0)
+		}
+		name, _ := tok.(string)
+		if err := decodeMember(name, joinPath(path, name)); err != nil {
+			return err
+		}
+	}
+	if _, err := d.dec.Token(); err != nil {
+		return d.fail(err, path, 0)
+	}
+	return nil
+}
+
+// skipValue consumes the next value without retaining it.
+func (d *planDecoder) skipValue(path string) error {
+	depth := 0
+	for {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return d.fail(err, path, 0)
+		}
+		if delim, ok := tok.(json.Delim); ok {
+			switch delim {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// fail wraps err in a ParseError at path. offset is the input offset just
+// past the offending data; zero means "take it from err, or the end of the
+// input read so far".
+func (d *planDecoder) fail(err error, path string, offset int64) error {
+	var syntaxErr *json.SyntaxError
+	switch {
+	case offset > 0:
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	default:
+		offset = d.pos.total
+	}
+	return newParseError(err, path, offset, d.pos)
+}
+
+func joinPath(path, field string) string {
+	switch {
+	case field == "":
+		return path
+	case path == "":
+		return field
+	}
+	return path + "." + field
+}
+
+func tokenKind(tok json.Token) string {
+	switch tok.(type) {
+	case json.Delim:
+		if tok == json.Delim('{') {
+			return "object"
+		}
+		return "array"
+	case string:
+		return "string"
+	case float64, json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "null"
+}
+
+// planBuilder assembles a Plan from raw plan entries in document order,
+// keeping deferred changes after the regular ones.
+type planBuilder struct {
+	metadata Metadata
+	changes  []ResourceChange
+	drift    []ResourceDrift
+	deferred []ResourceChange
+	outputs  []OutputChange
+	config   Configuration
+	state    []StateResource
+}
+
+// noteProvider records that the plan came from OpenTofu when a provider was
+// installed from the OpenTofu registry.
+func (b *planBuilder) noteProvider(providerName string) {
+	if strings.HasPrefix(providerName, openTofuRegistry) {
+		b.metadata.Tool = ToolOpenTofu
+	}
+}
+
+func (b *planBuilder) addChange(rc tfResourceChange) {
+	b.noteProvider(rc.ProviderName)
+	if ch, ok := toResourceChange(rc); ok {
+		b.changes = append(b.changes, ch)
+	}
+}
+
+func (b *planBuilder) addDrift(rc tfResourceChange) {
+	b.noteProvider(rc.ProviderName)
+	if ch, ok := toResourceChange(rc); ok {
+		b.drift = append(b.drift, ResourceDrift{ResourceChange: ch})
+	}
+}
+
+// addOutput records an output change. A repeated output_changes key merges
+// into the earlier one, so an output seen again replaces its first entry.
+func (b *planBuilder) addOutput(name string, oc tfOutputChange) {
+	out, ok := toOutputChange(name, oc)
+	for i := range b.outputs {
+		if b.outputs[i].Name != name {
+			continue
+		}
+		if ok {
+			b.outputs[i] = out
+		} else {
+			b.outputs = append(b.outputs[:i], b.outputs[i+1:]...)
+		}
+		return
+	}
+	if ok {
+		b.outputs = append(b.outputs, out)
+	}
+}
+
+func (b *planBuilder) addDeferred(dc tfDeferredChange) {
+	b.noteProvider(dc.ResourceChange.ProviderName)
+	ch, ok := toResourceChange(dc.ResourceChange)
+	if !ok {
+		return
+	}
+	ch.DeferredReason = dc.Reason
+	if ch.DeferredReason == "" {
+		ch.DeferredReason = "unknown"
+	}
+	b.deferred = append(b.deferred, ch)
+}
+
+func (b *planBuilder) plan() *Plan {
+	changes := make([]ResourceChange, 0, len(b.changes)+len(b.deferred))
+	changes = append(changes, b.changes...)
+	changes = append(changes, b.deferred...)
+	attachLifecycle(changes, b.config)
+	return &Plan{
+		ResourceChanges: changes,
+		ResourceDrift:   b.drift,
+		OutputChanges:   b.outputs,
+		Configuration:   b.config,
+		PriorState:      b.state,
+		Metadata:        b.metadata,
+	}
+}
@@ -0,0 +1,34 @@
+package render
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sgr0691/diffy/internal/analyze"
+)
+
+// sortedAcknowledged orders acknowledged findings by severity, highest
+// first, then by stack and address.
+func sortedAcknowledged(findings []analyze.Finding) []analyze.Finding {
+	sorted := append([]analyze.Finding(nil), findings...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Severity != sorted[j].Severity {
+			return sorted[i].Severity > sorted[j].Severity
+		}
+		return resourceKey(sorted[i].Stack, sorted[i].Address) < resourceKey(sorted[j].Stack, sorted[j].Address)
+	})
+	return sorted
+}
+
+// suppressionLabel describes who acknowledged a finding and until when,
+// e.g. "owner: platform-team, expires 2026-12-31".
+func suppressionLabel(s *analyze.Suppression) string {
+	return fmt.Sprintf("owner: %s, expires %s", s.Owner, s.Expires.Format("2006-01-02"))
+}
+
+// acknowledgedTitle is the heading line of an acknowledged finding, e.g.
+// "Internet-facing load balancer detected (HIGH)".
+func acknowledgedTitle(f analyze.Finding) string {
+	return fmt.Sprintf("%s (%s)", f.Title, strings.ToUpper(f.Severity.String()))
+}
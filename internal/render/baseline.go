@@ -0,0 +1,47 @@
+package render
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/sgr0691/diffy/internal/analyze"
+)
+
+// ReadBaseline reads the fingerprints of the findings, acknowledged ones
+// included, from a previous run's JSON output.
+func ReadBaseline(r io.Reader) (analyze.Baseline, error) {
+	var doc struct {
+		Findings     *[]jsonFinding `json:"findings"`
+		Acknowledged []jsonFinding  `json:"acknowledged"`
+		Error        *jsonError     `json:"error"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("not Diffy JSON output: %w", err)
+	}
+	switch {
+	case doc.Error != nil:
+		return nil, errors.New("the run it records failed; use the output of a successful diffy explain --format json")
+	case doc.Findings == nil:
+		return nil, errors.New("not Diffy JSON output: no findings; use the output of diffy explain --format json")
+	}
+
+	baseline := make(analyze.Baseline)
+	for _, f := range append(*doc.Findings, doc.Acknowledged...) {
+		if f.Fingerprint == "" {
+			return nil, fmt.Errorf("finding %q on %s has no fingerprint; regenerate the baseline with this version of Diffy", f.Title, f.Address)
+		}
+		baseline[f.Fingerprint] = true
+	}
+	return baseline, nil
+}
+
+// baselineNote marks findings known from the baseline, which do not count
+// toward the threshold.
+func baselineNote(f analyze.Finding) string {
+	if f.InBaseline {
+		return ", in baseline"
+	}
+	return ""
+}
@@ -0,0 +1,45 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sgr0691/diffy/internal/graph"
+)
+
+// blastRadiusShown is how many dependents md and text output list by name.
+const blastRadiusShown = 5
+
+// blastRadiusLabel summarizes the dependents of a destroyed resource, e.g.
+// "23 resources depend on it (4 also change in this plan): a, b, ... and 18
+// more". quote formats each address.
+func blastRadiusLabel(deps []graph.Dependent, quote func(string) string) string {
+	changed := 0
+	for _, d := range deps {
+		if d.Changed() {
+			changed++
+		}
+	}
+
+	noun := "resources depend"
+	if len(deps) == 1 {
+		noun = "resource depends"
+	}
+	label := fmt.Sprintf("%d %s on it", len(deps), noun)
+	if changed > 0 {
+		label += fmt.Sprintf(" (%d also change in this plan)", changed)
+	}
+
+	var names []string
+	for i, d := range deps {
+		if i == blastRadiusShown {
+			break
+		}
+		names = append(names, quote(d.Address))
+	}
+	label += ": " + strings.Join(names, ", ")
+	if more := len(deps) - len(names); more > 0 {
+		label += fmt.Sprintf(" and %d more", more)
+	}
+	return label
+}
@@ -0,0 +1,38 @@
+package render
+
+import (
+	"github.com/sgr0691/diffy/internal/analyze"
+	"github.com/sgr0691/diffy/internal/compare"
+	"github.com/sgr0691/diffy/internal/parse"
+)
+
+// ComparisonResult holds all data needed to render a plan comparison.
+type ComparisonResult struct {
+	// Before and After are the full change sets of the compared plans. They
+	// are used to redact sensitive values from the diff's findings.
+	Before    []parse.ResourceChange
+	After     []parse.ResourceChange
+	Diff      compare.Result
+	Threshold *analyze.Severity // nil if --fail-on-new not set
+	ExitCode  int
+}
+
+// ComparisonRenderer renders a ComparisonResult to a string.
+type ComparisonRenderer interface {
+	RenderComparison(c ComparisonResult) string
+}
+
+// sanitizeComparison scrubs sensitive values from the diff's findings, like
+// sanitize does for a single plan.
+func sanitizeComparison(c ComparisonResult) ComparisonResult {
+	secrets := make(map[string][]string)
+	for _, changes := range [][]parse.ResourceChange{c.Before, c.After} {
+		for _, ch := range changes {
+			key := resourceKey(ch.Stack, ch.Address)
+			secrets[key] = append(secrets[key], ch.SensitiveValues()...)
+		}
+	}
+	c.Diff.NewFindings = scrubFindings(c.Diff.NewFindings, secrets)
+	c.Diff.ResolvedFindings = scrubFindings(c.Diff.ResolvedFindings, secrets)
+	return c
+}
@@ -0,0 +1,152 @@
+package render
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sgr0691/diffy/internal/analyze"
+	"github.com/sgr0691/diffy/internal/parse"
+)
+
+// rootModuleLabel names the root module in rollups.
+const rootModuleLabel = "(root)"
+
+type moduleSummary struct {
+	Module   string
+	Counts   parse.Counts
+	Findings []analyze.Finding
+}
+
+// maxSeverity returns the highest finding severity in upper case, or "-"
+// when the module has no findings.
+func (m moduleSummary) maxSeverity() string {
+	if len(m.Findings) == 0 {
+		return "-"
+	}
+	return strings.ToUpper(analyze.MaxSeverity(m.Findings).String())
+}
+
+// moduleRollup groups changes and findings by module path, root first and
+// the rest sorted by path. With several stacks, modules are grouped per
+// stack and labelled "stack: module".
+func moduleRollup(changes []parse.ResourceChange, findings []analyze.Finding) []moduleSummary {
+	moduleOf := make(map[string]string, len(changes))
+	byModule := make(map[string][]parse.ResourceChange)
+	for _, ch := range changes {
+		key := resourceKey(ch.Stack, ch.ModuleAddress)
+		moduleOf[resourceKey(ch.Stack, ch.Address)] = key
+		byModule[key] = append(byModule[key], ch)
+	}
+
+	findingsByModule := make(map[string][]analyze.Finding)
+	for _, f := range findings {
+		module, ok := moduleOf[resourceKey(f.Stack, f.Address)]
+		if !ok {
+			module = resourceKey(f.Stack, "")
+			if addr, err := parse.ParseAddress(f.Address); err == nil {
+				module = resourceKey(f.Stack, addr.ModulePath())
+			}
+		}
+		findingsByModule[module] = append(findingsByModule[module], f)
+		if _, ok := byModule[module]; !ok {
+			byModule[module] = nil
+		}
+	}
+
+	modules := make([]string, 0, len(byModule))
+	for m := range byModule {
+		modules = append(modules, m)
+	}
+	sort.Strings(modules)
+
+	out := make([]moduleSummary, 0, len(modules))
+	for _, m := range modules {
+		stack, module, _ := strings.Cut(m, keySeparator)
+		label := module
+		if label == "" {
+			label = rootModuleLabel
+		}
+		out = append(out, moduleSummary{
+			Module:   withStack(stack, label),
+			Counts:   parse.ComputeCounts(byModule[m]),
+			Findings: findingsByModule[m],
+		})
+	}
+	return out
+}
+
+type stackSummary struct {
+	Stack    Stack
+	Counts   parse.Counts
+	Findings []analyze.Finding
+}
+
+// maxSeverity returns the highest finding severity in upper case, or "-"
+// when the stack has no findings.
+func (s stackSummary) maxSeverity() string {
+	return moduleSummary{Findings: s.Findings}.maxSeverity()
+}
+
+// stackRollup groups changes and findings by stack, in the order the
+// stacks were given. Stacks without changes are still listed.
+func stackRollup(stacks []Stack, changes []parse.ResourceChange, findings []analyze.Finding) []stackSummary {
+	byStack := make(map[string][]parse.ResourceChange)
+	for _, ch := range changes {
+		byStack[ch.Stack] = append(byStack[ch.Stack], ch)
+	}
+	findingsByStack := make(map[string][]analyze.Finding)
+	for _, f := range findings {
+		findingsByStack[f.Stack] = append(findingsByStack[f.Stack], f)
+	}
+
+	out := make([]stackSummary, 0, len(stacks))
+	for _, s := range stacks {
+		out = append(out, stackSummary{
+			Stack:    s,
+			Counts:   parse.ComputeCounts(byStack[s.Name]),
+			Findings: findingsByStack[s.Name],
+		})
+	}
+	return out
+}
+
+// keySeparator joins a stack name and an address in map keys. It cannot
+// occur in either.
+const keySeparator = "\x00"
+
+// resourceKey identifies an address within its stack, since the same
+// address may appear in several stacks.
+func resourceKey(stack, address string) string {
+	return stack + keySeparator + address
+}
+
+// withStack prefixes s with its stack name, e.g. "prod/vpc: aws_vpc.main".
+func withStack(stack, s string) string {
+	if stack == "" {
+		return s
+	}
+	return stack + ": " + s
+}
+
+// collapsedSummary counts collapsed findings by title, e.g. "3 findings
+// (Resource replacement detected ×2, Resource deletion detected ×1)".
+func collapsedSummary(findings []analyze.Finding) string {
+	counts := make(map[string]int)
+	var titles []string
+	for _, f := range findings {
+		if counts[f.Title] == 0 {
+			titles = append(titles, f.Title)
+		}
+		counts[f.Title]++
+	}
+	parts := make([]string, len(titles))
+	for i, t := range titles {
+		parts[i] = fmt.Sprintf("%s ×%d", t, counts[t])
+	}
+	noun := "findings"
+	if len(findings) == 1 {
+		noun = "finding"
+	}
+	return fmt.Sprintf("%d %s (%s)", len(findings), noun, strings.Join(parts, ", "))
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 
 	"github.com/sgr0691/diffy/internal/analyze"
+	"github.com/sgr0691/diffy/internal/graph"
 	"github.com/sgr0691/diffy/internal/parse"
 )
 
@@ -11,70 +12,184 @@ import (
 type JSONRenderer struct{}
 
 type jsonOutput struct {
-	Counts    parse.Counts  `json:"counts"`
-	Changes   []jsonChange  `json:"changes"`
-	Findings  []jsonFinding `json:"findings"`
-	Threshold *string       `json:"threshold,omitempty"`
-	Decision  string        `json:"decision"`
-	ExitCode  int           `json:"exit_code"`
+	Metadata     *parse.Metadata    `json:"metadata,omitempty"`
+	Counts       parse.Counts       `json:"counts"`
+	Stacks       []jsonStack        `json:"stacks,omitempty"`
+	Changes      []jsonChange       `json:"changes"`
+	Drift        []jsonChange       `json:"drift,omitempty"`
+	OutputCounts parse.OutputCounts `json:"output_counts"`
+	Outputs      []jsonOutputChange `json:"output_changes,omitempty"`
+	Findings     []jsonFinding      `json:"findings"`
+	Acknowledged []jsonFinding      `json:"acknowledged,omitempty"`
+	Threshold    *string            `json:"threshold,omitempty"`
+	Decision     string             `json:"decision"`
+	ExitCode     int                `json:"exit_code"`
+}
+
+// jsonStack is the per-stack breakdown when several plans are explained
+// together. Decision is set when a threshold is.
+type jsonStack struct {
+	Name        string          `json:"name"`
+	Metadata    *parse.Metadata `json:"metadata,omitempty"`
+	Counts      parse.Counts    `json:"counts"`
+	Findings    int             `json:"findings"`
+	MaxSeverity string          `json:"max_severity,omitempty"`
+	Decision    string          `json:"decision,omitempty"`
 }
 
 type jsonChange struct {
-	Address      string   `json:"address"`
-	Type         string   `json:"type"`
-	ProviderName string   `json:"provider_name,omitempty"`
-	Action       string   `json:"action"`
-	ChangePaths  []string `json:"change_paths,omitempty"`
+	Stack           string           `json:"stack,omitempty"`
+	Address         string           `json:"address"`
+	PreviousAddress string           `json:"previous_address,omitempty"`
+	Type            string           `json:"type"`
+	ProviderName    string           `json:"provider_name,omitempty"`
+	Action          string           `json:"action"`
+	ImportID        string           `json:"import_id,omitempty"`
+	DeferredReason  string           `json:"deferred_reason,omitempty"`
+	ChangePaths     []string         `json:"change_paths,omitempty"`
+	UnknownPaths    []string         `json:"unknown_paths,omitempty"`
+	SensitivePaths  []string         `json:"sensitive_paths,omitempty"`
+	ReplacePaths    []string         `json:"replace_paths,omitempty"`
+	ActionReason    string           `json:"action_reason,omitempty"`
+	ReplaceOrder    string           `json:"replace_order,omitempty"`
+	Lifecycle       *parse.Lifecycle `json:"lifecycle,omitempty"`
+}
+
+// jsonOutputChange describes an output change without its values, which
+// may be sensitive.
+type jsonOutputChange struct {
+	Stack           string `json:"stack,omitempty"`
+	Name            string `json:"name"`
+	Address         string `json:"address"`
+	Action          string `json:"action"`
+	BeforeType      string `json:"before_type,omitempty"`
+	AfterType       string `json:"after_type,omitempty"`
+	AfterUnknown    bool   `json:"after_unknown,omitempty"`
+	BeforeSensitive bool   `json:"before_sensitive"`
+	AfterSensitive  bool   `json:"after_sensitive"`
 }
 
 type jsonFinding struct {
-	Severity     string   `json:"severity"`
-	Title        string   `json:"title"`
-	Description  string   `json:"description"`
-	Address      string   `json:"resource_address"`
-	Action       string   `json:"action"`
-	ResourceType string   `json:"resource_type"`
-	ChangePaths  []string `json:"change_paths,omitempty"`
-	Matches      []string `json:"matches,omitempty"`
+	RuleID          string           `json:"rule_id,omitempty"`
+	Fingerprint     string           `json:"fingerprint,omitempty"`
+	Stack           string           `json:"stack,omitempty"`
+	Severity        string           `json:"severity"`
+	Confidence      string           `json:"confidence,omitempty"`
+	Title           string           `json:"title"`
+	Description     string           `json:"description"`
+	Address         string           `json:"resource_address"`
+	PreviousAddress string           `json:"previous_address,omitempty"`
+	Action          string           `json:"action"`
+	ResourceType    string           `json:"resource_type"`
+	ChangePaths     []string         `json:"change_paths,omitempty"`
+	Matches         []string         `json:"matches,omitempty"`
+	ForcedBy        []string         `json:"forced_by,omitempty"`
+	ActionReason    string           `json:"action_reason,omitempty"`
+	ReplaceOrder    string           `json:"replace_order,omitempty"`
+	Drift           bool             `json:"drift,omitempty"`
+	BlastRadius     *jsonBlastRadius `json:"blast_radius,omitempty"`
+	IndexShifts     []string         `json:"index_shifts,omitempty"`
+	Collapsed       []jsonFinding    `json:"collapsed,omitempty"`
+	Suppression     *jsonSuppression `json:"suppression,omitempty"`
+	InBaseline      bool             `json:"in_baseline,omitempty"`
+}
+
+// jsonSuppression is the suppression that acknowledges a finding.
+type jsonSuppression struct {
+	Justification string `json:"justification"`
+	Owner         string `json:"owner"`
+	Expires       string `json:"expires"`
+	Source        string `json:"source,omitempty"`
+}
+
+// jsonBlastRadius lists the resources that depend on a replaced or deleted
+// resource. Changed counts those the plan also changes.
+type jsonBlastRadius struct {
+	Count      int               `json:"count"`
+	Changed    int               `json:"changed"`
+	Dependents []graph.Dependent `json:"dependents"`
 }
 
 func (j JSONRenderer) Render(r Result) string {
+	r = sanitize(r)
+
 	changes := make([]jsonChange, len(r.Changes))
 	for i, ch := range r.Changes {
-		changes[i] = jsonChange{
-			Address:      ch.Address,
-			Type:         ch.Type,
-			ProviderName: ch.ProviderName,
-			Action:       string(ch.Action),
-			ChangePaths:  ch.ChangePaths,
-		}
+		changes[i] = toJSONChange(ch)
 	}
 
-	findings := make([]jsonFinding, len(r.Findings))
-	for i, f := range r.Findings {
-		findings[i] = jsonFinding{
-			Severity:     f.Severity.String(),
-			Title:        f.Title,
-			Description:  f.Description,
-			Address:      f.Address,
-			Action:       string(f.Evidence.Action),
-			ResourceType: f.Evidence.ResourceType,
-			ChangePaths:  f.Evidence.ChangePaths,
-			Matches:      f.Evidence.Matches,
-		}
+	var drift []jsonChange
+	for _, d := range r.Drift {
+		drift = append(drift, jsonChange{
+			Stack:        d.Stack,
+			Address:      d.Address,
+			Type:         d.Type,
+			ProviderName: d.ProviderName,
+			Action:       string(d.Action),
+			ChangePaths:  d.ChangePaths,
+		})
+	}
+
+	var outputs []jsonOutputChange
+	for _, o := range r.Outputs {
+		outputs = append(outputs, jsonOutputChange{
+			Stack:           o.Stack,
+			Name:            o.Name,
+			Address:         o.Address(),
+			Action:          string(o.Action),
+			BeforeType:      o.BeforeType(),
+			AfterType:       o.AfterType(),
+			AfterUnknown:    o.AfterUnknown,
+			BeforeSensitive: o.BeforeSensitive,
+			AfterSensitive:  o.AfterSensitive,
+		})
 	}
 
+	findings := toJSONFindings(r.Findings)
+
 	decision := "pass"
 	if r.ExitCode == 2 {
 		decision = "fail"
 	}
 
 	out := jsonOutput{
-		Counts:   r.Counts,
-		Changes:  changes,
-		Findings: findings,
-		Decision: decision,
-		ExitCode: r.ExitCode,
+		Counts:       r.Counts,
+		Changes:      changes,
+		Drift:        drift,
+		OutputCounts: r.OutputCounts,
+		Outputs:      outputs,
+		Findings:     findings,
+		Acknowledged: toJSONFindings(r.Acknowledged),
+		Decision:     decision,
+		ExitCode:     r.ExitCode,
+	}
+
+	if r.Metadata != (parse.Metadata{}) {
+		out.Metadata = &r.Metadata
+	}
+
+	if len(r.Stacks) > 1 {
+		for _, s := range stackRollup(r.Stacks, r.Changes, r.Findings) {
+			js := jsonStack{
+				Name:     s.Stack.Name,
+				Counts:   s.Counts,
+				Findings: len(s.Findings),
+			}
+			if s.Stack.Metadata != (parse.Metadata{}) {
+				md := s.Stack.Metadata
+				js.Metadata = &md
+			}
+			if len(s.Findings) > 0 {
+				js.MaxSeverity = analyze.MaxSeverity(s.Findings).String()
+			}
+			if r.Threshold != nil {
+				js.Decision = "pass"
+				if analyze.ExceedsThreshold(s.Findings, *r.Threshold) {
+					js.Decision = "fail"
+				}
+			}
+			out.Stacks = append(out.Stacks, js)
+		}
 	}
 
 	if r.Threshold != nil {
@@ -89,14 +204,198 @@ func (j JSONRenderer) Render(r Result) string {
 	return string(data) + "\n"
 }
 
-// SortFindings sorts findings by severity (highest first), then by address.
+func toJSONChange(ch parse.ResourceChange) jsonChange {
+	return jsonChange{
+		Stack:           ch.Stack,
+		Address:         ch.Address,
+		PreviousAddress: ch.PreviousAddress,
+		Type:            ch.Type,
+		ProviderName:    ch.ProviderName,
+		Action:          string(ch.Action),
+		ImportID:        ch.ImportID,
+		DeferredReason:  ch.DeferredReason,
+		ChangePaths:     ch.ChangePaths,
+		UnknownPaths:    ch.UnknownPaths,
+		SensitivePaths:  ch.SensitivePaths,
+		ReplacePaths:    ch.ReplacePaths,
+		ActionReason:    ch.ActionReason,
+		ReplaceOrder:    ch.ReplaceOrder,
+		Lifecycle:       ch.Lifecycle,
+	}
+}
+
+func toJSONFindings(fs []analyze.Finding) []jsonFinding {
+	findings := make([]jsonFinding, len(fs))
+	for i, f := range fs {
+		findings[i] = jsonFinding{
+			RuleID:          f.RuleID,
+			Fingerprint:     f.Fingerprint,
+			Stack:           f.Stack,
+			Severity:        f.Severity.String(),
+			Confidence:      string(f.Confidence),
+			Title:           f.Title,
+			Description:     f.Description,
+			Address:         f.Address,
+			PreviousAddress: f.Evidence.PreviousAddress,
+			Action:          string(f.Evidence.Action),
+			ResourceType:    f.Evidence.ResourceType,
+			ChangePaths:     f.Evidence.ChangePaths,
+			Matches:         f.Evidence.Matches,
+			ForcedBy:        f.Evidence.ForcedBy,
+			ActionReason:    f.Evidence.ActionReason,
+			ReplaceOrder:    f.Evidence.ReplaceOrder,
+			Drift:           f.Evidence.Drift,
+			IndexShifts:     f.Evidence.IndexShifts,
+			InBaseline:      f.InBaseline,
+		}
+		if deps := f.Evidence.BlastRadius; len(deps) > 0 {
+			br := &jsonBlastRadius{Count: len(deps), Dependents: deps}
+			for _, d := range deps {
+				if d.Changed() {
+					br.Changed++
+				}
+			}
+			findings[i].BlastRadius = br
+		}
+		if len(f.Collapsed) > 0 {
+			findings[i].Collapsed = toJSONFindings(f.Collapsed)
+		}
+		if s := f.Suppression; s != nil {
+			findings[i].Suppression = &jsonSuppression{
+				Justification: s.Justification,
+				Owner:         s.Owner,
+				Expires:       s.Expires.Format("2006-01-02"),
+				Source:        s.Source,
+			}
+		}
+	}
+	return findings
+}
+
+type jsonComparison struct {
+	Added            []jsonChange       `json:"added"`
+	Dropped          []jsonChange       `json:"dropped"`
+	Changed          []jsonActionChange `json:"changed"`
+	Unchanged        int                `json:"unchanged"`
+	NewFindings      []jsonFinding      `json:"new_findings"`
+	ResolvedFindings []jsonFinding      `json:"resolved_findings"`
+	Threshold        *string            `json:"threshold,omitempty"`
+	Decision         string             `json:"decision"`
+	ExitCode         int                `json:"exit_code"`
+}
+
+type jsonActionChange struct {
+	Stack          string `json:"stack,omitempty"`
+	Address        string `json:"address"`
+	Type           string `json:"type"`
+	Before         string `json:"before"`
+	After          string `json:"after"`
+	BeforeOrder    string `json:"before_replace_order,omitempty"`
+	AfterOrder     string `json:"after_replace_order,omitempty"`
+	BeforeDeferred bool   `json:"before_deferred,omitempty"`
+	AfterDeferred  bool   `json:"after_deferred,omitempty"`
+}
+
+func (j JSONRenderer) RenderComparison(c ComparisonResult) string {
+	c = sanitizeComparison(c)
+	d := c.Diff
+
+	out := jsonComparison{
+		Added:            make([]jsonChange, 0, len(d.Added)),
+		Dropped:          make([]jsonChange, 0, len(d.Dropped)),
+		Changed:          make([]jsonActionChange, 0, len(d.Changed)),
+		Unchanged:        d.Unchanged,
+		NewFindings:      toJSONFindings(d.NewFindings),
+		ResolvedFindings: toJSONFindings(d.ResolvedFindings),
+		Decision:         "pass",
+		ExitCode:         c.ExitCode,
+	}
+	for _, ch := range d.Added {
+		out.Added = append(out.Added, toJSONChange(ch))
+	}
+	for _, ch := range d.Dropped {
+		out.Dropped = append(out.Dropped, toJSONChange(ch))
+	}
+	for _, ac := range d.Changed {
+		out.Changed = append(out.Changed, jsonActionChange{
+			Stack:          ac.New.Stack,
+			Address:        ac.New.Address,
+			Type:           ac.New.Type,
+			Before:         ac.Old.Classification(),
+			After:          ac.New.Classification(),
+			BeforeOrder:    ac.Old.ReplaceOrder,
+			AfterOrder:     ac.New.ReplaceOrder,
+			BeforeDeferred: ac.Old.Deferred(),
+			AfterDeferred:  ac.New.Deferred(),
+		})
+	}
+	if c.ExitCode == 2 {
+		out.Decision = "fail"
+	}
+	if c.Threshold != nil {
+		s := c.Threshold.String()
+		out.Threshold = &s
+	}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return `{"error": "failed to marshal output"}`
+	}
+	return string(data) + "\n"
+}
+
+type jsonErrorOutput struct {
+	Error    jsonError `json:"error"`
+	Decision string    `json:"decision"`
+	ExitCode int       `json:"exit_code"`
+}
+
+type jsonError struct {
+	Kind    string `json:"kind"`
+	Source  string `json:"source,omitempty"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Offset  int64  `json:"offset,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Excerpt string `json:"excerpt,omitempty"`
+}
+
+func (j JSONRenderer) RenderError(err error) string {
+	rep := newErrorReport(err)
+	out := jsonErrorOutput{
+		Error: jsonError{
+			Kind:    rep.Kind,
+			Source:  rep.Source,
+			Message: rep.Message,
+			Hint:    rep.Hint,
+			Path:    rep.Path,
+			Offset:  rep.Offset,
+			Line:    rep.Line,
+			Column:  rep.Column,
+			Excerpt: rep.Excerpt,
+		},
+		Decision: "error",
+		ExitCode: 1,
+	}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return `{"error": {"message": "failed to marshal output"}, "decision": "error", "exit_code": 1}`
+	}
+	return string(data) + "\n"
+}
+
+// SortFindings sorts findings by severity (highest first), then by stack and
+// address.
 func SortFindings(findings []analyze.Finding) []analyze.Finding {
 	sorted := make([]analyze.Finding, len(findings))
 	copy(sorted, findings)
 	for i := 0; i < len(sorted); i++ {
 		for j := i + 1; j < len(sorted); j++ {
 			if sorted[j].Severity > sorted[i].Severity ||
-				(sorted[j].Severity == sorted[i].Severity && sorted[j].Address < sorted[i].Address) {
+				(sorted[j].Severity == sorted[i].Severity && resourceKey(sorted[j].Stack, sorted[j].Address) < resourceKey(sorted[i].Stack, sorted[i].Address)) {
 				sorted[i], sorted[j] = sorted[j], sorted[i]
 			}
 		}
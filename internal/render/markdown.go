@@ -13,11 +13,17 @@ import (
 type MarkdownRenderer struct{}
 
 func (m MarkdownRenderer) Render(r Result) string {
+	r = sanitize(r)
+
 	var sb strings.Builder
 
 	// Header summary
 	sb.WriteString("# Diffy Summary\n\n")
-	sb.WriteString(fmt.Sprintf("**%d** total changes: ", r.Counts.Total))
+	if len(r.Stacks) > 1 {
+		sb.WriteString(fmt.Sprintf("**%d** total changes across **%d** stacks: ", r.Counts.Total, len(r.Stacks)))
+	} else {
+		sb.WriteString(fmt.Sprintf("**%d** total changes: ", r.Counts.Total))
+	}
 	parts := []string{}
 	if r.Counts.Create > 0 {
 		parts = append(parts, fmt.Sprintf("%d to create", r.Counts.Create))
@@ -31,11 +37,56 @@ func (m MarkdownRenderer) Render(r Result) string {
 	if r.Counts.Replace > 0 {
 		parts = append(parts, fmt.Sprintf("%d to replace", r.Counts.Replace))
 	}
+	if r.Counts.Move > 0 {
+		parts = append(parts, fmt.Sprintf("%d to move", r.Counts.Move))
+	}
+	if r.Counts.Import > 0 {
+		parts = append(parts, fmt.Sprintf("%d to import", r.Counts.Import))
+	}
+	if r.Counts.Forget > 0 {
+		parts = append(parts, fmt.Sprintf("%d to forget", r.Counts.Forget))
+	}
+	if r.Counts.Read > 0 {
+		parts = append(parts, fmt.Sprintf("%d to read", r.Counts.Read))
+	}
+	if r.Counts.Deferred > 0 {
+		parts = append(parts, fmt.Sprintf("%d deferred", r.Counts.Deferred))
+	}
+	if r.Counts.Unknown > 0 {
+		parts = append(parts, fmt.Sprintf("%d with unrecognized actions", r.Counts.Unknown))
+	}
 	if len(parts) == 0 {
 		parts = append(parts, "no changes")
 	}
 	sb.WriteString(strings.Join(parts, ", "))
 	sb.WriteString("\n\n")
+	if r.OutputCounts.Total > 0 {
+		sb.WriteString(fmt.Sprintf("**%d** output changes: %s\n\n", r.OutputCounts.Total, outputCountsSummary(r.OutputCounts)))
+	}
+
+	// Per-stack rollup
+	if len(r.Stacks) > 1 {
+		sb.WriteString("## Stacks\n\n")
+		sb.WriteString("| Stack | Create | Update | Delete | Replace | Move | Total | Findings | Max severity |\n")
+		sb.WriteString("|-------|--------|--------|--------|---------|------|-------|----------|--------------|\n")
+		for _, s := range stackRollup(r.Stacks, r.Changes, r.Findings) {
+			c := s.Counts
+			sb.WriteString(fmt.Sprintf("| %s | %d | %d | %d | %d | %d | %d | %d | %s |\n", s.Stack.Name, c.Create, c.Update, c.Delete, c.Replace, c.Move, c.Total, len(s.Findings), s.maxSeverity()))
+		}
+		sb.WriteString("\n")
+	}
+
+	// Per-module rollup
+	if r.GroupBy == GroupByModule && (len(r.Changes) > 0 || len(r.Findings) > 0) {
+		sb.WriteString("## Modules\n\n")
+		sb.WriteString("| Module | Create | Update | Delete | Replace | Move | Total | Max severity |\n")
+		sb.WriteString("|--------|--------|--------|--------|---------|------|-------|--------------|\n")
+		for _, m := range moduleRollup(r.Changes, r.Findings) {
+			c := m.Counts
+			sb.WriteString(fmt.Sprintf("| %s | %d | %d | %d | %d | %d | %d | %s |\n", m.Module, c.Create, c.Update, c.Delete, c.Replace, c.Move, c.Total, m.maxSeverity()))
+		}
+		sb.WriteString("\n")
+	}
 
 	// Top changes table
 	if len(r.Changes) > 0 {
@@ -44,8 +95,38 @@ func (m MarkdownRenderer) Render(r Result) string {
 		sb.WriteString("| Action | Resource | Severity | Notes |\n")
 		sb.WriteString("|--------|----------|----------|-------|\n")
 		for _, ch := range r.Changes {
-			summary := summaries[ch.Address]
-			sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n", ch.Action, ch.Address, summary.Severity, summary.Notes))
+			summary := summaries[resourceKey(ch.Stack, ch.Address)]
+			sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n", changeLabel(ch), displayAddress(ch), summary.Severity, summary.Notes))
+		}
+		sb.WriteString("\n")
+	}
+
+	// Output changes other stacks may depend on
+	if len(r.Outputs) > 0 {
+		summaries := summarizeOutputFindings(r.Outputs, r.Findings)
+		sb.WriteString("## Output changes\n\n")
+		sb.WriteString("| Action | Output | Type | Sensitive | Severity | Notes |\n")
+		sb.WriteString("|--------|--------|------|-----------|----------|-------|\n")
+		for _, o := range r.Outputs {
+			summary := summaries[resourceKey(o.Stack, o.Address())]
+			sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s | %s |\n", o.Action, outputDisplayAddress(o), outputTypeLabel(o), outputSensitiveLabel(o), summary.Severity, summary.Notes))
+		}
+		sb.WriteString("\n")
+	}
+
+	// Out-of-band changes the plan may revert
+	if len(r.Drift) > 0 {
+		planned := plannedActions(r.Changes)
+		sb.WriteString("## Drift detected\n\n")
+		sb.WriteString("These resources changed outside of Terraform. Planned changes to them may revert manual edits.\n\n")
+		sb.WriteString("| Drift | Resource | Planned | Changed paths |\n")
+		sb.WriteString("|-------|----------|---------|---------------|\n")
+		for _, d := range r.Drift {
+			action := "-"
+			if a, ok := planned[resourceKey(d.Stack, d.Address)]; ok {
+				action = string(a)
+			}
+			sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n", d.Action, withStack(d.Stack, d.Address), action, formatPaths(d.ChangePaths)))
 		}
 		sb.WriteString("\n")
 	}
@@ -70,15 +151,44 @@ func (m MarkdownRenderer) Render(r Result) string {
 			}
 			sb.WriteString(fmt.Sprintf("### %s\n\n", strings.ToUpper(sev.String())))
 			for _, f := range findings {
-				sb.WriteString(fmt.Sprintf("- **%s** — `%s`\n", f.Title, f.Address))
+				sb.WriteString(fmt.Sprintf("- **%s** — `%s`\n", f.Title, findingAddress(f)))
 				sb.WriteString(fmt.Sprintf("  %s\n", f.Description))
-				sb.WriteString(fmt.Sprintf("  _(action: %s, type: %s)_\n\n", f.Evidence.Action, f.Evidence.ResourceType))
+				if len(f.Evidence.ForcedBy) > 0 {
+					sb.WriteString(fmt.Sprintf("  forced by: %s\n", formatPaths(f.Evidence.ForcedBy)))
+				}
+				if order := replaceOrderLabel(f.Evidence.ReplaceOrder); order != "" {
+					sb.WriteString(fmt.Sprintf("  ordering: %s\n", order))
+				}
+				if len(f.Evidence.BlastRadius) > 0 {
+					sb.WriteString(fmt.Sprintf("  blast radius: %s\n", blastRadiusLabel(f.Evidence.BlastRadius, func(s string) string { return "`" + s + "`" })))
+				}
+				if len(f.Evidence.IndexShifts) > 0 {
+					sb.WriteString(fmt.Sprintf("  shifted: %s\n", strings.Join(f.Evidence.IndexShifts, ", ")))
+				}
+				if len(f.Collapsed) > 0 {
+					sb.WriteString(fmt.Sprintf("  collapses: %s\n", collapsedSummary(f.Collapsed)))
+				}
+				sb.WriteString(fmt.Sprintf("  _(%s%s: %s, type: %s%s%s)_\n\n", ruleNote(f), actionLabel(f), f.Evidence.Action, f.Evidence.ResourceType, confidenceNote(f), baselineNote(f)))
 			}
 		}
 	} else {
 		sb.WriteString("No findings.\n")
 	}
 
+	// Findings accepted through suppressions
+	if len(r.Acknowledged) > 0 {
+		if len(r.Findings) == 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("## Acknowledged\n\n")
+		sb.WriteString("These findings match a suppression and do not count toward the threshold.\n\n")
+		for _, f := range sortedAcknowledged(r.Acknowledged) {
+			sb.WriteString(fmt.Sprintf("- **%s** — `%s`\n", acknowledgedTitle(f), findingAddress(f)))
+			sb.WriteString(fmt.Sprintf("  %s\n", f.Suppression.Justification))
+			sb.WriteString(fmt.Sprintf("  _(%s%s)_\n\n", ruleNote(f), suppressionLabel(f.Suppression)))
+		}
+	}
+
 	// Threshold decision
 	if r.Threshold != nil {
 		sb.WriteString("---\n\n")
@@ -94,11 +204,11 @@ func (m MarkdownRenderer) Render(r Result) string {
 
 func groupBySeverity(findings []analyze.Finding) map[analyze.Severity][]analyze.Finding {
 	m := make(map[analyze.Severity][]analyze.Finding)
-	// Sort findings within each severity by address for stability
+	// Sort findings within each severity by stack and address for stability
 	sorted := make([]analyze.Finding, len(findings))
 	copy(sorted, findings)
 	sort.Slice(sorted, func(i, j int) bool {
-		return sorted[i].Address < sorted[j].Address
+		return resourceKey(sorted[i].Stack, sorted[i].Address) < resourceKey(sorted[j].Stack, sorted[j].Address)
 	})
 	for _, f := range sorted {
 		m[f.Severity] = append(m[f.Severity], f)
@@ -114,7 +224,7 @@ type changeSummary struct {
 func summarizeChangeFindings(changes []parse.ResourceChange, findings []analyze.Finding) map[string]changeSummary {
 	out := make(map[string]changeSummary, len(changes))
 	for _, ch := range changes {
-		out[ch.Address] = changeSummary{
+		out[resourceKey(ch.Stack, ch.Address)] = changeSummary{
 			Severity: "-",
 			Notes:    ch.Type,
 		}
@@ -122,11 +232,21 @@ func summarizeChangeFindings(changes []parse.ResourceChange, findings []analyze.
 
 	byAddress := make(map[string][]analyze.Finding)
 	for _, f := range findings {
-		byAddress[f.Address] = append(byAddress[f.Address], f)
+		if f.Evidence.Drift {
+			continue
+		}
+		key := resourceKey(f.Stack, f.Address)
+		byAddress[key] = append(byAddress[key], f)
+		// Resources whose findings were collapsed show the summary finding.
+		for _, c := range f.Collapsed {
+			key := resourceKey(c.Stack, c.Address)
+			byAddress[key] = append(byAddress[key], f)
+		}
 	}
 
 	for _, ch := range changes {
-		fs := byAddress[ch.Address]
+		key := resourceKey(ch.Stack, ch.Address)
+		fs := byAddress[key]
 		if len(fs) == 0 {
 			continue
 		}
@@ -136,7 +256,7 @@ func summarizeChangeFindings(changes []parse.ResourceChange, findings []analyze.
 			}
 			return fs[i].Title < fs[j].Title
 		})
-		out[ch.Address] = changeSummary{
+		out[key] = changeSummary{
 			Severity: strings.ToUpper(fs[0].Severity.String()),
 			Notes:    fs[0].Title,
 		}
@@ -144,3 +264,181 @@ func summarizeChangeFindings(changes []parse.ResourceChange, findings []analyze.
 
 	return out
 }
+
+// replaceOrderLabel describes a replacement ordering in words.
+func replaceOrderLabel(order string) string {
+	switch order {
+	case parse.ReplaceCreateBeforeDestroy:
+		return "create before destroy"
+	case parse.ReplaceDestroyBeforeCreate:
+		return "destroy before create"
+	}
+	return ""
+}
+
+// changeLabel is the classification of a change, marking deferred ones.
+func changeLabel(ch parse.ResourceChange) string {
+	if ch.Deferred() {
+		return ch.Classification() + " (deferred)"
+	}
+	return ch.Classification()
+}
+
+// displayAddress renders a moved resource as "old → new", prefixed with its
+// stack when several plans are explained together.
+func displayAddress(ch parse.ResourceChange) string {
+	if ch.Moved() {
+		return withStack(ch.Stack, ch.PreviousAddress+" → "+ch.Address)
+	}
+	return withStack(ch.Stack, ch.Address)
+}
+
+func findingAddress(f analyze.Finding) string {
+	if prev := f.Evidence.PreviousAddress; prev != "" && prev != f.Address {
+		return withStack(f.Stack, prev+" → "+f.Address)
+	}
+	return withStack(f.Stack, f.Address)
+}
+
+// confidenceNote flags findings that could not be verified at plan time.
+func confidenceNote(f analyze.Finding) string {
+	if f.Confidence == analyze.ConfidenceUnverified {
+		return ", confidence: unverified"
+	}
+	return ""
+}
+
+func plannedActions(changes []parse.ResourceChange) map[string]parse.Action {
+	out := make(map[string]parse.Action, len(changes))
+	for _, ch := range changes {
+		out[resourceKey(ch.Stack, ch.Address)] = ch.Action
+	}
+	return out
+}
+
+func formatPaths(paths []string) string {
+	if len(paths) == 0 {
+		return "-"
+	}
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = "`" + p + "`"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// ruleNote names the rule that reported a finding, e.g. "rule: DIFFY-AWS-001, ".
+func ruleNote(f analyze.Finding) string {
+	if f.RuleID == "" {
+		return ""
+	}
+	return "rule: " + f.RuleID + ", "
+}
+
+// actionLabel names the evidence action: drift findings describe what
+// happened outside of Terraform rather than what the plan will do.
+func actionLabel(f analyze.Finding) string {
+	if f.Evidence.Drift {
+		return "drift"
+	}
+	return "action"
+}
+
+func (m MarkdownRenderer) RenderError(err error) string {
+	rep := newErrorReport(err)
+
+	var sb strings.Builder
+	sb.WriteString("# Diffy Error\n\n")
+	sb.WriteString(fmt.Sprintf("**%s**\n\n", rep.Message))
+	if loc := rep.location(); loc != "" {
+		sb.WriteString(fmt.Sprintf("Location: `%s`\n\n", loc))
+	}
+	if rep.Excerpt != "" {
+		sb.WriteString("```\n" + rep.excerptLines("") + "```\n\n")
+	}
+	if rep.Hint != "" {
+		sb.WriteString(rep.Hint + "\n")
+	}
+	return sb.String()
+}
+
+func (m MarkdownRenderer) RenderComparison(c ComparisonResult) string {
+	c = sanitizeComparison(c)
+	d := c.Diff
+
+	var sb strings.Builder
+	sb.WriteString("# Diffy Plan Comparison\n\n")
+	if d.Empty() {
+		sb.WriteString("No differences between the plans.\n\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("**%d** added, **%d** dropped, **%d** with a changed action, %d unchanged. **%d** new findings, **%d** resolved.\n\n",
+			len(d.Added), len(d.Dropped), len(d.Changed), d.Unchanged, len(d.NewFindings), len(d.ResolvedFindings)))
+	}
+
+	if len(d.Added) > 0 {
+		sb.WriteString("## Added to the plan\n\n")
+		sb.WriteString("| Action | Resource | Type |\n")
+		sb.WriteString("|--------|----------|------|\n")
+		for _, ch := range d.Added {
+			sb.WriteString(fmt.Sprintf("| %s | %s | %s |\n", changeLabel(ch), displayAddress(ch), ch.Type))
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(d.Dropped) > 0 {
+		sb.WriteString("## Dropped from the plan\n\n")
+		sb.WriteString("| Action | Resource | Type |\n")
+		sb.WriteString("|--------|----------|------|\n")
+		for _, ch := range d.Dropped {
+			sb.WriteString(fmt.Sprintf("| %s | %s | %s |\n", changeLabel(ch), displayAddress(ch), ch.Type))
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(d.Changed) > 0 {
+		sb.WriteString("## Action changed\n\n")
+		sb.WriteString("| Resource | Before | After |\n")
+		sb.WriteString("|----------|--------|-------|\n")
+		for _, ac := range d.Changed {
+			sb.WriteString(fmt.Sprintf("| %s | %s | %s |\n", displayAddress(ac.New), comparisonAction(ac.Old), comparisonAction(ac.New)))
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(d.NewFindings) > 0 {
+		sb.WriteString("## New findings\n\n")
+		for _, f := range d.NewFindings {
+			sb.WriteString(fmt.Sprintf("- **%s** [%s] — `%s`\n", f.Title, strings.ToUpper(f.Severity.String()), findingAddress(f)))
+			sb.WriteString(fmt.Sprintf("  %s\n", f.Description))
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(d.ResolvedFindings) > 0 {
+		sb.WriteString("## Resolved findings\n\n")
+		for _, f := range d.ResolvedFindings {
+			sb.WriteString(fmt.Sprintf("- ~~%s~~ [%s] — `%s`\n", f.Title, strings.ToUpper(f.Severity.String()), findingAddress(f)))
+		}
+		sb.WriteString("\n")
+	}
+
+	if c.Threshold != nil {
+		sb.WriteString("---\n\n")
+		if c.ExitCode == 2 {
+			sb.WriteString(fmt.Sprintf("**FAIL**: new findings at or above `%s` threshold.\n", c.Threshold.String()))
+		} else {
+			sb.WriteString(fmt.Sprintf("**PASS**: no new findings at or above `%s` threshold.\n", c.Threshold.String()))
+		}
+	}
+
+	return sb.String()
+}
+
+// comparisonAction is a change's action with its replacement ordering, so a
+// switch between create-before-destroy and destroy-before-create is visible.
+func comparisonAction(ch parse.ResourceChange) string {
+	if order := replaceOrderLabel(ch.ReplaceOrder); order != "" {
+		return changeLabel(ch) + " (" + order + ")"
+	}
+	return changeLabel(ch)
+}
@@ -0,0 +1,97 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sgr0691/diffy/internal/analyze"
+	"github.com/sgr0691/diffy/internal/parse"
+)
+
+// outputTypeLabel describes an output's value type, e.g. "string" or
+// "string → list" when it changes.
+func outputTypeLabel(o parse.OutputChange) string {
+	const unknown = "(known after apply)"
+	before, after := o.BeforeType(), o.AfterType()
+	if o.AfterUnknown {
+		after = unknown
+	}
+	switch {
+	case before == "" && after == "":
+		return "-"
+	case before == "":
+		return after
+	case after == "":
+		return before
+	case before == after:
+		return after
+	}
+	return before + " → " + after
+}
+
+// outputSensitiveLabel describes an output's sensitivity, e.g. "yes" or
+// "no → yes" when it changes.
+func outputSensitiveLabel(o parse.OutputChange) string {
+	label := func(sensitive bool) string {
+		if sensitive {
+			return "yes"
+		}
+		return "no"
+	}
+	switch o.Action {
+	case parse.ActionCreate:
+		return label(o.AfterSensitive)
+	case parse.ActionDelete:
+		return label(o.BeforeSensitive)
+	}
+	if o.SensitivityChanged() {
+		return label(o.BeforeSensitive) + " → " + label(o.AfterSensitive)
+	}
+	return label(o.AfterSensitive)
+}
+
+// outputDisplayAddress is an output's address, prefixed with its stack
+// when several plans are explained together.
+func outputDisplayAddress(o parse.OutputChange) string {
+	return withStack(o.Stack, o.Address())
+}
+
+// summarizeOutputFindings returns the highest-severity finding per output,
+// keyed by resourceKey of the output's stack and address.
+func summarizeOutputFindings(outputs []parse.OutputChange, findings []analyze.Finding) map[string]changeSummary {
+	worst := make(map[string]analyze.Finding)
+	for _, f := range findings {
+		key := resourceKey(f.Stack, f.Address)
+		prev, ok := worst[key]
+		if !ok || f.Severity > prev.Severity || (f.Severity == prev.Severity && f.Title < prev.Title) {
+			worst[key] = f
+		}
+	}
+
+	out := make(map[string]changeSummary, len(outputs))
+	for _, o := range outputs {
+		key := resourceKey(o.Stack, o.Address())
+		summary := changeSummary{Severity: "-", Notes: "-"}
+		if f, ok := worst[key]; ok {
+			summary = changeSummary{Severity: strings.ToUpper(f.Severity.String()), Notes: f.Title}
+		}
+		out[key] = summary
+	}
+	return out
+}
+
+// outputCountsSummary lists the non-zero output action counts, e.g.
+// "1 to create, 1 to delete".
+func outputCountsSummary(c parse.OutputCounts) string {
+	var parts []string
+	if c.Create > 0 {
+		parts = append(parts, fmt.Sprintf("%d to create", c.Create))
+	}
+	if c.Update > 0 {
+		parts = append(parts, fmt.Sprintf("%d to update", c.Update))
+	}
+	if c.Delete > 0 {
+		parts = append(parts, fmt.Sprintf("%d to delete", c.Delete))
+	}
+	return strings.Join(parts, ", ")
+}
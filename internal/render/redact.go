@@ -0,0 +1,136 @@
+package render
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/sgr0691/diffy/internal/analyze"
+	"github.com/sgr0691/diffy/internal/parse"
+)
+
+// minAnywhereLen is the shortest sensitive value scrubbed wherever it
+// appears. Shorter values are only scrubbed as whole tokens so that, for
+// example, a sensitive "1" does not mangle unrelated text.
+const minAnywhereLen = 4
+
+// jsonLiterals are never scrubbed. A sensitive boolean or null gives
+// nothing away in finding text, while replacing the words would mangle
+// unrelated evidence such as "create_before_destroy: true".
+var jsonLiterals = map[string]bool{"true": true, "false": true, "null": true}
+
+// sanitize returns a copy of r that is safe to render: before/after values
+// are redacted using the plan's sensitivity masks, and sensitive values are
+// scrubbed from finding text and evidence. Every renderer calls it first.
+func sanitize(r Result) Result {
+	secrets := make(map[string][]string)
+
+	changes := make([]parse.ResourceChange, len(r.Changes))
+	for i, ch := range r.Changes {
+		changes[i] = ch.Redacted()
+		key := resourceKey(ch.Stack, ch.Address)
+		secrets[key] = append(secrets[key], ch.SensitiveValues()...)
+	}
+	r.Changes = changes
+
+	if r.Drift != nil {
+		drift := make([]parse.ResourceDrift, len(r.Drift))
+		for i, d := range r.Drift {
+			drift[i] = parse.ResourceDrift{ResourceChange: d.ResourceChange.Redacted()}
+			key := resourceKey(d.Stack, d.Address)
+			secrets[key] = append(secrets[key], d.SensitiveValues()...)
+		}
+		r.Drift = drift
+	}
+
+	if r.Outputs != nil {
+		outputs := make([]parse.OutputChange, len(r.Outputs))
+		for i, o := range r.Outputs {
+			outputs[i] = o.Redacted()
+		}
+		r.Outputs = outputs
+	}
+
+	r.Findings = scrubFindings(r.Findings, secrets)
+	r.Acknowledged = scrubFindings(r.Acknowledged, secrets)
+
+	return r
+}
+
+// scrubFindings returns copies of findings with the sensitive values of
+// their resource, keyed by resourceKey in secrets, scrubbed.
+func scrubFindings(findings []analyze.Finding, secrets map[string][]string) []analyze.Finding {
+	out := make([]analyze.Finding, len(findings))
+	for i, f := range findings {
+		values := secrets[resourceKey(f.Stack, f.Address)]
+		if len(values) > 0 {
+			f = scrubFinding(f, values)
+		}
+		if f.Collapsed != nil {
+			f.Collapsed = scrubFindings(f.Collapsed, secrets)
+		}
+		out[i] = f
+	}
+	return out
+}
+
+func scrubFinding(f analyze.Finding, values []string) analyze.Finding {
+	// Longest first, so a value is never partially replaced by a shorter one.
+	sorted := make([]string, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	f.Title = scrub(f.Title, sorted)
+	f.Description = scrub(f.Description, sorted)
+	if f.Evidence.Matches != nil {
+		matches := make([]string, len(f.Evidence.Matches))
+		for i, m := range f.Evidence.Matches {
+			matches[i] = scrub(m, sorted)
+		}
+		f.Evidence.Matches = matches
+	}
+	return f
+}
+
+func scrub(s string, values []string) string {
+	for _, v := range values {
+		if jsonLiterals[v] {
+			continue
+		}
+		if len(v) >= minAnywhereLen {
+			s = strings.ReplaceAll(s, v, parse.RedactedValue)
+			continue
+		}
+		s = replaceToken(s, v)
+	}
+	return s
+}
+
+// replaceToken replaces occurrences of v that are not part of a longer
+// alphanumeric run.
+func replaceToken(s, v string) string {
+	var sb strings.Builder
+	for {
+		idx := strings.Index(s, v)
+		if idx < 0 {
+			sb.WriteString(s)
+			return sb.String()
+		}
+		end := idx + len(v)
+		if isWordBoundary(s, idx-1) && isWordBoundary(s, end) {
+			sb.WriteString(s[:idx])
+			sb.WriteString(parse.RedactedValue)
+		} else {
+			sb.WriteString(s[:end])
+		}
+		s = s[end:]
+	}
+}
+
+func isWordBoundary(s string, i int) bool {
+	if i < 0 || i >= len(s) {
+		return true
+	}
+	r := rune(s[i])
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
@@ -1,20 +1,122 @@
 package render
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	"github.com/sgr0691/diffy/internal/analyze"
 	"github.com/sgr0691/diffy/internal/parse"
 )
 
+// GroupByModule adds a per-module rollup of counts and max severity.
+const GroupByModule = "module"
+
 // Result holds all data needed for rendering.
 type Result struct {
-	Counts    parse.Counts
-	Changes   []parse.ResourceChange
-	Findings  []analyze.Finding
-	Threshold *analyze.Severity // nil if --fail-on not set
-	ExitCode  int
+	Counts parse.Counts
+	// OutputCounts tallies Outputs; output changes are not included in
+	// Counts.
+	OutputCounts parse.OutputCounts
+	Changes      []parse.ResourceChange
+	Drift        []parse.ResourceDrift
+	Outputs      []parse.OutputChange
+	Findings     []analyze.Finding
+	// Acknowledged are findings matched by a suppression. They are shown
+	// separately and do not count toward the threshold.
+	Acknowledged []analyze.Finding
+	Threshold    *analyze.Severity // nil if --fail-on not set
+	ExitCode     int
+	GroupBy      string // "" or GroupByModule
+	Metadata     parse.Metadata
+	// Stacks lists the source plans when several are explained together.
+	// Changes and findings carry the matching Stack name.
+	Stacks []Stack
+}
+
+// Stack identifies one of several plans aggregated into a Result.
+type Stack struct {
+	Name     string
+	Metadata parse.Metadata
 }
 
-// Renderer renders a Result to a string.
+// Renderer renders a Result, or an error that prevented one, to a string.
 type Renderer interface {
 	Render(r Result) string
+	RenderError(err error) string
+}
+
+// errorReport is the renderer-neutral view of a load or parse error.
+type errorReport struct {
+	Kind    string // "parse", "unsupported_document", "timeout" or "error"
+	Source  string // plan file, when several were given
+	Message string
+	Hint    string
+	Path    string
+	Offset  int64
+	Line    int
+	Column  int
+	Excerpt string
+	Caret   int
+}
+
+func newErrorReport(err error) errorReport {
+	rep := errorReport{Kind: "error", Message: err.Error()}
+
+	var parseErr *parse.ParseError
+	var docErr *parse.UnsupportedDocumentError
+	var timeoutErr *parse.TimeoutError
+	switch {
+	case errors.As(err, &docErr):
+		rep.Kind = "unsupported_document"
+	case errors.As(err, &timeoutErr):
+		rep.Kind = "timeout"
+	case errors.As(err, &parseErr):
+		rep.Kind = "parse"
+		rep.Message = parseErr.Message()
+		rep.Path = parseErr.Path
+		rep.Offset = parseErr.Offset
+		rep.Line = parseErr.Line
+		rep.Column = parseErr.Column
+		rep.Excerpt = parseErr.Excerpt
+		rep.Caret = parseErr.Caret
+	}
+
+	var sourceErr *parse.SourceError
+	if errors.As(err, &sourceErr) {
+		rep.Source = sourceErr.Path
+	}
+
+	var hinted interface{ Hint() string }
+	if errors.As(err, &hinted) {
+		rep.Hint = hinted.Hint()
+	}
+	return rep
+}
+
+// location describes where a parse error occurred, e.g.
+// "resource_changes[3].address, line 12, column 5".
+func (e errorReport) location() string {
+	var parts []string
+	if e.Source != "" && e.Kind == "parse" {
+		parts = append(parts, e.Source)
+	}
+	if e.Path != "" {
+		parts = append(parts, e.Path)
+	}
+	if e.Line > 0 {
+		parts = append(parts, fmt.Sprintf("line %d, column %d", e.Line, e.Column))
+	} else if e.Offset > 0 {
+		parts = append(parts, fmt.Sprintf("byte %d", e.Offset))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// excerptLines returns the excerpt followed by a caret line under the
+// offending character, each prefixed with indent.
+func (e errorReport) excerptLines(indent string) string {
+	if e.Excerpt == "" {
+		return ""
+	}
+	return indent + e.Excerpt + "\n" + indent + strings.Repeat(" ", e.Caret) + "^\n"
 }
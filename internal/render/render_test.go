@@ -1,12 +1,17 @@
 package render
 
 import (
+	"context"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sgr0691/diffy/internal/analyze"
+	"github.com/sgr0691/diffy/internal/compare"
+	"github.com/sgr0691/diffy/internal/graph"
 	"github.com/sgr0691/diffy/internal/parse"
 )
 
@@ -19,6 +24,13 @@ func TestGoldenMarkdown(t *testing.T) {
 		{"replace", "replace.json", "replace.md"},
 		{"delete_stateful", "delete_stateful.json", "delete_stateful.md"},
 		{"benign_tags_only", "benign_tags_only.json", "benign_tags_only.md"},
+		{"drift", "drift.json", "drift.md"},
+		{"moved", "moved.json", "moved.md"},
+		{"sensitive", "sensitive.json", "sensitive.md"},
+		{"outputs", "outputs.json", "outputs.md"},
+		{"blast_radius", "blast_radius.json", "blast_radius.md"},
+		{"lifecycle", "lifecycle.json", "lifecycle.md"},
+		{"count_shift", "count_shift.json", "count_shift.md"},
 	}
 
 	for _, tt := range tests {
@@ -26,18 +38,24 @@ func TestGoldenMarkdown(t *testing.T) {
 			planPath := filepath.Join("..", "..", "examples", "plan", tt.planFile)
 			goldPath := filepath.Join("..", "..", "examples", "expected", tt.goldFile)
 
-			changes, err := parse.FromFile(planPath)
+			plan, err := parse.LoadFile(planPath)
 			if err != nil {
 				t.Fatal(err)
 			}
 
+			changes := plan.ResourceChanges
 			counts := parse.ComputeCounts(changes)
-			findings := analyze.Analyze(changes)
+			findings := analyze.AddBlastRadius(analyze.Analyze(changes), graph.Build(plan))
+			findings = append(findings, analyze.AnalyzeDrift(plan.ResourceDrift, changes)...)
+			findings = append(findings, analyze.AnalyzeOutputs(plan.OutputChanges)...)
 
 			result := Result{
-				Counts:   counts,
-				Changes:  changes,
-				Findings: findings,
+				Counts:       counts,
+				OutputCounts: parse.ComputeOutputCounts(plan.OutputChanges),
+				Changes:      changes,
+				Drift:        plan.ResourceDrift,
+				Outputs:      plan.OutputChanges,
+				Findings:     findings,
 			}
 
 			renderer := MarkdownRenderer{}
@@ -78,6 +96,7 @@ func TestJSONRenderer(t *testing.T) {
 		Findings:  findings,
 		Threshold: &threshold,
 		ExitCode:  2,
+		Metadata:  parse.Metadata{FormatVersion: "1.2", TerraformVersion: "1.9.5"},
 	}
 
 	renderer := JSONRenderer{}
@@ -95,6 +114,9 @@ func TestJSONRenderer(t *testing.T) {
 	if !strings.Contains(output, `"threshold": "high"`) {
 		t.Error("expected threshold: high in JSON output")
 	}
+	if !strings.Contains(output, `"terraform_version": "1.9.5"`) {
+		t.Error("expected terraform_version in JSON metadata")
+	}
 }
 
 func TestTextRenderer(t *testing.T) {
@@ -117,6 +139,130 @@ func TestTextRenderer(t *testing.T) {
 	}
 }
 
+func TestSensitiveValuesNeverRendered(t *testing.T) {
+	plan, err := parse.LoadFile(filepath.Join("..", "..", "examples", "plan", "sensitive.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := plan.ResourceChanges
+	findings := analyze.Analyze(changes)
+	if len(findings) == 0 {
+		t.Fatal("expected the fixture to produce findings whose evidence touches sensitive values")
+	}
+	outputs := []parse.OutputChange{{
+		Name:            "db_password",
+		Action:          parse.ActionUpdate,
+		Before:          json.RawMessage(`"hunter2-old-secret"`),
+		After:           json.RawMessage(`"hunter2-new-secret"`),
+		BeforeSensitive: true,
+		AfterSensitive:  true,
+	}}
+	result := Result{
+		Counts:       parse.ComputeCounts(changes),
+		OutputCounts: parse.ComputeOutputCounts(outputs),
+		Changes:      changes,
+		Outputs:      outputs,
+		Findings:     findings,
+	}
+
+	secrets := []string{"0.0.0.0/0", "10.20.30.0/24", "hunter2-old-secret", "hunter2-new-secret", "team-secret-key", "s3cr3t-tag-value"}
+	renderers := map[string]Renderer{
+		"md":   MarkdownRenderer{},
+		"text": TextRenderer{},
+		"json": JSONRenderer{},
+	}
+	comparison := ComparisonResult{
+		After: changes,
+		Diff:  compare.Compare(compare.Plan{}, compare.Plan{Changes: changes, Findings: findings}),
+	}
+	for name, renderer := range renderers {
+		output := renderer.Render(result) + renderer.(ComparisonRenderer).RenderComparison(comparison)
+		for _, secret := range secrets {
+			if strings.Contains(output, secret) {
+				t.Errorf("%s output leaks sensitive value %q:\n%s", name, secret, output)
+			}
+		}
+	}
+
+	// Values handed to renderers must already be redacted, so any future
+	// before/after rendering cannot leak either.
+	sanitized := sanitize(result)
+	for _, ch := range sanitized.Changes {
+		for _, raw := range []string{string(ch.Before), string(ch.After)} {
+			for _, secret := range secrets {
+				if strings.Contains(raw, secret) {
+					t.Errorf("sanitized %s still carries %q: %s", ch.Address, secret, raw)
+				}
+			}
+		}
+	}
+	for _, o := range sanitized.Outputs {
+		for _, raw := range []string{string(o.Before), string(o.After)} {
+			for _, secret := range secrets {
+				if strings.Contains(raw, secret) {
+					t.Errorf("sanitized output %s still carries %q: %s", o.Name, secret, raw)
+				}
+			}
+		}
+	}
+	for _, f := range sanitized.Findings {
+		for _, m := range f.Evidence.Matches {
+			for _, secret := range secrets {
+				if strings.Contains(m, secret) {
+					t.Errorf("sanitized finding %q leaks %q in matches", f.Title, secret)
+				}
+			}
+		}
+	}
+	if !strings.Contains(string(changes[1].After), "hunter2-new-secret") {
+		t.Error("sanitize must not mutate the caller's changes")
+	}
+}
+
+func TestScrubKeepsJSONLiterals(t *testing.T) {
+	got := scrub("create_before_destroy: true, force_destroy: false, password: p@ssw0rd, port 5432", []string{"true", "false", "null", "p@ssw0rd", "5432"})
+	want := "create_before_destroy: true, force_destroy: false, password: " + parse.RedactedValue + ", port " + parse.RedactedValue
+	if got != want {
+		t.Errorf("scrub = %q, want %q", got, want)
+	}
+}
+
+func TestGroupByModuleRollup(t *testing.T) {
+	changes := []parse.ResourceChange{
+		{Address: "aws_instance.a", Type: "aws_instance", Action: parse.ActionCreate},
+		{Address: "module.network.module.vpc.aws_route.r", ModuleAddress: "module.network.module.vpc", Type: "aws_route", Action: parse.ActionUpdate},
+		{Address: "module.network.module.vpc.aws_route.s", ModuleAddress: "module.network.module.vpc", Type: "aws_route", Action: parse.ActionDelete},
+	}
+	findings := []analyze.Finding{
+		{Severity: analyze.SeverityMedium, Title: "Network routing change detected", Address: "module.network.module.vpc.aws_route.r"},
+		{Severity: analyze.SeverityHigh, Title: "Resource deletion detected", Address: "module.network.module.vpc.aws_route.s"},
+	}
+	result := Result{
+		Counts:   parse.ComputeCounts(changes),
+		Changes:  changes,
+		Findings: findings,
+		GroupBy:  GroupByModule,
+	}
+
+	md := MarkdownRenderer{}.Render(result)
+	if !strings.Contains(md, "| module.network.module.vpc | 0 | 1 | 1 | 0 | 0 | 2 | HIGH |") {
+		t.Errorf("expected module rollup row in markdown, got:\n%s", md)
+	}
+	if !strings.Contains(md, "| (root) | 1 | 0 | 0 | 0 | 0 | 1 | - |") {
+		t.Errorf("expected root rollup row in markdown, got:\n%s", md)
+	}
+
+	text := TextRenderer{}.Render(result)
+	if !strings.Contains(text, "module.network.module.vpc: 2 changes (update 1, delete 1), max severity HIGH") {
+		t.Errorf("expected module rollup line in text, got:\n%s", text)
+	}
+
+	result.GroupBy = ""
+	if strings.Contains(MarkdownRenderer{}.Render(result), "## Modules") {
+		t.Error("expected no module rollup without --group-by")
+	}
+}
+
 func normalizeWhitespace(s string) string {
 	s = strings.TrimSpace(s)
 	lines := strings.Split(s, "\n")
@@ -125,3 +271,288 @@ func normalizeWhitespace(s string) string {
 	}
 	return strings.Join(lines, "\n")
 }
+
+func TestRenderErrorConsistentAcrossFormats(t *testing.T) {
+	_, err := parse.Load(strings.NewReader(`{"resource_changes": [
+  {"address": "a", "change": {"actions": ["create"], "replace_paths": "engine"}}
+]}`))
+	if err == nil {
+		t.Fatal("expected a parse error")
+	}
+
+	renderers := map[string]Renderer{
+		"md":   MarkdownRenderer{},
+		"text": TextRenderer{},
+		"json": JSONRenderer{},
+	}
+	for name, renderer := range renderers {
+		output := renderer.RenderError(err)
+		for _, want := range []string{"expected array, got string", "resource_changes[0].change.replace_paths", "replace_paths"} {
+			if !strings.Contains(output, want) {
+				t.Errorf("%s error output missing %q:\n%s", name, want, output)
+			}
+		}
+		if name != "json" && !strings.Contains(output, "line 2, column") {
+			t.Errorf("%s error output missing line/column:\n%s", name, output)
+		}
+		if strings.Contains(output, "engine") {
+			t.Errorf("%s error output echoes a string value:\n%s", name, output)
+		}
+	}
+
+	var out struct {
+		Error struct {
+			Path   string `json:"path"`
+			Line   int    `json:"line"`
+			Column int    `json:"column"`
+		} `json:"error"`
+		ExitCode int `json:"exit_code"`
+	}
+	if err := json.Unmarshal([]byte(JSONRenderer{}.RenderError(err)), &out); err != nil {
+		t.Fatalf("JSON error output is not valid JSON: %v", err)
+	}
+	if out.Error.Line != 2 || out.Error.Column == 0 || out.ExitCode != 1 {
+		t.Errorf("unexpected JSON error fields: %+v", out)
+	}
+
+	// Non-parse errors still carry their hint.
+	_, err = parse.Load(strings.NewReader(`{"values": {}}`))
+	for name, renderer := range renderers {
+		if output := renderer.RenderError(err); !strings.Contains(output, "terraform plan -out") {
+			t.Errorf("%s error output missing hint:\n%s", name, output)
+		}
+	}
+
+	timeout := &parse.TimeoutError{Command: "terraform show", Err: context.DeadlineExceeded}
+	for name, renderer := range renderers {
+		if output := renderer.RenderError(timeout); !strings.Contains(output, "timed out") || !strings.Contains(output, "--timeout") {
+			t.Errorf("%s timeout output missing message or hint:\n%s", name, output)
+		}
+	}
+	if output := (JSONRenderer{}).RenderError(timeout); !strings.Contains(output, `"kind": "timeout"`) {
+		t.Errorf("JSON timeout output should be distinguishable:\n%s", output)
+	}
+}
+
+func TestMultiStackRendering(t *testing.T) {
+	stacks := []Stack{{Name: "prod/db"}, {Name: "staging/db"}, {Name: "prod/dns"}}
+	changes := []parse.ResourceChange{
+		{Address: "aws_db_instance.main", Type: "aws_db_instance", Action: parse.ActionDelete, Stack: "prod/db"},
+		{Address: "aws_db_instance.main", Type: "aws_db_instance", Action: parse.ActionUpdate, Stack: "staging/db"},
+	}
+	findings := analyze.Analyze(changes)
+	threshold := analyze.SeverityCritical
+	result := Result{
+		Counts:    parse.ComputeCounts(changes),
+		Changes:   changes,
+		Findings:  findings,
+		Threshold: &threshold,
+		ExitCode:  2,
+		Stacks:    stacks,
+	}
+
+	md := MarkdownRenderer{}.Render(result)
+	for _, want := range []string{
+		"across **3** stacks",
+		"| prod/db | 0 | 0 | 1 | 0 | 0 | 1 | 1 | CRITICAL |",
+		"| staging/db | 0 | 1 | 0 | 0 | 0 | 1 | 0 | - |",
+		"| prod/dns | 0 | 0 | 0 | 0 | 0 | 0 | 0 | - |",
+		"| delete | prod/db: aws_db_instance.main | CRITICAL |",
+		// The same address in another stack is not tainted by prod's finding.
+		"| update | staging/db: aws_db_instance.main | - |",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+
+	text := TextRenderer{}.Render(result)
+	if !strings.Contains(text, "prod/db: 1 changes (delete 1), 1 findings, max severity CRITICAL") {
+		t.Errorf("text missing stack rollup:\n%s", text)
+	}
+
+	var out struct {
+		Stacks []struct {
+			Name        string       `json:"name"`
+			Counts      parse.Counts `json:"counts"`
+			Findings    int          `json:"findings"`
+			MaxSeverity string       `json:"max_severity"`
+			Decision    string       `json:"decision"`
+		} `json:"stacks"`
+		Findings []struct {
+			Stack string `json:"stack"`
+		} `json:"findings"`
+	}
+	if err := json.Unmarshal([]byte(JSONRenderer{}.Render(result)), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Stacks) != 3 {
+		t.Fatalf("expected 3 stacks, got %+v", out.Stacks)
+	}
+	if s := out.Stacks[0]; s.Name != "prod/db" || s.Counts.Delete != 1 || s.Findings != 1 || s.MaxSeverity != "critical" || s.Decision != "fail" {
+		t.Errorf("unexpected prod/db breakdown: %+v", s)
+	}
+	if s := out.Stacks[1]; s.Decision != "pass" || s.MaxSeverity != "" {
+		t.Errorf("unexpected staging/db breakdown: %+v", s)
+	}
+	if len(out.Findings) == 0 || out.Findings[0].Stack != "prod/db" {
+		t.Errorf("findings should carry their stack: %+v", out.Findings)
+	}
+}
+
+func TestComparisonRendering(t *testing.T) {
+	before := []parse.ResourceChange{
+		{Address: "aws_instance.web", Type: "aws_instance", Action: parse.ActionUpdate},
+		{Address: "aws_iam_role.ci", Type: "aws_iam_role", Action: parse.ActionCreate},
+	}
+	after := []parse.ResourceChange{
+		{Address: "aws_instance.web", Type: "aws_instance", Action: parse.ActionReplace, ReplaceOrder: parse.ReplaceDestroyBeforeCreate},
+		{Address: "aws_db_instance.main", Type: "aws_db_instance", Action: parse.ActionDelete},
+	}
+	diff := compare.Compare(
+		compare.Plan{Changes: before, Findings: analyze.Analyze(before)},
+		compare.Plan{Changes: after, Findings: analyze.Analyze(after)},
+	)
+	threshold := analyze.SeverityCritical
+	c := ComparisonResult{Before: before, After: after, Diff: diff, Threshold: &threshold, ExitCode: 2}
+
+	md := MarkdownRenderer{}.RenderComparison(c)
+	for _, want := range []string{
+		"## Added to the plan", "| delete | aws_db_instance.main | aws_db_instance |",
+		"## Dropped from the plan", "aws_iam_role.ci",
+		"| aws_instance.web | update | replace (destroy before create) |",
+		"## New findings", "**FAIL**: new findings at or above `critical`",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown comparison missing %q:\n%s", want, md)
+		}
+	}
+
+	text := TextRenderer{}.RenderComparison(c)
+	if !strings.Contains(text, "~ aws_instance.web: update → replace (destroy before create)") {
+		t.Errorf("text comparison missing action change:\n%s", text)
+	}
+
+	var out struct {
+		Added   []jsonChange `json:"added"`
+		Changed []struct {
+			Address string `json:"address"`
+			Before  string `json:"before"`
+			After   string `json:"after"`
+		} `json:"changed"`
+		NewFindings []jsonFinding `json:"new_findings"`
+		Decision    string        `json:"decision"`
+	}
+	if err := json.Unmarshal([]byte(JSONRenderer{}.RenderComparison(c)), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Added) != 1 || len(out.Changed) != 1 || out.Changed[0].Before != "update" || out.Changed[0].After != "replace" {
+		t.Errorf("unexpected JSON comparison: %+v", out)
+	}
+	if len(out.NewFindings) == 0 || out.Decision != "fail" {
+		t.Errorf("expected new findings and a fail decision: %+v", out)
+	}
+
+	empty := MarkdownRenderer{}.RenderComparison(ComparisonResult{})
+	if !strings.Contains(empty, "No differences between the plans.") {
+		t.Errorf("expected an empty comparison note:\n%s", empty)
+	}
+}
+
+func TestAcknowledgedFindings(t *testing.T) {
+	suppressed := analyze.Finding{
+		RuleID:      analyze.RuleInternetFacingLB,
+		Severity:    analyze.SeverityHigh,
+		Title:       "Internet-facing load balancer detected",
+		Description: "Load balancer aws_lb.public is internet-facing.",
+		Address:     "aws_lb.public",
+		Evidence:    analyze.Evidence{Action: parse.ActionCreate, ResourceType: "aws_lb"},
+		Suppression: &analyze.Suppression{
+			Rule:          analyze.RuleInternetFacingLB,
+			Justification: "Public ALB for the marketing site",
+			Owner:         "platform-team",
+			Expires:       time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC),
+			Source:        ".diffy-suppressions.yaml:2",
+		},
+	}
+	threshold := analyze.SeverityHigh
+	result := Result{
+		Counts:       parse.Counts{Create: 1, Total: 1},
+		Changes:      []parse.ResourceChange{{Address: "aws_lb.public", Type: "aws_lb", Action: parse.ActionCreate}},
+		Acknowledged: []analyze.Finding{suppressed},
+		Threshold:    &threshold,
+	}
+
+	md := MarkdownRenderer{}.Render(result)
+	for _, want := range []string{
+		"No findings.",
+		"## Acknowledged",
+		"- **Internet-facing load balancer detected (HIGH)** — `aws_lb.public`",
+		"Public ALB for the marketing site",
+		"owner: platform-team, expires 2026-12-31",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("expected %q in markdown output:\n%s", want, md)
+		}
+	}
+
+	text := TextRenderer{}.Render(result)
+	if !strings.Contains(text, "Acknowledged (not counted toward the threshold):") {
+		t.Errorf("expected an acknowledged list in text output:\n%s", text)
+	}
+
+	var out struct {
+		Findings     []jsonFinding `json:"findings"`
+		Acknowledged []struct {
+			RuleID      string `json:"rule_id"`
+			Suppression struct {
+				Owner   string `json:"owner"`
+				Expires string `json:"expires"`
+				Source  string `json:"source"`
+			} `json:"suppression"`
+		} `json:"acknowledged"`
+	}
+	if err := json.Unmarshal([]byte(JSONRenderer{}.Render(result)), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Findings) != 0 || len(out.Acknowledged) != 1 {
+		t.Fatalf("unexpected JSON findings: %+v", out)
+	}
+	if ack := out.Acknowledged[0]; ack.RuleID != analyze.RuleInternetFacingLB || ack.Suppression.Owner != "platform-team" ||
+		ack.Suppression.Expires != "2026-12-31" || ack.Suppression.Source != ".diffy-suppressions.yaml:2" {
+		t.Errorf("unexpected acknowledged finding: %+v", ack)
+	}
+}
+
+func TestReadBaseline(t *testing.T) {
+	findings := analyze.Analyze([]parse.ResourceChange{
+		{Address: "aws_instance.web", Type: "aws_instance", Action: parse.ActionReplace},
+	})
+	output := JSONRenderer{}.Render(Result{Findings: findings})
+	baseline, err := ReadBaseline(strings.NewReader(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(baseline) != 1 || !baseline[findings[0].Fingerprint] {
+		t.Fatalf("baseline = %v, want the fingerprint of %s", baseline, findings[0].Address)
+	}
+
+	marked := baseline.Mark(findings)
+	if md := (MarkdownRenderer{}).Render(Result{Findings: marked}); !strings.Contains(md, ", in baseline)_") {
+		t.Errorf("expected an in-baseline note in markdown output:\n%s", md)
+	}
+	if js := (JSONRenderer{}).Render(Result{Findings: marked}); !strings.Contains(js, `"in_baseline": true`) {
+		t.Errorf("expected in_baseline in JSON output:\n%s", js)
+	}
+
+	for _, data := range []string{
+		`{"changes": []}`,
+		`{"findings": [{"title": "Old", "resource_address": "aws_instance.web"}]}`,
+		`{"error": {"message": "boom"}, "decision": "error"}`,
+		`not json`,
+	} {
+		if _, err := ReadBaseline(strings.NewReader(data)); err == nil {
+			t.Errorf("ReadBaseline(%s) succeeded", data)
+		}
+	}
+}
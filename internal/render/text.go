@@ -6,36 +6,111 @@ import (
 	"strings"
 
 	"github.com/sgr0691/diffy/internal/analyze"
+	"github.com/sgr0691/diffy/internal/parse"
 )
 
 // TextRenderer renders output in plain text format.
 type TextRenderer struct{}
 
 func (t TextRenderer) Render(r Result) string {
+	r = sanitize(r)
+
 	var sb strings.Builder
 
 	sb.WriteString("Diffy Summary\n")
 	sb.WriteString(strings.Repeat("=", 40) + "\n\n")
 
-	sb.WriteString(fmt.Sprintf("Total changes: %d\n", r.Counts.Total))
+	if len(r.Stacks) > 1 {
+		sb.WriteString(fmt.Sprintf("Total changes: %d across %d stacks\n", r.Counts.Total, len(r.Stacks)))
+	} else {
+		sb.WriteString(fmt.Sprintf("Total changes: %d\n", r.Counts.Total))
+	}
 	if r.Counts.Create > 0 {
-		sb.WriteString(fmt.Sprintf("  Create:  %d\n", r.Counts.Create))
+		sb.WriteString(fmt.Sprintf("  Create:   %d\n", r.Counts.Create))
 	}
 	if r.Counts.Update > 0 {
-		sb.WriteString(fmt.Sprintf("  Update:  %d\n", r.Counts.Update))
+		sb.WriteString(fmt.Sprintf("  Update:   %d\n", r.Counts.Update))
 	}
 	if r.Counts.Delete > 0 {
-		sb.WriteString(fmt.Sprintf("  Delete:  %d\n", r.Counts.Delete))
+		sb.WriteString(fmt.Sprintf("  Delete:   %d\n", r.Counts.Delete))
 	}
 	if r.Counts.Replace > 0 {
-		sb.WriteString(fmt.Sprintf("  Replace: %d\n", r.Counts.Replace))
+		sb.WriteString(fmt.Sprintf("  Replace:  %d\n", r.Counts.Replace))
+	}
+	if r.Counts.Move > 0 {
+		sb.WriteString(fmt.Sprintf("  Move:     %d\n", r.Counts.Move))
+	}
+	if r.Counts.Import > 0 {
+		sb.WriteString(fmt.Sprintf("  Import:   %d\n", r.Counts.Import))
+	}
+	if r.Counts.Forget > 0 {
+		sb.WriteString(fmt.Sprintf("  Forget:   %d\n", r.Counts.Forget))
+	}
+	if r.Counts.Read > 0 {
+		sb.WriteString(fmt.Sprintf("  Read:     %d\n", r.Counts.Read))
+	}
+	if r.Counts.Deferred > 0 {
+		sb.WriteString(fmt.Sprintf("  Deferred: %d\n", r.Counts.Deferred))
+	}
+	if r.Counts.Unknown > 0 {
+		sb.WriteString(fmt.Sprintf("  Unknown:  %d\n", r.Counts.Unknown))
+	}
+	if r.OutputCounts.Total > 0 {
+		sb.WriteString(fmt.Sprintf("Total output changes: %d (%s)\n", r.OutputCounts.Total, outputCountsSummary(r.OutputCounts)))
 	}
 	sb.WriteString("\n")
 
+	if len(r.Stacks) > 1 {
+		sb.WriteString("Stacks:\n")
+		for _, s := range stackRollup(r.Stacks, r.Changes, r.Findings) {
+			changes := fmt.Sprintf("%d changes", s.Counts.Total)
+			if summary := countsSummary(s.Counts); summary != "" {
+				changes += " (" + summary + ")"
+			}
+			sb.WriteString(fmt.Sprintf("  %s: %s, %d findings, max severity %s\n", s.Stack.Name, changes, len(s.Findings), s.maxSeverity()))
+		}
+		sb.WriteString("\n")
+	}
+
+	if r.GroupBy == GroupByModule && (len(r.Changes) > 0 || len(r.Findings) > 0) {
+		sb.WriteString("Modules:\n")
+		for _, m := range moduleRollup(r.Changes, r.Findings) {
+			changes := fmt.Sprintf("%d changes", m.Counts.Total)
+			if summary := countsSummary(m.Counts); summary != "" {
+				changes += " (" + summary + ")"
+			}
+			sb.WriteString(fmt.Sprintf("  %s: %s, max severity %s\n", m.Module, changes, m.maxSeverity()))
+		}
+		sb.WriteString("\n")
+	}
+
 	if len(r.Changes) > 0 {
 		sb.WriteString("Changes:\n")
 		for _, ch := range r.Changes {
-			sb.WriteString(fmt.Sprintf("  [%s] %s (%s)\n", ch.Action, ch.Address, ch.Type))
+			sb.WriteString(fmt.Sprintf("  [%s] %s (%s)\n", changeLabel(ch), displayAddress(ch), ch.Type))
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(r.Outputs) > 0 {
+		sb.WriteString("Output changes:\n")
+		for _, o := range r.Outputs {
+			sb.WriteString(fmt.Sprintf("  [%s] %s (type: %s, sensitive: %s)\n", o.Action, outputDisplayAddress(o), outputTypeLabel(o), outputSensitiveLabel(o)))
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(r.Drift) > 0 {
+		planned := plannedActions(r.Changes)
+		sb.WriteString("Drift detected (changed outside of Terraform):\n")
+		for _, d := range r.Drift {
+			sb.WriteString(fmt.Sprintf("  [%s] %s (%s)\n", d.Action, withStack(d.Stack, d.Address), d.Type))
+			if a, ok := planned[resourceKey(d.Stack, d.Address)]; ok {
+				sb.WriteString(fmt.Sprintf("    planned: %s\n", a))
+			}
+			if len(d.ChangePaths) > 0 {
+				sb.WriteString(fmt.Sprintf("    changed: %s\n", strings.Join(d.ChangePaths, ", ")))
+			}
 		}
 		sb.WriteString("\n")
 	}
@@ -49,18 +124,42 @@ func (t TextRenderer) Render(r Result) string {
 			if sorted[i].Severity != sorted[j].Severity {
 				return sorted[i].Severity > sorted[j].Severity
 			}
-			return sorted[i].Address < sorted[j].Address
+			return resourceKey(sorted[i].Stack, sorted[i].Address) < resourceKey(sorted[j].Stack, sorted[j].Address)
 		})
 
 		for _, f := range sorted {
-			sb.WriteString(fmt.Sprintf("  [%s] %s — %s\n", strings.ToUpper(f.Severity.String()), f.Title, f.Address))
+			sb.WriteString(fmt.Sprintf("  [%s] %s — %s\n", strings.ToUpper(f.Severity.String()), f.Title, findingAddress(f)))
 			sb.WriteString(fmt.Sprintf("    %s\n", f.Description))
-			sb.WriteString(fmt.Sprintf("    (action: %s, type: %s)\n\n", f.Evidence.Action, f.Evidence.ResourceType))
+			if len(f.Evidence.ForcedBy) > 0 {
+				sb.WriteString(fmt.Sprintf("    forced by: %s\n", strings.Join(f.Evidence.ForcedBy, ", ")))
+			}
+			if order := replaceOrderLabel(f.Evidence.ReplaceOrder); order != "" {
+				sb.WriteString(fmt.Sprintf("    ordering: %s\n", order))
+			}
+			if len(f.Evidence.BlastRadius) > 0 {
+				sb.WriteString(fmt.Sprintf("    blast radius: %s\n", blastRadiusLabel(f.Evidence.BlastRadius, func(s string) string { return s })))
+			}
+			if len(f.Evidence.IndexShifts) > 0 {
+				sb.WriteString(fmt.Sprintf("    shifted: %s\n", strings.Join(f.Evidence.IndexShifts, ", ")))
+			}
+			if len(f.Collapsed) > 0 {
+				sb.WriteString(fmt.Sprintf("    collapses: %s\n", collapsedSummary(f.Collapsed)))
+			}
+			sb.WriteString(fmt.Sprintf("    (%s%s: %s, type: %s%s%s)\n\n", ruleNote(f), actionLabel(f), f.Evidence.Action, f.Evidence.ResourceType, confidenceNote(f), baselineNote(f)))
 		}
 	} else {
 		sb.WriteString("No findings.\n\n")
 	}
 
+	if len(r.Acknowledged) > 0 {
+		sb.WriteString("Acknowledged (not counted toward the threshold):\n")
+		for _, f := range sortedAcknowledged(r.Acknowledged) {
+			sb.WriteString(fmt.Sprintf("  - %s — %s\n", acknowledgedTitle(f), findingAddress(f)))
+			sb.WriteString(fmt.Sprintf("    %s\n", f.Suppression.Justification))
+			sb.WriteString(fmt.Sprintf("    (%s%s)\n\n", ruleNote(f), suppressionLabel(f.Suppression)))
+		}
+	}
+
 	if r.Threshold != nil {
 		if r.ExitCode == 2 {
 			sb.WriteString(fmt.Sprintf("FAIL: findings at or above '%s' threshold detected.\n", r.Threshold.String()))
@@ -71,3 +170,109 @@ func (t TextRenderer) Render(r Result) string {
 
 	return sb.String()
 }
+
+// countsSummary lists the non-zero action counts, e.g. "create 1, update 2".
+func countsSummary(c parse.Counts) string {
+	var parts []string
+	for _, entry := range []struct {
+		label string
+		n     int
+	}{
+		{"create", c.Create},
+		{"update", c.Update},
+		{"delete", c.Delete},
+		{"replace", c.Replace},
+		{"move", c.Move},
+		{"import", c.Import},
+		{"forget", c.Forget},
+		{"read", c.Read},
+		{"deferred", c.Deferred},
+		{"unknown", c.Unknown},
+	} {
+		if entry.n > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", entry.label, entry.n))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (t TextRenderer) RenderError(err error) string {
+	rep := newErrorReport(err)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Error: %s\n", rep.Message))
+	if loc := rep.location(); loc != "" {
+		sb.WriteString(fmt.Sprintf("  at %s\n", loc))
+	}
+	if rep.Excerpt != "" {
+		sb.WriteString("\n" + rep.excerptLines("    "))
+	}
+	if rep.Hint != "" {
+		sb.WriteString("\n" + rep.Hint + "\n")
+	}
+	return sb.String()
+}
+
+func (t TextRenderer) RenderComparison(c ComparisonResult) string {
+	c = sanitizeComparison(c)
+	d := c.Diff
+
+	var sb strings.Builder
+	sb.WriteString("Diffy Plan Comparison\n")
+	sb.WriteString(strings.Repeat("=", 40) + "\n\n")
+	if d.Empty() {
+		sb.WriteString("No differences between the plans.\n\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("Added:     %d\n", len(d.Added)))
+		sb.WriteString(fmt.Sprintf("Dropped:   %d\n", len(d.Dropped)))
+		sb.WriteString(fmt.Sprintf("Changed:   %d\n", len(d.Changed)))
+		sb.WriteString(fmt.Sprintf("Unchanged: %d\n\n", d.Unchanged))
+	}
+
+	if len(d.Added) > 0 {
+		sb.WriteString("Added to the plan:\n")
+		for _, ch := range d.Added {
+			sb.WriteString(fmt.Sprintf("  + [%s] %s (%s)\n", changeLabel(ch), displayAddress(ch), ch.Type))
+		}
+		sb.WriteString("\n")
+	}
+	if len(d.Dropped) > 0 {
+		sb.WriteString("Dropped from the plan:\n")
+		for _, ch := range d.Dropped {
+			sb.WriteString(fmt.Sprintf("  - [%s] %s (%s)\n", changeLabel(ch), displayAddress(ch), ch.Type))
+		}
+		sb.WriteString("\n")
+	}
+	if len(d.Changed) > 0 {
+		sb.WriteString("Action changed:\n")
+		for _, ac := range d.Changed {
+			sb.WriteString(fmt.Sprintf("  ~ %s: %s → %s\n", displayAddress(ac.New), comparisonAction(ac.Old), comparisonAction(ac.New)))
+		}
+		sb.WriteString("\n")
+	}
+	if len(d.NewFindings) > 0 {
+		sb.WriteString("New findings:\n")
+		for _, f := range d.NewFindings {
+			sb.WriteString(fmt.Sprintf("  [%s] %s — %s\n", strings.ToUpper(f.Severity.String()), f.Title, findingAddress(f)))
+			sb.WriteString(fmt.Sprintf("    %s\n", f.Description))
+		}
+		sb.WriteString("\n")
+	}
+	if len(d.ResolvedFindings) > 0 {
+		sb.WriteString("Resolved findings:\n")
+		for _, f := range d.ResolvedFindings {
+			sb.WriteString(fmt.Sprintf("  [%s] %s — %s\n", strings.ToUpper(f.Severity.String()), f.Title, findingAddress(f)))
+		}
+		sb.WriteString("\n")
+	}
+
+	if c.Threshold != nil {
+		if c.ExitCode == 2 {
+			sb.WriteString(fmt.Sprintf("FAIL: new findings at or above '%s' threshold.\n", c.Threshold.String()))
+		} else {
+			sb.WriteString(fmt.Sprintf("PASS: no new findings at or above '%s' threshold.\n", c.Threshold.String()))
+		}
+	}
+
+	return sb.String()
+}